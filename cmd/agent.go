@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/karol-broda/snitch/internal/collector"
+)
+
+// agent-specific flags
+var (
+	agentListen    string
+	agentTokenFile string
+	agentTLSCert   string
+	agentTLSKey    string
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Serve this host's connections to snitch --remote",
+	Long: `Serve this host's connections to snitch --remote.
+
+The agent answers GET /v1/connections with a snapshot of this host, the
+same json 'snitch snapshot save' writes. Filters given on the client run
+on the agent, so only matching connections are sent:
+  snitch agent --listen :9797                 # on web-1
+  snitch top --remote web-1:9797              # anywhere else
+  snitch ls --remote web-1:9797 state=listen
+
+Requests must carry the token from --token-file or $SNITCH_AGENT_TOKEN as
+a bearer token; clients read theirs from --remote-token-file or
+$SNITCH_REMOTE_TOKEN. A token is required unless the agent only listens
+on loopback. With --tls-cert and --tls-key the agent serves https, and
+clients connect with --remote https://web-1:9797.
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runAgent()
+	},
+}
+
+func runAgent() {
+	if remote != nil || replayed != nil {
+		log.Fatalf("Error: the agent serves this host, it cannot be combined with --remote or --from-snapshot")
+	}
+	token, err := readToken(agentTokenFile, "SNITCH_AGENT_TOKEN")
+	if err != nil {
+		log.Fatalf("Error reading token: %v", err)
+	}
+	if token == "" && !isLoopbackListen(agentListen) {
+		log.Fatalf("Error: a token is required to listen on %s (set --token-file or SNITCH_AGENT_TOKEN)", agentListen)
+	}
+	if (agentTLSCert == "") != (agentTLSKey == "") {
+		log.Fatalf("Error: --tls-cert and --tls-key must be given together")
+	}
+
+	server := &http.Server{
+		Addr: agentListen,
+		Handler: collector.NewAgentHandler(collector.AgentOptions{
+			Token:         token,
+			Collector:     collectorOptions(nil),
+			SnitchVersion: Version,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdown)
+	}()
+
+	scheme := "http"
+	if agentTLSCert != "" {
+		scheme = "https"
+	}
+	fmt.Fprintf(os.Stderr, "snitch agent serving %s on %s\n", scheme, agentListen)
+
+	if agentTLSCert != "" {
+		err = server.ListenAndServeTLS(agentTLSCert, agentTLSKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// isLoopbackListen reports whether addr only accepts connections from this
+// host
+func isLoopbackListen(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+
+	agentCmd.Flags().StringVar(&agentListen, "listen", "127.0.0.1:9797", "Address to serve on")
+	agentCmd.Flags().StringVar(&agentTokenFile, "token-file", "", "File holding the token clients must send (default $SNITCH_AGENT_TOKEN)")
+	agentCmd.Flags().StringVar(&agentTLSCert, "tls-cert", "", "Certificate to serve https with")
+	agentCmd.Flags().StringVar(&agentTLSKey, "tls-key", "", "Key of --tls-cert")
+}
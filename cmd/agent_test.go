@@ -0,0 +1,21 @@
+package cmd
+
+import "testing"
+
+func TestIsLoopbackListen(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:9797": true,
+		"[::1]:9797":     true,
+		"localhost:9797": true,
+		":9797":          false,
+		"0.0.0.0:9797":   false,
+		"10.0.0.5:9797":  false,
+		"web-1:9797":     false,
+		"9797":           false,
+	}
+	for addr, want := range tests {
+		if got := isLoopbackListen(addr); got != want {
+			t.Errorf("isLoopbackListen(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
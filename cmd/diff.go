@@ -0,0 +1,377 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/karol-broda/snitch/internal/collector"
+	"github.com/karol-broda/snitch/internal/errutil"
+)
+
+// diff-specific flags
+var (
+	diffOutputFormat string
+	diffExitCode     bool
+	diffQuiet        bool
+)
+
+// diff exit codes, as in diff(1)
+const (
+	diffExitDiffers = 1 // the sides differ, with --exit-code or -q
+	diffExitTrouble = 2 // the diff could not be made
+)
+
+// Flow kinds compared by diff
+const (
+	flowListen = "listen" // a listening socket
+	flowOut    = "out"    // connections to a remote endpoint
+	flowIn     = "in"     // connections accepted on a listening port
+)
+
+// DiffEntry is a listener or a group of connections that differs between
+// the two sides of a diff
+type DiffEntry struct {
+	Change    string   `json:"change"` // added, removed, changed
+	Kind      string   `json:"kind"`   // listen, out, in
+	Namespace string   `json:"namespace,omitempty"`
+	Proto     string   `json:"proto"`
+	Laddr     string   `json:"laddr,omitempty"`
+	Lport     int      `json:"lport,omitempty"`
+	Raddr     string   `json:"raddr,omitempty"`
+	Rport     int      `json:"rport,omitempty"`
+	Before    []string `json:"before,omitempty"` // states on the first side
+	After     []string `json:"after,omitempty"`  // states on the second side
+	CountA    int      `json:"count_before"`
+	CountB    int      `json:"count_after"`
+}
+
+// DiffProcess groups the differences of one process
+type DiffProcess struct {
+	Process string      `json:"process"`
+	Changes []DiffEntry `json:"changes"`
+}
+
+// DiffReport is the result of snitch diff
+type DiffReport struct {
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Added     int           `json:"added"`
+	Removed   int           `json:"removed"`
+	Changed   int           `json:"changed"`
+	Processes []DiffProcess `json:"processes"`
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <a> <b> [filters...]",
+	Short: "Compare the connections of two snapshots, or a snapshot and live",
+	Long: `Compare the connections of two snapshots, or a snapshot and live.
+
+Each side is a file saved with 'snitch snapshot save' or "live" for this
+host, or for the --remote agent. Listeners and connections that were
+added, removed or changed state are listed per process. Connections are
+compared by what they connect to, not by socket: outgoing ones by remote
+address and port, accepted ones by the listening port, so reconnects and
+new ephemeral ports are no change. TIME_WAIT sockets are ignored.
+
+  snitch snapshot save before.json
+  # deploy
+  snitch diff before.json live state=listen --exit-code
+
+With --exit-code (or -q, which prints nothing) diff exits with 1 when the
+sides differ, like git diff. It exits with 2 when something goes wrong,
+such as a snapshot that cannot be read, so scripts can tell the two apart.
+`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if code := runDiffCommand(os.Stdout, os.Stderr, args[0], args[1], args[2:]); code != 0 {
+			os.Exit(code)
+		}
+	},
+}
+
+// runDiffCommand prints the diff of a and b and returns the exit code
+func runDiffCommand(out, errOut io.Writer, a, b string, args []string) int {
+	fail := func(format string, v ...any) int {
+		errutil.Ignore(fmt.Fprintf(errOut, format+"\n", v...))
+		return diffExitTrouble
+	}
+
+	filters, err := BuildFilters(args)
+	if err != nil {
+		return fail("Error parsing filters: %v", err)
+	}
+
+	fromLabel, from, err := loadDiffSide(a, args)
+	if err != nil {
+		return fail("Error reading %s: %v", a, err)
+	}
+	toLabel, to, err := loadDiffSide(b, args)
+	if err != nil {
+		return fail("Error reading %s: %v", b, err)
+	}
+
+	report := diffSnapshots(collector.FilterConnections(from, filters), collector.FilterConnections(to, filters))
+	report.From, report.To = fromLabel, toLabel
+
+	if !diffQuiet {
+		switch diffOutputFormat {
+		case "json":
+			if err := printDiffJSON(out, report); err != nil {
+				return fail("Error marshaling JSON: %v", err)
+			}
+		default:
+			printDiffTable(out, report)
+		}
+	}
+
+	if (diffExitCode || diffQuiet) && len(report.Processes) > 0 {
+		return diffExitDiffers
+	}
+	return 0
+}
+
+// loadDiffSide reads one side of a diff: a snapshot file, or this host
+// when name is "live"
+func loadDiffSide(name string, filterArgs []string) (string, []collector.Connection, error) {
+	if name == "live" && remote != nil {
+		snapshot, err := remote.Fetch(collector.FilterOptions{})
+		if err != nil {
+			return "", nil, err
+		}
+		return "live " + remote.Addr() + " (" + snapshot.Describe() + ")", snapshot.Connections, nil
+	}
+	if name == "live" {
+		conns, err := collector.NewDefaultCollector(collectorOptions(filterArgs)).GetConnections()
+		return "live", conns, err
+	}
+	snapshot, err := collector.LoadSnapshot(name)
+	if err != nil {
+		return "", nil, err
+	}
+	return name + " (" + snapshot.Describe() + ")", snapshot.Connections, nil
+}
+
+// diffFlow is a listener or a group of connections on one side of a diff
+type diffFlow struct {
+	process string
+	entry   DiffEntry // without change, states and counts
+	states  map[string]bool
+	count   int
+}
+
+// diffFlows groups connections into the flows diff compares
+func diffFlows(conns []collector.Connection) map[string]*diffFlow {
+	// accepted connections are recognised by their local port listening
+	listening := make(map[string]bool)
+	for _, c := range conns {
+		if c.State == "LISTEN" {
+			listening[stableNamespace(c.Namespace)+"|"+c.Proto+"|"+strconv.Itoa(c.Lport)] = true
+		}
+	}
+
+	flows := make(map[string]*diffFlow)
+	for _, c := range conns {
+		if c.State == "TIME_WAIT" {
+			continue
+		}
+		e := DiffEntry{Namespace: stableNamespace(c.Namespace), Proto: c.Proto}
+		switch {
+		case c.State == "LISTEN" || (c.Rport == 0 && c.Proto != "unix"):
+			e.Kind, e.Laddr, e.Lport = flowListen, c.Laddr, c.Lport
+		case c.Proto == "unix":
+			// connected unix sockets have no endpoint worth comparing
+			continue
+		case listening[e.Namespace+"|"+e.Proto+"|"+strconv.Itoa(c.Lport)]:
+			e.Kind, e.Lport = flowIn, c.Lport
+		default:
+			e.Kind, e.Raddr, e.Rport = flowOut, c.Raddr, c.Rport
+		}
+
+		key := strings.Join([]string{c.Process, e.Kind, e.Namespace, e.Proto, e.Laddr, strconv.Itoa(e.Lport), e.Raddr, strconv.Itoa(e.Rport)}, "|")
+		f := flows[key]
+		if f == nil {
+			f = &diffFlow{process: c.Process, entry: e, states: make(map[string]bool)}
+			flows[key] = f
+		}
+		f.states[c.State] = true
+		f.count++
+	}
+	return flows
+}
+
+// stableNamespace keeps namespace names, host or from ip netns, and drops
+// bare inode numbers, which change with every boot and differ across hosts
+func stableNamespace(ns string) string {
+	if _, err := strconv.ParseUint(ns, 10, 64); err == nil {
+		return ""
+	}
+	return ns
+}
+
+// diffSnapshots compares two sets of connections flow by flow
+func diffSnapshots(from, to []collector.Connection) DiffReport {
+	a, b := diffFlows(from), diffFlows(to)
+	byProcess := make(map[string][]DiffEntry)
+	var report DiffReport
+
+	add := func(f *diffFlow, change string, before, after *diffFlow) {
+		e := f.entry
+		e.Change = change
+		if before != nil {
+			e.Before, e.CountA = sortedStates(before.states), before.count
+		}
+		if after != nil {
+			e.After, e.CountB = sortedStates(after.states), after.count
+		}
+		byProcess[f.process] = append(byProcess[f.process], e)
+	}
+
+	for key, fa := range a {
+		fb, ok := b[key]
+		switch {
+		case !ok:
+			add(fa, "removed", fa, nil)
+			report.Removed++
+		case strings.Join(sortedStates(fa.states), ",") != strings.Join(sortedStates(fb.states), ","):
+			add(fa, "changed", fa, fb)
+			report.Changed++
+		}
+	}
+	for key, fb := range b {
+		if _, ok := a[key]; !ok {
+			add(fb, "added", nil, fb)
+			report.Added++
+		}
+	}
+
+	report.Processes = make([]DiffProcess, 0, len(byProcess))
+	for process, entries := range byProcess {
+		sort.Slice(entries, func(i, j int) bool {
+			return diffEntryKey(entries[i]) < diffEntryKey(entries[j])
+		})
+		report.Processes = append(report.Processes, DiffProcess{Process: process, Changes: entries})
+	}
+	sort.Slice(report.Processes, func(i, j int) bool {
+		return report.Processes[i].Process < report.Processes[j].Process
+	})
+	return report
+}
+
+// diffEntryKey orders listeners first, then accepted and outgoing
+// connections, each by port
+func diffEntryKey(e DiffEntry) string {
+	order := map[string]string{flowListen: "0", flowIn: "1", flowOut: "2"}[e.Kind]
+	return fmt.Sprintf("%s|%05d|%s|%05d|%s|%s", order, e.Lport, e.Raddr, e.Rport, e.Proto, e.Laddr)
+}
+
+func sortedStates(states map[string]bool) []string {
+	list := make([]string, 0, len(states))
+	for s := range states {
+		if s != "" {
+			list = append(list, s)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+func printDiffJSON(out io.Writer, report DiffReport) error {
+	jsonOutput, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	errutil.Ignore(fmt.Fprintln(out, string(jsonOutput)))
+	return nil
+}
+
+func printDiffTable(out io.Writer, report DiffReport) {
+	errutil.Ignore(fmt.Fprintf(out, "--- %s\n+++ %s\n", report.From, report.To))
+	if len(report.Processes) == 0 {
+		errutil.Ignore(fmt.Fprintln(out, "no differences"))
+		return
+	}
+
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 8, 2, ' ', 0)
+	for _, p := range report.Processes {
+		process := p.Process
+		if process == "" {
+			process = "(unknown process)"
+		}
+		errutil.Ignore(fmt.Fprintf(w, "\n%s\n", process))
+
+		for _, e := range p.Changes {
+			icon := map[string]string{"added": "+", "removed": "-", "changed": "~"}[e.Change]
+			errutil.Ignore(fmt.Fprintf(w, "  %s %s\t%s\t%s\t%s\n", icon, e.Kind, e.Proto, formatDiffEndpoint(e), formatDiffStates(e)))
+		}
+	}
+	errutil.Flush(w)
+	// listeners leave the last column empty
+	for _, line := range strings.SplitAfter(table.String(), "\n") {
+		errutil.Ignore(io.WriteString(out, strings.TrimRight(line, " \n")+strings.Repeat("\n", strings.Count(line, "\n"))))
+	}
+
+	errutil.Ignore(fmt.Fprintf(out, "\n%d added, %d removed, %d changed\n", report.Added, report.Removed, report.Changed))
+}
+
+func formatDiffEndpoint(e DiffEntry) string {
+	endpoint := ""
+	switch e.Kind {
+	case flowListen:
+		endpoint = e.Laddr
+		if e.Proto != "unix" {
+			endpoint += ":" + strconv.Itoa(e.Lport)
+		}
+	case flowIn:
+		endpoint = "<- :" + strconv.Itoa(e.Lport)
+	case flowOut:
+		endpoint = "-> " + e.Raddr + ":" + strconv.Itoa(e.Rport)
+	}
+	if e.Namespace != "" {
+		endpoint += " [" + e.Namespace + "]"
+	}
+	return endpoint
+}
+
+// formatDiffStates shows the states of a changed flow and how many
+// connections a flow has, when it has more than one
+func formatDiffStates(e DiffEntry) string {
+	count := func(n int) string {
+		if n > 1 && e.Kind != flowListen {
+			return fmt.Sprintf(" (%d)", n)
+		}
+		return ""
+	}
+	switch e.Change {
+	case "changed":
+		return strings.Join(e.Before, ",") + count(e.CountA) + " -> " + strings.Join(e.After, ",") + count(e.CountB)
+	case "removed":
+		if e.Kind == flowListen {
+			return ""
+		}
+		return strings.Join(e.Before, ",") + count(e.CountA)
+	default:
+		if e.Kind == flowListen {
+			return ""
+		}
+		return strings.Join(e.After, ",") + count(e.CountB)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVarP(&diffOutputFormat, "output", "o", "table", "Output format (table, json)")
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "Exit with 1 when the sides differ")
+	diffCmd.Flags().BoolVarP(&diffQuiet, "quiet", "q", false, "Print nothing, only set the exit code")
+	addFilterFlags(diffCmd)
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/karol-broda/snitch/internal/collector"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	before := []collector.Connection{
+		{Process: "nginx", Proto: "tcp", State: "LISTEN", Laddr: "0.0.0.0", Lport: 80},
+		{Process: "nginx", Proto: "tcp", State: "ESTABLISHED", Laddr: "10.0.0.1", Lport: 80, Raddr: "203.0.113.5", Rport: 51000},
+		{Process: "app", Proto: "tcp", State: "ESTABLISHED", Laddr: "10.0.0.1", Lport: 40000, Raddr: "10.0.0.9", Rport: 5432},
+		{Process: "app", Proto: "tcp", State: "LISTEN", Laddr: "127.0.0.1", Lport: 9000},
+		{Process: "app", Proto: "tcp", State: "TIME_WAIT", Laddr: "10.0.0.1", Lport: 40001, Raddr: "10.0.0.9", Rport: 6379},
+	}
+	after := []collector.Connection{
+		{Process: "nginx", Proto: "tcp", State: "LISTEN", Laddr: "0.0.0.0", Lport: 80},
+		// another client on the same listener is no change
+		{Process: "nginx", Proto: "tcp", State: "ESTABLISHED", Laddr: "10.0.0.1", Lport: 80, Raddr: "198.51.100.7", Rport: 62000},
+		// a reconnect from a new ephemeral port, stuck this time
+		{Process: "app", Proto: "tcp", State: "SYN_SENT", Laddr: "10.0.0.1", Lport: 40100, Raddr: "10.0.0.9", Rport: 5432},
+		{Process: "app", Proto: "udp", Laddr: "0.0.0.0", Lport: 5353},
+	}
+
+	report := diffSnapshots(before, after)
+	if report.Added != 1 || report.Removed != 1 || report.Changed != 1 {
+		t.Fatalf("expected 1 added, 1 removed, 1 changed, got %+v", report)
+	}
+	if len(report.Processes) != 1 || report.Processes[0].Process != "app" {
+		t.Fatalf("expected only app to differ, got %+v", report.Processes)
+	}
+
+	changes := report.Processes[0].Changes
+	want := []struct {
+		change, kind string
+		port         int
+	}{
+		{"added", flowListen, 5353},
+		{"removed", flowListen, 9000},
+		{"changed", flowOut, 5432},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for i, w := range want {
+		port := changes[i].Lport
+		if w.kind == flowOut {
+			port = changes[i].Rport
+		}
+		if changes[i].Change != w.change || changes[i].Kind != w.kind || port != w.port {
+			t.Errorf("change %d: expected %s %s %d, got %+v", i, w.change, w.kind, w.port, changes[i])
+		}
+	}
+	if c := changes[2]; strings.Join(c.Before, ",") != "ESTABLISHED" || strings.Join(c.After, ",") != "SYN_SENT" {
+		t.Errorf("expected ESTABLISHED -> SYN_SENT, got %v -> %v", c.Before, c.After)
+	}
+}
+
+func TestDiffFlowsNamespaces(t *testing.T) {
+	// inode numbers differ between boots, names do not
+	a := []collector.Connection{{Process: "dns", Proto: "udp", Namespace: "4026531840", Laddr: "0.0.0.0", Lport: 53}}
+	b := []collector.Connection{{Process: "dns", Proto: "udp", Namespace: "4026532001", Laddr: "0.0.0.0", Lport: 53}}
+	if report := diffSnapshots(a, b); len(report.Processes) != 0 {
+		t.Errorf("expected no differences across namespace inodes, got %+v", report.Processes)
+	}
+
+	b[0].Namespace = "blue"
+	if report := diffSnapshots(a, b); report.Added != 1 || report.Removed != 1 {
+		t.Errorf("expected a listener moved into a named namespace to differ, got %+v", report)
+	}
+}
+
+func TestPrintDiffTable(t *testing.T) {
+	report := diffSnapshots(nil, []collector.Connection{
+		{Process: "redis", Proto: "tcp", State: "LISTEN", Laddr: "127.0.0.1", Lport: 6379},
+		{Process: "redis", Proto: "tcp", State: "ESTABLISHED", Laddr: "127.0.0.1", Lport: 6379, Raddr: "127.0.0.1", Rport: 50001},
+		{Process: "redis", Proto: "tcp", State: "ESTABLISHED", Laddr: "127.0.0.1", Lport: 6379, Raddr: "127.0.0.1", Rport: 50002},
+	})
+	report.From, report.To = "before.json", "live"
+
+	var out bytes.Buffer
+	printDiffTable(&out, report)
+	got := out.String()
+
+	for _, want := range []string{"--- before.json\n+++ live\n", "\nredis\n", "+ listen  tcp  127.0.0.1:6379\n", "+ in      tcp  <- :6379        ESTABLISHED (2)\n", "2 added, 0 removed, 0 changed"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in\n%s", want, got)
+		}
+	}
+}
+
+func TestRunDiffCommandExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	before := write("before.json", `[{"process":"nginx","proto":"tcp","state":"LISTEN","laddr":"*","lport":80}]`)
+	after := write("after.json", `[{"process":"nginx","proto":"tcp","state":"LISTEN","laddr":"*","lport":443}]`)
+
+	saved := diffExitCode
+	diffExitCode = true
+	defer func() { diffExitCode = saved }()
+
+	for _, tc := range []struct {
+		name string
+		a, b string
+		args []string
+		want int
+	}{
+		{"same", before, before, nil, 0},
+		{"differs", before, after, nil, diffExitDiffers},
+		{"missing snapshot", before, filepath.Join(dir, "missing.json"), nil, diffExitTrouble},
+		{"bad filter", before, after, []string{"bogus=1"}, diffExitTrouble},
+	} {
+		var out, errOut bytes.Buffer
+		if got := runDiffCommand(&out, &errOut, tc.a, tc.b, tc.args); got != tc.want {
+			t.Errorf("%s: expected exit code %d, got %d (%s)", tc.name, tc.want, got, errOut.String())
+		}
+		if (tc.want == diffExitTrouble) != (errOut.Len() > 0) {
+			t.Errorf("%s: unexpected stderr %q", tc.name, errOut.String())
+		}
+	}
+}
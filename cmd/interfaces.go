@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/karol-broda/snitch/internal/collector"
+	"github.com/karol-broda/snitch/internal/errutil"
+)
+
+// InterfaceSummary is an interface together with the sockets using it
+type InterfaceSummary struct {
+	Name    string   `json:"name"`
+	Up      bool     `json:"up"`
+	Addrs   []string `json:"addrs"`
+	Sockets int      `json:"sockets"`
+}
+
+var interfacesOutputFormat string
+
+var interfacesCmd = &cobra.Command{
+	Use:   "interfaces [filters...]",
+	Short: "List network interfaces with their addresses and socket counts",
+	Long: `List network interfaces with their addresses and socket counts.
+
+Sockets bound to a wildcard address are counted under "all". Filters are
+the same as for 'snitch ls', for example:
+  snitch interfaces state=listen
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runInterfacesCommand(args)
+	},
+}
+
+func runInterfacesCommand(args []string) {
+	filters, err := BuildFilters(args)
+	if err != nil {
+		log.Fatalf("Error parsing filters: %v", err)
+	}
+
+	ifaces, err := collector.ListInterfaces()
+	if err != nil {
+		log.Fatalf("Error listing interfaces: %v", err)
+	}
+
+	connections, err := FetchConnections(filters)
+	if err != nil {
+		log.Fatalf("Error fetching connections: %v", err)
+	}
+
+	summaries := buildInterfaceSummaries(ifaces, connections)
+
+	switch interfacesOutputFormat {
+	case "json":
+		jsonOutput, err := json.MarshalIndent(summaries, "", "  ")
+		if err != nil {
+			log.Fatalf("Error marshaling JSON: %v", err)
+		}
+		fmt.Println(string(jsonOutput))
+	default:
+		printInterfacesTable(summaries)
+	}
+}
+
+// buildInterfaceSummaries lists the host interfaces in index order, followed
+// by "all" and any interface only seen on sockets (e.g. from another netns)
+func buildInterfaceSummaries(ifaces []collector.Interface, connections []collector.Connection) []InterfaceSummary {
+	counts := make(map[string]int)
+	for _, conn := range connections {
+		if conn.Interface != "" {
+			counts[conn.Interface]++
+		}
+	}
+
+	sort.Slice(ifaces, func(i, j int) bool {
+		return ifaces[i].Index < ifaces[j].Index
+	})
+
+	summaries := make([]InterfaceSummary, 0, len(ifaces)+1)
+	for _, iface := range ifaces {
+		summaries = append(summaries, InterfaceSummary{
+			Name:    iface.Name,
+			Up:      iface.Up,
+			Addrs:   iface.Addrs,
+			Sockets: counts[iface.Name],
+		})
+		delete(counts, iface.Name)
+	}
+
+	summaries = append(summaries, InterfaceSummary{
+		Name:    collector.InterfaceAll,
+		Up:      true,
+		Addrs:   []string{},
+		Sockets: counts[collector.InterfaceAll],
+	})
+	delete(counts, collector.InterfaceAll)
+
+	others := make([]string, 0, len(counts))
+	for name := range counts {
+		others = append(others, name)
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		summaries = append(summaries, InterfaceSummary{
+			Name:    name,
+			Addrs:   []string{},
+			Sockets: counts[name],
+		})
+	}
+
+	return summaries
+}
+
+func printInterfacesTable(summaries []InterfaceSummary) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	defer errutil.Flush(w)
+
+	errutil.Ignore(fmt.Fprintln(w, "INTERFACE\tSTATE\tSOCKETS\tADDRESSES"))
+	for _, s := range summaries {
+		state := "down"
+		if s.Up {
+			state = "up"
+		}
+		if s.Name == collector.InterfaceAll {
+			state = "-"
+		}
+		addrs := "-"
+		if len(s.Addrs) > 0 {
+			addrs = strings.Join(s.Addrs, ", ")
+		}
+		errutil.Ignore(fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.Name, state, s.Sockets, addrs))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(interfacesCmd)
+
+	interfacesCmd.Flags().StringVarP(&interfacesOutputFormat, "output", "o", "table", "Output format (table, json)")
+
+	// shared filter flags
+	addFilterFlags(interfacesCmd)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/karol-broda/snitch/internal/collector"
+)
+
+func TestBuildInterfaceSummaries(t *testing.T) {
+	ifaces := []collector.Interface{
+		{Name: "eth0", Index: 2, Up: true, Addrs: []string{"192.0.2.2/24"}},
+		{Name: "lo", Index: 1, Up: true, Addrs: []string{"127.0.0.1/8"}},
+	}
+	conns := []collector.Connection{
+		{Interface: "lo"},
+		{Interface: "eth0"},
+		{Interface: "eth0"},
+		{Interface: "all"},
+		{Interface: "veth1"},
+		{},
+	}
+
+	got := buildInterfaceSummaries(ifaces, conns)
+
+	want := []struct {
+		name    string
+		sockets int
+	}{
+		{"lo", 1},
+		{"eth0", 2},
+		{"all", 1},
+		{"veth1", 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d summaries, got %d: %+v", len(want), len(got), got)
+	}
+	for i, w := range want {
+		if got[i].Name != w.name || got[i].Sockets != w.sockets {
+			t.Errorf("summary %d: expected %s=%d, got %s=%d", i, w.name, w.sockets, got[i].Name, got[i].Sockets)
+		}
+	}
+}
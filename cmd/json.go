@@ -15,5 +15,6 @@ var jsonCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(jsonCmd)
+	jsonCmd.Flags().BoolVar(&jsonEnvelope, "envelope", false, "Wrap the output in an object with connections and warnings arrays")
 	addFilterFlags(jsonCmd)
 }
\ No newline at end of file
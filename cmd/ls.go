@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
@@ -34,8 +35,15 @@ var (
 	fields        string
 	colorMode     string
 	plainOutput   bool
+	jsonEnvelope  bool
 )
 
+// listEnvelope is the json output of ls and json with --envelope
+type listEnvelope struct {
+	Connections []collector.Connection `json:"connections"`
+	Warnings    []collector.Warning    `json:"warnings"`
+}
+
 var lsCmd = &cobra.Command{
 	Use:   "ls [filters...]",
 	Short: "One-shot listing of connections",
@@ -44,9 +52,7 @@ var lsCmd = &cobra.Command{
 Filters are specified in key=value format. For example:
   snitch ls proto=tcp state=established
 
-Available filters:
-  proto, state, pid, proc, lport, rport, user, laddr, raddr, contains, if, mark, namespace, inode, since
-`,
+` + filterKeysHelp,
 	Run: func(cmd *cobra.Command, args []string) {
 		runListCommand(outputFormat, args)
 	},
@@ -76,10 +82,19 @@ func runListCommand(outputFormat string, args []string) {
 	// handle file output
 	if outputFile != "" {
 		writeToFile(rt.Connections, outputFile, selectedFields)
+		printWarnings(os.Stderr, rt.Warnings)
 		return
 	}
 
-	renderList(rt.Connections, outputFormat, selectedFields)
+	renderList(rt.Connections, outputFormat, selectedFields, rt.Warnings)
+}
+
+// printWarnings reports collection gaps on one line. machine readable
+// formats send it to stderr so their output stays parsable.
+func printWarnings(w io.Writer, warnings []collector.Warning) {
+	if summary := collector.SummarizeWarnings(warnings); summary != "" {
+		errutil.Ignore(fmt.Fprintf(w, "warning: %s\n", summary))
+	}
 }
 
 func writeToFile(connections []collector.Connection, filename string, selectedFields []string) {
@@ -144,17 +159,31 @@ func writeDelimited(w io.Writer, connections []collector.Connection, delimiter s
 	}
 }
 
-func renderList(connections []collector.Connection, format string, selectedFields []string) {
+func renderList(connections []collector.Connection, format string, selectedFields []string, warnings []collector.Warning) {
 	switch format {
 	case "json":
+		if jsonEnvelope {
+			// both are always arrays, so scripts can index them
+			if connections == nil {
+				connections = []collector.Connection{}
+			}
+			if warnings == nil {
+				warnings = []collector.Warning{}
+			}
+			printJSON(listEnvelope{Connections: connections, Warnings: warnings})
+			return
+		}
 		printJSON(connections)
+		printWarnings(os.Stderr, warnings)
 	case "csv":
 		printCSV(connections, !noHeaders, showTimestamp, selectedFields)
+		printWarnings(os.Stderr, warnings)
 	case "table", "wide":
 		if plainOutput {
 			printPlainTable(connections, !noHeaders, showTimestamp, selectedFields)
+			printWarnings(os.Stderr, warnings)
 		} else {
-			printStyledTable(connections, !noHeaders, selectedFields)
+			printStyledTable(connections, !noHeaders, selectedFields, warnings)
 		}
 	default:
 		log.Fatalf("Invalid output format: %s. Valid formats are: table, wide, json, csv", format)
@@ -187,34 +216,98 @@ func getFieldMap(c collector.Connection) map[string]string {
 			rport = resolvedRport
 		}
 	}
+
+	// unix sockets have a path instead of an address and port
+	if c.Proto == "unix" {
+		lport, rport = "", ""
+	}
 	
-	return map[string]string{
-		"pid":       strconv.Itoa(c.PID),
-		"process":   c.Process,
-		"cmdline":   c.Cmdline,
-		"cwd":       c.Cwd,
-		"user":      c.User,
-		"uid":       strconv.Itoa(c.UID),
-		"proto":     c.Proto,
-		"ipversion": c.IPVersion,
-		"state":     c.State,
-		"laddr":     laddr,
-		"lport":     lport,
-		"raddr":     raddr,
-		"rport":     rport,
-		"if":        c.Interface,
-		"rx_bytes":  strconv.FormatInt(c.RxBytes, 10),
-		"tx_bytes":  strconv.FormatInt(c.TxBytes, 10),
-		"rtt_ms":    strconv.FormatFloat(c.RttMs, 'f', 1, 64),
-		"mark":      c.Mark,
-		"namespace": c.Namespace,
-		"inode":     strconv.FormatInt(c.Inode, 10),
-		"ts":        c.TS.Format("2006-01-02T15:04:05.000Z07:00"),
+	fields := map[string]string{
+		"pid":              strconv.Itoa(c.PID),
+		"process":          c.Process,
+		"cmdline":          c.Cmdline,
+		"cwd":              c.Cwd,
+		"user":             c.User,
+		"uid":              strconv.Itoa(c.UID),
+		"proto":            c.Proto,
+		"ipversion":        c.IPVersion,
+		"state":            c.State,
+		"laddr":            laddr,
+		"lport":            lport,
+		"raddr":            raddr,
+		"rport":            rport,
+		"if":               c.Interface,
+		"rx_bytes":         strconv.FormatInt(c.RxBytes, 10),
+		"tx_bytes":         strconv.FormatInt(c.TxBytes, 10),
+		"rtt_ms":           strconv.FormatFloat(c.RttMs, 'f', 1, 64),
+		"cwnd":             strconv.Itoa(c.Cwnd),
+		"total_retrans":    strconv.Itoa(c.TotalRetrans),
+		"delivery_rate":    strconv.FormatInt(c.DeliveryRate, 10),
+		"sendq":            strconv.FormatInt(c.SendQ, 10),
+		"recvq":            strconv.FormatInt(c.RecvQ, 10),
+		"timer":            c.Timer,
+		"timer_expires_ms": strconv.FormatInt(c.TimerExpiresMs, 10),
+		"retransmits":      strconv.Itoa(c.Retransmits),
+		"mark":             c.Mark,
+		"namespace":        c.Namespace,
+		"inode":            strconv.FormatInt(c.Inode, 10),
+		"id":               c.ID,
+		"container":        collector.ShortContainerID(c.ContainerID),
+		"container_id":     c.ContainerID,
+		"runtime":          c.ContainerRuntime,
+		"pod_uid":          c.PodUID,
+		"pod_qos":          c.PodQoS,
+		"sock_type":        c.SockType,
+		"holders":          formatHolders(c.Holders),
+		"ppid":             strconv.Itoa(c.PPID),
+		"start_time":       formatTime(c.StartTime),
+		"exe":              c.Exe,
+		"exe_deleted":      strconv.FormatBool(c.ExeDeleted),
+		"cap_eff":          c.CapEff,
+		"security_label":   c.SecurityLabel,
+		"unit":             c.Unit,
+		"first_seen":       formatTime(c.FirstSeen),
+		"age":              (time.Duration(c.Age) * time.Second).String(),
+		"socket_unit":      c.SocketUnit,
+		"nat":              "",
+		"nat_laddr":        "",
+		"nat_lport":        "",
+		"nat_raddr":        "",
+		"nat_rport":        "",
+		"ct_zone":          "",
+		"ts":               c.TS.Format("2006-01-02T15:04:05.000Z07:00"),
 	}
+
+	// the nat view of the flow, with --conntrack
+	if ct := c.Conntrack; ct != nil {
+		fields["nat"] = ct.NAT
+		fields["nat_laddr"] = ct.NATLaddr
+		fields["nat_lport"] = strconv.Itoa(ct.NATLport)
+		fields["nat_raddr"] = ct.NATRaddr
+		fields["nat_rport"] = strconv.Itoa(ct.NATRport)
+		fields["ct_zone"] = strconv.Itoa(int(ct.Zone))
+	}
+	return fields
+}
+
+// formatHolders lists the processes holding a socket as pid:fd pairs
+func formatHolders(holders []collector.Holder) string {
+	parts := make([]string, len(holders))
+	for i, h := range holders {
+		parts[i] = fmt.Sprintf("%d:%d", h.PID, h.FD)
+	}
+	return strings.Join(parts, ",")
 }
 
-func printJSON(conns []collector.Connection) {
-	jsonOutput, err := json.MarshalIndent(conns, "", "  ")
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func printJSON(v any) {
+	jsonOutput, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		log.Fatalf("Error marshaling to JSON: %v", err)
 	}
@@ -285,7 +378,7 @@ func printPlainTable(conns []collector.Connection, headers bool, timestamp bool,
 	}
 }
 
-func printStyledTable(conns []collector.Connection, headers bool, selectedFields []string) {
+func printStyledTable(conns []collector.Connection, headers bool, selectedFields []string, warnings []collector.Warning) {
 	if len(selectedFields) == 0 {
 		selectedFields = []string{"process", "pid", "proto", "state", "laddr", "lport", "raddr", "rport"}
 	}
@@ -321,6 +414,7 @@ func printStyledTable(conns []collector.Connection, headers bool, selectedFields
 	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("15"))
 	processStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("15"))
 	faintStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	warningStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("178"))
 
 	// build top border
 	output.WriteString("\n")
@@ -413,8 +507,13 @@ func printStyledTable(conns []collector.Connection, headers bool, selectedFields
 	output.WriteString(borderStyle.Render("╯"))
 	output.WriteString("\n")
 
-	// summary
-	output.WriteString(faintStyle.Render(fmt.Sprintf("  %d connections\n", len(conns))))
+	// summary, followed by what the collector could not see
+	if summary := collector.SummarizeWarnings(warnings); summary != "" {
+		output.WriteString(faintStyle.Render(fmt.Sprintf("  %d connections", len(conns))) + "\n")
+		output.WriteString(warningStyle.Render("  warning: "+summary) + "\n")
+	} else {
+		output.WriteString(faintStyle.Render(fmt.Sprintf("  %d connections\n", len(conns))))
+	}
 	output.WriteString("\n")
 
 	// output with pager if needed
@@ -473,6 +572,7 @@ func init() {
 	lsCmd.Flags().StringVarP(&fields, "fields", "f", strings.Join(cfg.Defaults.Fields, ","), "Comma-separated list of fields to show")
 	lsCmd.Flags().StringVar(&colorMode, "color", cfg.Defaults.Color, "Color mode (auto, always, never)")
 	lsCmd.Flags().BoolVarP(&plainOutput, "plain", "p", false, "Plain output (parsable, no styling)")
+	lsCmd.Flags().BoolVar(&jsonEnvelope, "envelope", false, "Wrap json output in an object with connections and warnings arrays")
 
 	// shared flags
 	addFilterFlags(lsCmd)
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 
@@ -139,6 +140,53 @@ func TestLsCommand_JSONOutput(t *testing.T) {
 	}
 }
 
+// warningMock is a mock collector that reports permission warnings
+type warningMock struct {
+	*collector.MockCollector
+}
+
+func (warningMock) Warnings() []collector.Warning {
+	return []collector.Warning{{Kind: collector.WarningUnreadablePIDs, Count: 3, Message: "3 processes could not be inspected"}}
+}
+
+func TestLsCommand_JSONEnvelope(t *testing.T) {
+	_, cleanup := testutil.SetupTestEnvironment(t)
+	defer cleanup()
+
+	originalCollector := collector.GetCollector()
+	defer collector.SetCollector(originalCollector)
+	collector.SetCollector(warningMock{testutil.NewTestCollectorWithFixture("single-tcp").MockCollector})
+
+	originalColorMode := colorMode
+	jsonEnvelope, colorMode = true, "never"
+	defer func() { jsonEnvelope, colorMode = false, originalColorMode }()
+
+	capture := testutil.NewOutputCapture(t)
+	capture.Start()
+	runListCommand("json", []string{})
+	stdout, stderr, err := capture.Stop()
+	if err != nil {
+		t.Fatalf("Failed to capture output: %v", err)
+	}
+	if stderr != "" {
+		t.Errorf("expected the warnings in the json rather than on stderr, got: %s", stderr)
+	}
+
+	var out struct {
+		Connections []collector.Connection `json:"connections"`
+		Warnings    []collector.Warning    `json:"warnings"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		t.Fatalf("expected a json object, got %v: %s", err, stdout)
+	}
+	if len(out.Connections) != 1 {
+		t.Errorf("expected 1 connection, got %d", len(out.Connections))
+	}
+	if len(out.Warnings) != 1 || out.Warnings[0].Kind != collector.WarningUnreadablePIDs || out.Warnings[0].Count != 3 {
+		t.Errorf("unexpected warnings %+v", out.Warnings)
+	}
+}
+
 func TestLsCommand_Filtering(t *testing.T) {
 	_, cleanup := testutil.SetupTestEnvironment(t)
 	defer cleanup()
@@ -271,3 +319,21 @@ func TestParseFilters(t *testing.T) {
 		})
 	}
 }
+
+func TestGetFieldMap_Holders(t *testing.T) {
+	conn := collector.Connection{
+		PID:     100,
+		Process: "nginx",
+		Holders: []collector.Holder{
+			{PID: 100, FD: 6, Process: "nginx"},
+			{PID: 101, FD: 6, Process: "nginx"},
+		},
+	}
+
+	if got := getFieldMap(conn)["holders"]; got != "100:6,101:6" {
+		t.Errorf("holders = %q, want %q", got, "100:6,101:6")
+	}
+	if got := getFieldMap(collector.Connection{})["holders"]; got != "" {
+		t.Errorf("expected no holders for an unattributed socket, got %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/karol-broda/snitch/internal/collector"
+)
+
+// newRemoteCollector connects to the agent named by --remote. the unix,
+// netlink and conntrack sockets the flags and filters ask for are asked of
+// the agent; the filters themselves are pushed down by FetchConnections.
+func newRemoteCollector(args []string) (*collector.RemoteCollector, error) {
+	token, err := readToken(remoteTokenFile, "SNITCH_REMOTE_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	if remoteCA != "" {
+		pem, err := os.ReadFile(remoteCA)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", remoteCA)
+		}
+		tlsConfig = &tls.Config{RootCAs: roots}
+	}
+
+	opts := collectorOptions(args)
+	return collector.NewRemoteCollector(remoteAddr, collector.RemoteOptions{
+		Token:          token,
+		TLSConfig:      tlsConfig,
+		IncludeUnix:    opts.IncludeUnix,
+		IncludeNetlink: opts.IncludeNetlink,
+		Conntrack:      opts.Conntrack,
+	})
+}
+
+// readToken reads a token from path, or from the environment variable env
+// when no path is given. tokens are kept out of flags so that they do not
+// show up in the process list.
+func readToken(path, env string) (string, error) {
+	if path == "" {
+		return os.Getenv(env), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -4,13 +4,29 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/karol-broda/snitch/internal/collector"
 	"github.com/karol-broda/snitch/internal/config"
 	"github.com/spf13/cobra"
 )
 
 
 var (
-	cfgFile string
+	cfgFile          string
+	collectorBackend string
+	procRoot         string
+	allNetns         bool
+	includeUnix      bool
+	conntrack        bool
+	fromSnapshot     string
+	remoteAddr       string
+	remoteTokenFile  string
+	remoteCA         string
+
+	// the snapshot replayed instead of the live host, with --from-snapshot
+	replayed *collector.Snapshot
+
+	// the agent read instead of the live host, with --remote
+	remote *collector.RemoteCollector
 )
 
 var rootCmd = &cobra.Command{
@@ -23,6 +39,31 @@ A modern, unix-y tool for inspecting network connections, with a focus on a clea
 		if _, err := config.Load(); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: Error loading config: %v\n", err)
 		}
+		if fromSnapshot != "" && remoteAddr != "" {
+			fmt.Fprintln(os.Stderr, "Error: --from-snapshot and --remote cannot be combined")
+			os.Exit(1)
+		}
+		if fromSnapshot != "" {
+			snapshot, err := collector.LoadSnapshot(fromSnapshot)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			replayed = snapshot
+			collector.SetCollector(collector.NewSnapshotCollector(snapshot))
+			return
+		}
+		if remoteAddr != "" {
+			rc, err := newRemoteCollector(args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			remote = rc
+			collector.SetCollector(rc)
+			return
+		}
+		collector.SetCollector(collector.NewDefaultCollector(collectorOptions(args)))
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		// default to top - flags are shared so they work here too
@@ -31,8 +72,13 @@ A modern, unix-y tool for inspecting network connections, with a focus on a clea
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil {
 		fmt.Println(err)
+		if cmd == diffCmd {
+			// 1 means the sides differ
+			os.Exit(diffExitTrouble)
+		}
 		os.Exit(1)
 	}
 }
@@ -41,12 +87,41 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/snitch/snitch.toml)")
 	rootCmd.PersistentFlags().Bool("debug", false, "enable debug logs to stderr")
 
-	// add top's flags to root so `snitch -l` works (defaults to top command)
 	cfg := config.Get()
+	rootCmd.PersistentFlags().StringVar(&collectorBackend, "backend", cfg.Collector.Backend, "Socket collection backend on linux (netlink, proc)")
+	rootCmd.PersistentFlags().StringVar(&procRoot, "proc-root", cfg.Collector.ProcRoot, "Path of the proc filesystem to read on linux")
+	rootCmd.PersistentFlags().BoolVar(&allNetns, "all-netns", cfg.Collector.AllNetns, "Collect sockets from every network namespace on linux")
+	rootCmd.PersistentFlags().BoolVar(&includeUnix, "unix", cfg.Collector.Unix, "Include unix domain sockets on linux (also enabled by proto=unix)")
+	rootCmd.PersistentFlags().StringVar(&fromSnapshot, "from-snapshot", "", "Read connections from a file saved with 'snitch snapshot save' instead of this host")
+	rootCmd.PersistentFlags().StringVar(&remoteAddr, "remote", "", "Read connections from a snitch agent (host:port or an https:// url) instead of this host")
+	rootCmd.PersistentFlags().StringVar(&remoteTokenFile, "remote-token-file", "", "File holding the token of the --remote agent (default $SNITCH_REMOTE_TOKEN)")
+	rootCmd.PersistentFlags().StringVar(&remoteCA, "remote-ca", "", "CA certificate to verify an https --remote agent with, instead of the system roots")
+	rootCmd.PersistentFlags().BoolVar(&conntrack, "conntrack", cfg.Collector.Conntrack, "Join the netfilter conntrack table on linux for nat tuples and marks (also enabled by mark=)")
+
+	// add top's flags to root so `snitch -l` works (defaults to top command)
 	rootCmd.Flags().StringVar(&topTheme, "theme", cfg.Defaults.Theme, "Theme for TUI (see 'snitch themes')")
 	rootCmd.Flags().DurationVarP(&topInterval, "interval", "i", 0, "Refresh interval (default 1s)")
 
 	// shared flags for root command
 	addFilterFlags(rootCmd)
 	addResolutionFlags(rootCmd)
-}
\ No newline at end of file
+}
+
+// collectorOptions builds the collector configuration from global flags.
+// unix and netlink sockets are also collected when the filter args ask for
+// them, and conntrack is read when they filter on the mark it provides.
+func collectorOptions(args []string) collector.Options {
+	opts := collector.Options{
+		Backend:       collectorBackend,
+		ProcRoot:      procRoot,
+		AllNamespaces: allNetns,
+		IncludeUnix:   includeUnix,
+		Conntrack:     conntrack,
+	}
+	if filters, err := ParseFilterArgs(args); err == nil {
+		opts.IncludeUnix = opts.IncludeUnix || filters.WantsProto("unix")
+		opts.IncludeNetlink = filters.WantsProto("netlink")
+		opts.Conntrack = opts.Conntrack || filters.Mark != ""
+	}
+	return opts
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/karol-broda/snitch/internal/resolver"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -21,6 +22,9 @@ type Runtime struct {
 	// filtered connections ready for rendering
 	Connections []collector.Connection
 
+	// what the collector could not see, e.g. without root
+	Warnings []collector.Warning
+
 	// common settings
 	ColorMode    string
 	ResolveAddrs bool
@@ -73,14 +77,10 @@ func BuildFilters(args []string) (collector.FilterOptions, error) {
 	return filters, nil
 }
 
-// FetchConnections gets connections from the collector and applies filters.
+// FetchConnections gets connections from the collector and applies filters,
+// on the remote host when the collector is one.
 func FetchConnections(filters collector.FilterOptions) ([]collector.Connection, error) {
-	connections, err := collector.GetConnections()
-	if err != nil {
-		return nil, err
-	}
-
-	return collector.FilterConnections(connections, filters), nil
+	return collector.GetFilteredConnections(filters)
 }
 
 // NewRuntime creates a runtime with fetched and filtered connections.
@@ -106,6 +106,7 @@ func NewRuntime(args []string, colorMode string) (*Runtime, error) {
 	rt := &Runtime{
 		Filters:      filters,
 		Connections:  connections,
+		Warnings:     collector.GetWarnings(),
 		ColorMode:    colorMode,
 		ResolveAddrs: resolveAddrs,
 		ResolvePorts: resolvePorts,
@@ -139,18 +140,70 @@ func (r *Runtime) SortConnections(opts collector.SortOptions) {
 func ParseFilterArgs(args []string) (collector.FilterOptions, error) {
 	filters := collector.FilterOptions{}
 	for _, arg := range args {
-		parts := strings.SplitN(arg, "=", 2)
-		if len(parts) != 2 {
+		key, op, value, ok := splitFilterArg(arg)
+		if !ok {
 			return filters, fmt.Errorf("invalid filter format: %s (expected key=value)", arg)
 		}
-		key, value := parts[0], parts[1]
-		if err := applyFilter(&filters, key, value); err != nil {
+		var err error
+		if op == "=" {
+			err = applyFilter(&filters, key, value)
+		} else {
+			err = applyComparison(&filters, key, op, value)
+		}
+		if err != nil {
 			return filters, err
 		}
 	}
 	return filters, nil
 }
 
+// splitFilterArg splits "key=value" or a comparison like "sendq>0" at the
+// first operator.
+func splitFilterArg(arg string) (key, op, value string, ok bool) {
+	i := strings.IndexAny(arg, "=!<>")
+	if i <= 0 {
+		return "", "", "", false
+	}
+	op = arg[i : i+1]
+	if i+1 < len(arg) && arg[i+1] == '=' && op != "=" {
+		op += "="
+	}
+	if op == "!" {
+		return "", "", "", false
+	}
+	return arg[:i], op, arg[i+len(op):], true
+}
+
+// applyComparison applies a numeric comparison filter such as sendq>0.
+func applyComparison(filters *collector.FilterOptions, key, op, value string) error {
+	if strings.EqualFold(key, "age") {
+		// ages are durations, compared in whole seconds
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid age filter: %w", err)
+		}
+		value = strconv.FormatInt(int64(d/time.Second), 10)
+	}
+
+	nf, err := collector.ParseNumericFilter(op, value)
+	if err != nil {
+		return fmt.Errorf("invalid %s filter: %w", key, err)
+	}
+	switch strings.ToLower(key) {
+	case "sendq":
+		filters.SendQ = nf
+	case "recvq":
+		filters.RecvQ = nf
+	case "retransmits":
+		filters.Retransmits = nf
+	case "age":
+		filters.Age = nf
+	default:
+		return fmt.Errorf("filter %s does not support %s", key, op)
+	}
+	return nil
+}
+
 // applyFilter applies a single key=value filter to FilterOptions.
 func applyFilter(filters *collector.FilterOptions, key, value string) error {
 	switch strings.ToLower(key) {
@@ -166,6 +219,16 @@ func applyFilter(filters *collector.FilterOptions, key, value string) error {
 		filters.Pid = pid
 	case "proc":
 		filters.Proc = value
+	case "ppid":
+		ppid, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid ppid value: %s", value)
+		}
+		filters.PPID = ppid
+	case "exe":
+		filters.Exe = value
+	case "unit":
+		filters.Unit = value
 	case "lport":
 		port, err := strconv.Atoi(value)
 		if err != nil {
@@ -203,6 +266,14 @@ func applyFilter(filters *collector.FilterOptions, key, value string) error {
 			return fmt.Errorf("invalid inode value: %s", value)
 		}
 		filters.Inode = inode
+	case "sendq", "recvq", "retransmits", "age":
+		return applyComparison(filters, key, "=", value)
+	case "timer":
+		filters.Timer = value
+	case "container":
+		filters.Container = value
+	case "pod":
+		filters.Pod = value
 	case "since":
 		since, sinceRel, err := collector.ParseTimeFilter(value)
 		if err != nil {
@@ -216,13 +287,23 @@ func applyFilter(filters *collector.FilterOptions, key, value string) error {
 	return nil
 }
 
+// filterKeysHelp lists the filter keys in the help of every command that
+// takes filters
+const filterKeysHelp = `Available filters:
+  proto, state, pid, proc, lport, rport, user, laddr, raddr, contains, if,
+  mark, namespace, inode, since, timer, sendq, recvq, retransmits,
+  container, pod, ppid, exe, unit, age
+`
+
 // FilterFlagsHelp returns the help text for common filter flags.
 const FilterFlagsHelp = `
 Filters are specified in key=value format. For example:
   snitch ls proto=tcp state=established
 
-Available filters:
-  proto, state, pid, proc, lport, rport, user, laddr, raddr, contains, if, mark, namespace, inode, since`
+` + filterKeysHelp + `
+Numeric filters also accept comparisons, for example sendq>0 or retransmits>=3.
+age takes a duration, e.g. age>1h for long-lived connections; since=5m
+keeps the ones that appeared in the last five minutes.`
 
 // addFilterFlags adds the common filter flags to a command.
 func addFilterFlags(cmd *cobra.Command) {
@@ -233,6 +233,43 @@ func TestParseFilterArgs_UnknownKey(t *testing.T) {
 	}
 }
 
+func TestParseFilterArgs_Comparisons(t *testing.T) {
+	filters, err := ParseFilterArgs([]string{"sendq>0", "recvq<=10", "retransmits!=0", "timer=keepalive"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filters.SendQ != (collector.NumericFilter{Op: ">", Value: 0}) {
+		t.Errorf("expected sendq>0, got %+v", filters.SendQ)
+	}
+	if filters.RecvQ != (collector.NumericFilter{Op: "<=", Value: 10}) {
+		t.Errorf("expected recvq<=10, got %+v", filters.RecvQ)
+	}
+	if filters.Retransmits != (collector.NumericFilter{Op: "!=", Value: 0}) {
+		t.Errorf("expected retransmits!=0, got %+v", filters.Retransmits)
+	}
+	if filters.Timer != "keepalive" {
+		t.Errorf("expected timer 'keepalive', got %q", filters.Timer)
+	}
+}
+
+func TestParseFilterArgs_Age(t *testing.T) {
+	filters, err := ParseFilterArgs([]string{"age>1h30m"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filters.Age != (collector.NumericFilter{Op: ">", Value: 5400}) {
+		t.Errorf("expected age>5400s, got %+v", filters.Age)
+	}
+}
+
+func TestParseFilterArgs_InvalidComparison(t *testing.T) {
+	for _, arg := range []string{"proc>nginx", "sendq>abc", "sendq!5", "age>5"} {
+		if _, err := ParseFilterArgs([]string{arg}); err == nil {
+			t.Errorf("expected error for %q", arg)
+		}
+	}
+}
+
 func TestParseFilterArgs_CaseInsensitiveKeys(t *testing.T) {
 	filters, err := ParseFilterArgs([]string{"PROTO=tcp", "State=LISTEN"})
 	if err != nil {
@@ -509,6 +546,16 @@ func TestApplyFilter_AllKeys(t *testing.T) {
 				t.Errorf("inode: expected 12345, got %d", f.Inode)
 			}
 		}},
+		{"container", "3f4e5d6c", func(t *testing.T, f *collector.FilterOptions) {
+			if f.Container != "3f4e5d6c" {
+				t.Errorf("container: expected '3f4e5d6c', got %q", f.Container)
+			}
+		}},
+		{"pod", "0f1e2d3c", func(t *testing.T, f *collector.FilterOptions) {
+			if f.Pod != "0f1e2d3c" {
+				t.Errorf("pod: expected '0f1e2d3c', got %q", f.Pod)
+			}
+		}},
 	}
 
 	for _, tt := range tests {
@@ -523,3 +570,14 @@ func TestApplyFilter_AllKeys(t *testing.T) {
 	}
 }
 
+func TestCollectorOptions_UnixFromFilter(t *testing.T) {
+	if collectorOptions(nil).IncludeUnix {
+		t.Error("unix sockets should be off without --unix or a filter")
+	}
+	if !collectorOptions([]string{"proto=tcp,unix"}).IncludeUnix {
+		t.Error("proto=tcp,unix should turn on unix sockets")
+	}
+	if opts := collectorOptions([]string{"proto=netlink"}); !opts.IncludeNetlink || opts.IncludeUnix {
+		t.Errorf("proto=netlink should only turn on netlink sockets: %+v", opts)
+	}
+}
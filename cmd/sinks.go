@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/karol-broda/snitch/internal/config"
+	"github.com/karol-broda/snitch/internal/sink"
+)
+
+var sinkSpecs []string
+
+// addSinkFlags adds --sink to commands that stream json events
+func addSinkFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&sinkSpecs, "sink", nil, "Also send events to a sink, type[:target][,key=value...] (file, syslog, journald, webhook, unix; repeatable)")
+}
+
+// openSinks opens the sinks from the config file and the --sink flags. it
+// returns nil, which discards events, when there are none.
+func openSinks() *sink.Fanout {
+	configs := append([]sink.Config(nil), config.Get().Sinks...)
+	for _, spec := range sinkSpecs {
+		c, err := sink.Parse(spec)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		configs = append(configs, c)
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+
+	sinks, err := sink.OpenAll(configs, os.Stderr)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	return sinks
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/karol-broda/snitch/internal/collector"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save connections to a file to inspect elsewhere",
+	Long: `Save connections to a file to inspect elsewhere.
+
+A snapshot holds the connections of this host with its hostname, kernel,
+the time and the snitch version. Any command that reads connections can
+replay it with --from-snapshot, e.g.:
+  snitch snapshot save web-1.json
+  snitch top --from-snapshot web-1.json
+  snitch ls --from-snapshot web-1.json proto=tcp state=listen
+`,
+}
+
+var snapshotSaveCmd = &cobra.Command{
+	Use:   "save <file> [filters...]",
+	Short: "Save this host's connections, or a --remote host's, to a file (- for stdout)",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runSnapshotSave(args[0], args[1:])
+	},
+}
+
+func runSnapshotSave(path string, args []string) {
+	filters, err := BuildFilters(args)
+	if err != nil {
+		log.Fatalf("Error parsing filters: %v", err)
+	}
+	var snapshot *collector.Snapshot
+	if replayed != nil {
+		// filtering a snapshot keeps where and when it was taken
+		filtered := *replayed
+		filtered.Version = collector.SnapshotVersion
+		filtered.Connections = collector.FilterConnections(replayed.Connections, filters)
+		snapshot = &filtered
+	} else if remote != nil {
+		// the agent describes its own host
+		snapshot, err = remote.Fetch(filters)
+		if err != nil {
+			log.Fatalf("Error getting connections: %v", err)
+		}
+		snapshot.Connections = collector.FilterConnections(snapshot.Connections, filters)
+	} else {
+		// the file name kept the filters from picking the sockets to collect
+		collector.SetCollector(collector.NewDefaultCollector(collectorOptions(args)))
+		snapshot, err = collector.TakeSnapshot(filters, Version)
+		if err != nil {
+			log.Fatalf("Error getting connections: %v", err)
+		}
+	}
+
+	if path == "-" {
+		if err := snapshot.Write(os.Stdout); err != nil {
+			log.Fatalf("Error writing snapshot: %v", err)
+		}
+		return
+	}
+	if err := writeSnapshotFile(path, snapshot); err != nil {
+		log.Fatalf("Error writing snapshot: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "saved %d connections from %s to %s\n", len(snapshot.Connections), snapshot.Describe(), path)
+}
+
+func writeSnapshotFile(path string, snapshot *collector.Snapshot) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := snapshot.Write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotSaveCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
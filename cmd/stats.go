@@ -28,6 +28,7 @@ type StatsData struct {
 	ByState   map[string]int       `json:"by_state"`
 	ByProc    []ProcessStats       `json:"by_proc"`
 	ByIf      []InterfaceStats     `json:"by_if"`
+	Warnings  []collector.Warning  `json:"warnings,omitempty"`
 }
 
 type ProcessStats struct {
@@ -57,9 +58,7 @@ var statsCmd = &cobra.Command{
 Filters are specified in key=value format. For example:
   snitch stats proto=tcp state=listening
 
-Available filters:
-  proto, state, pid, proc, lport, rport, user, laddr, raddr, contains
-`,
+` + filterKeysHelp,
 	Run: func(cmd *cobra.Command, args []string) {
 		runStatsCommand(args)
 	},
@@ -99,8 +98,10 @@ func runStatsCommand(args []string) {
 			printStatsJSON(stats)
 		case "csv":
 			printStatsCSV(stats, !statsNoHeaders && count == 0)
+			printWarnings(os.Stderr, stats.Warnings)
 		default:
 			printStatsTable(stats, !statsNoHeaders && count == 0)
+			printWarnings(os.Stderr, stats.Warnings)
 		}
 
 		count++
@@ -134,6 +135,10 @@ func generateStats(filters collector.FilterOptions) (*StatsData, error) {
 		ByState:   make(map[string]int),
 		ByProc:    make([]ProcessStats, 0),
 		ByIf:      make([]InterfaceStats, 0),
+		Warnings:  collector.GetWarnings(),
+	}
+	if replayed != nil && !replayed.Time.IsZero() {
+		stats.Timestamp = replayed.Time
 	}
 
 	procCounts := make(map[string]ProcessStats)
@@ -161,7 +166,7 @@ func generateStats(filters collector.FilterOptions) (*StatsData, error) {
 			}
 		}
 
-		// Count by interface (placeholder since we don't have interface data yet)
+		// Count by interface
 		if conn.Interface != "" {
 			ifCounts[conn.Interface]++
 		}
@@ -285,6 +290,18 @@ func printStatsTable(stats *StatsData, headers bool) {
 			proc := stats.ByProc[i]
 			errutil.Ignore(fmt.Fprintf(w, "%d\t%s\t%d\n", proc.PID, proc.Process, proc.Count))
 		}
+		errutil.Ignore(fmt.Fprintln(w))
+	}
+
+	// Interface breakdown
+	if len(stats.ByIf) > 0 {
+		if headers {
+			errutil.Ignore(fmt.Fprintln(w, "BY INTERFACE:"))
+			errutil.Ignore(fmt.Fprintln(w, "INTERFACE\tCOUNT"))
+		}
+		for _, iface := range stats.ByIf {
+			errutil.Ignore(fmt.Fprintf(w, "%s\t%d\n", iface.Interface, iface.Count))
+		}
 	}
 }
 
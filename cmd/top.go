@@ -40,6 +40,12 @@ var topCmd = &cobra.Command{
 			NoCache:       effectiveNoCache,
 			RememberState: cfg.TUI.RememberState,
 		}
+		if replayed != nil {
+			opts.Snapshot = replayed.Describe()
+		}
+		if remote != nil {
+			opts.Remote = remote.Addr()
+		}
 
 		// if any filter flag is set, use exclusive mode
 		if filterTCP || filterUDP || filterListen || filterEstab {
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -13,34 +14,79 @@ import (
 
 	"github.com/karol-broda/snitch/internal/collector"
 	"github.com/karol-broda/snitch/internal/config"
+	"github.com/karol-broda/snitch/internal/errutil"
 	"github.com/karol-broda/snitch/internal/resolver"
+	"github.com/karol-broda/snitch/internal/sink"
 
 	"github.com/spf13/cobra"
 )
 
 type TraceEvent struct {
 	Timestamp  time.Time             `json:"ts"`
-	Event      string                `json:"event"` // "opened" or "closed"
+	Event      string                `json:"event"`               // "opened", "closed", "state_changed" or "sent"
+	ID         string                `json:"id"`                  // the connection's id, the same in all its events
+	OldState   string                `json:"old_state,omitempty"` // with state_changed
+	Kind       string                `json:"kind,omitempty"`      // the socket call, with --bpf
 	Connection collector.Connection  `json:"connection"`
+
+	// with closed: how long the connection lived and the last state it was
+	// seen in. its final counters are in Connection.
+	LifetimeMs int64  `json:"lifetime_ms,omitempty"`
+	LastState  string `json:"last_state,omitempty"`
 }
 
+// traceEventPollInterval is how often trace polls for new connections when
+// the kernel reports closes by itself
+const traceEventPollInterval = 250 * time.Millisecond
+
 var (
 	traceInterval     time.Duration
 	traceCount        int
 	traceOutputFormat string
 	traceTimestamp    bool
+	traceBPF          bool
+	traceSummary      bool
+
+	traceExec            string
+	traceOn              []string
+	traceExecConcurrency int
+	traceExecQueue       int
+	traceExecTimeout     time.Duration
+	traceHook            *eventHook
+	traceSinks           *sink.Fanout
 )
 
 var traceCmd = &cobra.Command{
 	Use:   "trace [filters...]",
 	Short: "Print new/closed connections as they happen",
-	Long: `Print new/closed connections as they happen.
+	Long: `Print new/closed connections and state changes as they happen. Every
+event carries the connection's id, the same for all events of a connection.
 	
 Filters are specified in key=value format. For example:
   snitch trace proto=tcp state=established
 
-Available filters:
-  proto, state, pid, proc, lport, rport, user, laddr, raddr, contains
+` + filterKeysHelp + `
+On Linux with CAP_NET_ADMIN, closes are reported by the kernel as they
+happen, with the final byte counters, and new connections are polled for
+every 250ms at most. Without it trace polls every --interval.
+
+With --bpf, connects, accepts, closes and udp sends are traced in the kernel
+with eBPF instead, each with the exact time and the calling process. This
+needs root, and falls back to polling where eBPF is unavailable.
+
+With --summary, only finished connections are printed, each with its
+lifetime, last state, final byte counters and rtt: a flow log per process.
+
+With --exec, a shell command runs for every event, or only for those given
+with --on, e.g.:
+  snitch trace --on opened --exec 'notify-send "$SNITCH_PROCESS -> $SNITCH_RADDR"' proc=curl
+The event is passed as SNITCH_EVENT, SNITCH_PID, SNITCH_RADDR and the other
+fields ls knows, upper-cased, and as json on stdin.
+
+With --sink, events are also written as json to a rotating file, syslog,
+journald, a webhook or a unix socket, e.g.:
+  snitch trace --sink file:/var/log/snitch/trace.ndjson,max_size=100MB,backups=7
+Sinks can also be listed as [[sinks]] in the config file.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		runTraceCommand(args)
@@ -48,6 +94,9 @@ Available filters:
 }
 
 func runTraceCommand(args []string) {
+	if replayed != nil {
+		log.Fatalf("Error: trace follows the live host and cannot replay a snapshot")
+	}
 	cfg := config.Get()
 	
 	// configure resolver with cache setting
@@ -59,6 +108,19 @@ func runTraceCommand(args []string) {
 		log.Fatalf("Error parsing filters: %v", err)
 	}
 
+	if traceExec != "" {
+		traceHook, err = newEventHook(traceExec, traceOn, traceExecConcurrency, traceExecQueue, traceExecTimeout)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		defer traceHook.wait()
+	} else if len(traceOn) > 0 {
+		log.Fatalf("Error: --on needs --exec")
+	}
+
+	traceSinks = openSinks()
+	defer errutil.Close(traceSinks)
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -70,6 +132,15 @@ func runTraceCommand(args []string) {
 		cancel()
 	}()
 
+	if traceBPF {
+		events, err := collector.WatchSocketEvents(ctx)
+		if err == nil {
+			traceSocketEvents(events, filters)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "trace: ebpf unavailable (%v), polling instead\n", err)
+	}
+
 	// Track connections using a key-based approach
 	currentConnections := make(map[string]collector.Connection)
 	
@@ -85,14 +156,46 @@ func runTraceCommand(args []string) {
 		}
 	}
 
-	ticker := time.NewTicker(traceInterval)
+	// on linux the kernel reports sockets as they close, so a connection
+	// that opens and closes between two polls is still seen; opens are
+	// polled for more often to match
+	interval := traceInterval
+	destroyed, err := collector.WatchDestroyed(ctx)
+	if err != nil {
+		if !errors.Is(err, collector.ErrDestroyEventsUnsupported) {
+			fmt.Fprintf(os.Stderr, "trace: kernel close events unavailable (%v), polling every %s\n", err, interval)
+		}
+	} else if interval > traceEventPollInterval {
+		interval = traceEventPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	remnants := make(closedRemnants)
 
 	eventCount := 0
+	emit := func(event TraceEvent) bool {
+		if traceSummary && event.Event != "closed" {
+			return false
+		}
+		handleTraceEvent(event)
+		eventCount++
+		return traceCount > 0 && eventCount >= traceCount
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case conn, ok := <-destroyed:
+			if !ok {
+				destroyed = nil
+				continue
+			}
+			event, ok := traceClosed(currentConnections, remnants, conn, filters)
+			if ok && emit(event) {
+				return
+			}
 		case <-ticker.C:
 			newConnections, err := collector.GetConnections()
 			if err != nil {
@@ -108,47 +211,184 @@ func runTraceCommand(args []string) {
 				key := getConnectionKey(conn)
 				newConnectionsMap[key] = conn
 			}
+			remnants.filter(newConnectionsMap)
 
-			// Find newly opened connections
-			for key, conn := range newConnectionsMap {
-				if _, exists := currentConnections[key]; !exists {
-					event := TraceEvent{
-						Timestamp:  time.Now(),
-						Event:      "opened",
-						Connection: conn,
-					}
-					printTraceEvent(event)
-					eventCount++
-				}
-			}
+			events := diffConnections(currentConnections, newConnectionsMap, time.Now())
 
-			// Find closed connections
-			for key, conn := range currentConnections {
-				if _, exists := newConnectionsMap[key]; !exists {
-					event := TraceEvent{
-						Timestamp:  time.Now(),
-						Event:      "closed",
-						Connection: conn,
-					}
-					printTraceEvent(event)
-					eventCount++
+			// Update current state
+			currentConnections = newConnectionsMap
+
+			for _, event := range events {
+				if emit(event) {
+					return
 				}
 			}
+		}
+	}
+}
 
-			// Update current state
-			currentConnections = newConnectionsMap
+// traceSocketEvents prints the socket calls traced in the kernel. unlike
+// polling it sees every connection, however short-lived, but none that were
+// open before it started.
+func traceSocketEvents(events <-chan collector.SocketEvent, filters collector.FilterOptions) {
+	eventCount := 0
+	opened := make(map[string]time.Time) // connection id -> connect or accept
+	for event := range events {
+		conn := event.Connection
+		if len(collector.FilterConnections([]collector.Connection{conn}, filters)) == 0 {
+			continue
+		}
 
-			if traceCount > 0 && eventCount >= traceCount {
-				return
+		var traceEvent TraceEvent
+		switch event.Kind {
+		case collector.SocketEventClose:
+			if ts, ok := opened[conn.ID]; ok {
+				conn.FirstSeen = ts
+				delete(opened, conn.ID)
 			}
+			traceEvent = newClosedEvent(conn.TS, conn, event.OldState)
+		case collector.SocketEventSend:
+			traceEvent = newTraceEvent(conn.TS, "sent", conn)
+		default:
+			opened[conn.ID] = conn.TS
+			traceEvent = newTraceEvent(conn.TS, "opened", conn)
+		}
+		traceEvent.Kind = event.Kind
+		if traceSummary && traceEvent.Event != "closed" {
+			continue
+		}
+		handleTraceEvent(traceEvent)
+
+		eventCount++
+		if traceCount > 0 && eventCount >= traceCount {
+			return
 		}
 	}
 }
 
+// diffConnections compares two snapshots keyed by connection id and returns
+// the connections opened, closed and changing state in between
+func diffConnections(prev, next map[string]collector.Connection, now time.Time) []TraceEvent {
+	var events []TraceEvent
+	for key, conn := range next {
+		old, exists := prev[key]
+		switch {
+		case !exists:
+			events = append(events, newTraceEvent(now, "opened", conn))
+		case !collector.SameSocket(old, conn):
+			// a new connection on the addresses of one that closed
+			events = append(events, newClosedEvent(now, old, old.State), newTraceEvent(now, "opened", conn))
+		case old.State != conn.State:
+			event := newTraceEvent(now, "state_changed", conn)
+			event.OldState = old.State
+			events = append(events, event)
+		}
+	}
+	for key, conn := range prev {
+		if _, exists := next[key]; !exists {
+			events = append(events, newClosedEvent(now, conn, conn.State))
+		}
+	}
+	return events
+}
+
+func newTraceEvent(ts time.Time, event string, conn collector.Connection) TraceEvent {
+	return TraceEvent{Timestamp: ts, Event: event, ID: conn.ID, Connection: conn}
+}
+
+// newClosedEvent reports a connection that ended at ts, having last been
+// seen in lastState. its lifetime is only known when it was seen opening.
+func newClosedEvent(ts time.Time, conn collector.Connection, lastState string) TraceEvent {
+	event := newTraceEvent(ts, "closed", conn)
+	event.LastState = lastState
+	if !conn.FirstSeen.IsZero() && ts.After(conn.FirstSeen) {
+		event.LifetimeMs = ts.Sub(conn.FirstSeen).Milliseconds()
+	}
+	return event
+}
+
+// traceClosed handles a connection the kernel reported destroyed: it is no
+// longer tracked, and what is left of it is ignored from now on. it returns
+// false for a connection that was not tracked and does not match filters.
+func traceClosed(current map[string]collector.Connection, remnants closedRemnants, conn collector.Connection, filters collector.FilterOptions) (TraceEvent, bool) {
+	closed, lastState := conn, ""
+	if key, found := findByTuple(current, conn); found {
+		known := current[key]
+		closed, lastState = mergeClosed(known, conn), known.State
+		delete(current, key)
+	} else if len(collector.FilterConnections([]collector.Connection{conn}, filters)) == 0 {
+		return TraceEvent{}, false
+	} else {
+		closed.ID = collector.ConnectionID(closed)
+	}
+	remnants[getConnectionKey(closed)] = true
+	return newClosedEvent(conn.TS, closed, lastState), true
+}
+
+// closedRemnants holds the ids of connections the kernel reported closed.
+// after an active close the socket lives on in TIME_WAIT, orphaned and
+// without its inode, and would otherwise be reported opened and closed a
+// second time, without counters.
+type closedRemnants map[string]bool
+
+// filter drops the remnants of closed connections from conns, and forgets
+// connections that are gone or were opened again on the same addresses
+func (r closedRemnants) filter(conns map[string]collector.Connection) {
+	for key := range r {
+		conn, ok := conns[key]
+		switch {
+		case !ok:
+			delete(r, key)
+		case conn.Inode == 0 || conn.State == "TIME_WAIT":
+			delete(conns, key)
+		default:
+			delete(r, key)
+		}
+	}
+}
+
+// findByTuple finds the tracked connection a destroy event belongs to. the
+// event has no process, so only the addresses can be compared.
+func findByTuple(conns map[string]collector.Connection, closed collector.Connection) (string, bool) {
+	for key, c := range conns {
+		if c.Proto == closed.Proto && c.Laddr == closed.Laddr && c.Lport == closed.Lport &&
+			c.Raddr == closed.Raddr && c.Rport == closed.Rport {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// mergeClosed keeps what was known about a connection and adds the final
+// state and counters the kernel reported when destroying it
+func mergeClosed(known, destroyed collector.Connection) collector.Connection {
+	known.TS = destroyed.TS
+	known.State = destroyed.State
+	known.RxBytes = destroyed.RxBytes
+	known.TxBytes = destroyed.TxBytes
+	known.RttMs = destroyed.RttMs
+	known.Cwnd = destroyed.Cwnd
+	known.TotalRetrans = destroyed.TotalRetrans
+	known.DeliveryRate = destroyed.DeliveryRate
+	return known
+}
+
 func getConnectionKey(conn collector.Connection) string {
-	// Create a unique key for a connection based on protocol, addresses, ports, and PID
-	// This helps identify the same logical connection across snapshots
-	return fmt.Sprintf("%s|%s:%d|%s:%d|%d", conn.Proto, conn.Laddr, conn.Lport, conn.Raddr, conn.Rport, conn.PID)
+	// the addresses, and the inode of sockets that can share them, identify a
+	// connection across snapshots, whatever its state or the process it is
+	// attributed to
+	if conn.ID != "" {
+		return conn.ID
+	}
+	return collector.ConnectionID(conn)
+}
+
+// handleTraceEvent prints an event, sends it to the sinks and runs the
+// --exec command for it
+func handleTraceEvent(event TraceEvent) {
+	printTraceEvent(event)
+	traceSinks.Send(event)
+	traceHook.run(event)
 }
 
 func printTraceEvent(event TraceEvent) {
@@ -178,8 +418,13 @@ func printTraceEventHuman(event TraceEvent) {
 	}
 
 	eventIcon := "+"
-	if event.Event == "closed" {
+	switch event.Event {
+	case "closed":
 		eventIcon = "-"
+	case "sent":
+		eventIcon = ">"
+	case "state_changed":
+		eventIcon = "~"
 	}
 
 	laddr := conn.Laddr
@@ -223,20 +468,61 @@ func printTraceEventHuman(event TraceEvent) {
 	if state == "" {
 		state = "UNKNOWN"
 	}
+	switch {
+	case event.Event == "closed" && event.LastState != "":
+		state = event.LastState
+	case event.Kind != "":
+		state = event.Kind
+	case event.Event == "state_changed":
+		state = event.OldState + "->" + state
+	}
 
-	fmt.Printf("%s%s %s %s %s%s\n", timestamp, eventIcon, protocol, state, connStr, process)
+	summary := ""
+	if event.Event == "closed" {
+		summary = closedSummary(event)
+	}
+
+	fmt.Printf("%s%s %s %s %s%s%s\n", timestamp, eventIcon, protocol, state, connStr, process, summary)
+}
+
+// closedSummary renders how a connection ended: its lifetime and its final
+// counters, where known
+func closedSummary(event TraceEvent) string {
+	conn := event.Connection
+	var parts []string
+	if event.LifetimeMs > 0 {
+		parts = append(parts, (time.Duration(event.LifetimeMs) * time.Millisecond).String())
+	}
+	if conn.RxBytes > 0 || conn.TxBytes > 0 {
+		parts = append(parts, fmt.Sprintf("rx=%d tx=%d", conn.RxBytes, conn.TxBytes))
+	}
+	if conn.RttMs > 0 {
+		parts = append(parts, fmt.Sprintf("rtt=%.3gms", conn.RttMs))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, " ")
 }
 
 func init() {
 	rootCmd.AddCommand(traceCmd)
 
 	// trace-specific flags
-	traceCmd.Flags().DurationVarP(&traceInterval, "interval", "i", time.Second, "Polling interval (e.g., 500ms, 2s)")
+	traceCmd.Flags().DurationVarP(&traceInterval, "interval", "i", time.Second, "Polling interval (e.g., 500ms, 2s), capped at 250ms when kernel close events are available")
 	traceCmd.Flags().IntVarP(&traceCount, "count", "c", 0, "Number of events to capture (0 = unlimited)")
 	traceCmd.Flags().StringVarP(&traceOutputFormat, "output", "o", "human", "Output format (human, json)")
 	traceCmd.Flags().BoolVar(&traceTimestamp, "ts", false, "Include timestamp in output")
+	traceCmd.Flags().BoolVar(&traceBPF, "bpf", false, "Trace socket calls in the kernel with eBPF (linux, needs root)")
+	traceCmd.Flags().BoolVar(&traceSummary, "summary", false, "Print one line per finished connection instead of every event")
+	traceCmd.Flags().StringVar(&traceExec, "exec", "", "Run a shell command for each event, with SNITCH_* variables and the event as json on stdin")
+	traceCmd.Flags().StringSliceVar(&traceOn, "on", nil, "Events to run --exec for (opened, closed, state_changed, sent; default all)")
+	traceCmd.Flags().IntVar(&traceExecConcurrency, "exec-concurrency", 4, "Maximum number of --exec commands running at once")
+	traceCmd.Flags().IntVar(&traceExecQueue, "exec-queue", 1000, "Maximum number of events waiting for a free --exec slot; more are dropped")
+	traceCmd.Flags().DurationVar(&traceExecTimeout, "exec-timeout", 10*time.Second, "Kill an --exec command after this long (0 = never)")
 
 	// shared flags
 	addFilterFlags(traceCmd)
+	addSinkFlags(traceCmd)
 	addResolutionFlags(traceCmd)
 }
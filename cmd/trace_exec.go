@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// traceEventNames are the values --on accepts
+var traceEventNames = []string{"opened", "closed", "state_changed", "sent"}
+
+// eventHook runs a shell command for trace events. the event is passed as
+// SNITCH_* environment variables and as json on stdin.
+type eventHook struct {
+	command string
+	on      map[string]bool // empty for every event
+	timeout time.Duration
+	queue   chan hookJob
+	wg      sync.WaitGroup
+	errw    io.Writer
+
+	// events dropped since the queue last had room, counted by run
+	dropped int
+}
+
+// hookJob is one command waiting for a free slot
+type hookJob struct {
+	event   TraceEvent
+	payload []byte
+	env     []string
+}
+
+func newEventHook(command string, on []string, concurrency, queue int, timeout time.Duration) (*eventHook, error) {
+	if concurrency < 1 {
+		return nil, fmt.Errorf("--exec-concurrency must be at least 1")
+	}
+	if queue < 0 {
+		return nil, fmt.Errorf("--exec-queue cannot be negative")
+	}
+
+	h := &eventHook{
+		command: command,
+		on:      make(map[string]bool),
+		timeout: timeout,
+		queue:   make(chan hookJob, queue),
+		errw:    os.Stderr,
+	}
+	for _, name := range on {
+		name = strings.TrimSpace(name)
+		valid := false
+		for _, known := range traceEventNames {
+			valid = valid || name == known
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid --on event %q (expected %s)", name, strings.Join(traceEventNames, ", "))
+		}
+		h.on[name] = true
+	}
+
+	h.wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer h.wg.Done()
+			for job := range h.queue {
+				h.exec(job)
+			}
+		}()
+	}
+	return h, nil
+}
+
+// run queues the command for event, if selected. it never blocks: trace
+// has to keep reading events, or the kernel drops them instead. when every
+// command is busy and the queue is full the event is dropped, and the drops
+// are reported on stderr.
+func (h *eventHook) run(event TraceEvent) {
+	if h == nil || (len(h.on) > 0 && !h.on[event.Event]) {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		_, _ = fmt.Fprintf(h.errw, "trace: exec: %v\n", err)
+		return
+	}
+	job := hookJob{event: event, payload: payload, env: append(os.Environ(), hookEnv(event)...)}
+
+	select {
+	case h.queue <- job:
+		h.reportDropped()
+	default:
+		if h.dropped == 0 {
+			_, _ = fmt.Fprintf(h.errw, "trace: exec: %d commands queued, dropping events\n", cap(h.queue))
+		}
+		h.dropped++
+	}
+}
+
+// reportDropped says how many events were dropped, once the queue has room
+// again or trace ends
+func (h *eventHook) reportDropped() {
+	if h.dropped > 0 {
+		_, _ = fmt.Fprintf(h.errw, "trace: exec: dropped %d events\n", h.dropped)
+		h.dropped = 0
+	}
+}
+
+func (h *eventHook) exec(job hookJob) {
+	ctx := context.Background()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.command)
+	// kill the whole process group on timeout, not just the shell
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.Env = job.env
+	cmd.Stdin = bytes.NewReader(append(job.payload, '\n'))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", h.timeout)
+		}
+		_, _ = fmt.Fprintf(h.errw, "trace: exec for %s event %s: %v\n", job.event.Event, job.event.ID, err)
+	}
+}
+
+// wait runs the queued commands and blocks until they have finished. the
+// hook cannot be used afterwards.
+func (h *eventHook) wait() {
+	if h == nil {
+		return
+	}
+	close(h.queue)
+	h.wg.Wait()
+	h.reportDropped()
+}
+
+// hookEnv turns an event into SNITCH_* variables: SNITCH_EVENT and the other
+// event fields, then every field ls knows, by its upper-cased name. addresses
+// and ports are always numeric.
+func hookEnv(event TraceEvent) []string {
+	c := event.Connection
+	fields := getFieldMap(c)
+	fields["laddr"] = c.Laddr
+	fields["raddr"] = c.Raddr
+	fields["lport"] = strconv.Itoa(c.Lport)
+	fields["rport"] = strconv.Itoa(c.Rport)
+
+	fields["event"] = event.Event
+	fields["id"] = event.ID
+	fields["ts"] = event.Timestamp.Format(time.RFC3339Nano)
+	fields["kind"] = event.Kind
+	fields["old_state"] = event.OldState
+	fields["last_state"] = event.LastState
+	fields["lifetime_ms"] = strconv.FormatInt(event.LifetimeMs, 10)
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]string, len(names))
+	for i, name := range names {
+		env[i] = "SNITCH_" + strings.ToUpper(name) + "=" + fields[name]
+	}
+	return env
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karol-broda/snitch/internal/collector"
+)
+
+func TestDiffConnections(t *testing.T) {
+	snapshot := func(conns ...collector.Connection) map[string]collector.Connection {
+		m := make(map[string]collector.Connection)
+		for _, c := range conns {
+			c.ID = collector.ConnectionID(c)
+			m[getConnectionKey(c)] = c
+		}
+		return m
+	}
+
+	web := collector.Connection{Proto: "tcp", State: "ESTABLISHED", Laddr: "10.0.0.1", Lport: 443, Raddr: "10.0.0.2", Rport: 50000, Inode: 100, PID: 10}
+	closing := web
+	closing.State = "CLOSE_WAIT"
+	// the socket passed to a worker keeps its identity
+	handedOff := closing
+	handedOff.PID = 11
+	gone := collector.Connection{Proto: "udp", State: "UNCONN", Laddr: "*", Lport: 53, Inode: 200}
+	opened := collector.Connection{Proto: "tcp", State: "LISTEN", Laddr: "*", Lport: 8080, Inode: 300}
+
+	events := diffConnections(snapshot(web, gone), snapshot(handedOff, opened), time.Now())
+
+	byEvent := make(map[string]TraceEvent)
+	for _, e := range events {
+		if _, dup := byEvent[e.Event]; dup {
+			t.Fatalf("more than one %s event: %+v", e.Event, events)
+		}
+		byEvent[e.Event] = e
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected opened, closed and state_changed, got %+v", events)
+	}
+
+	changed := byEvent["state_changed"]
+	if changed.OldState != "ESTABLISHED" || changed.Connection.State != "CLOSE_WAIT" {
+		t.Errorf("unexpected state change %s -> %s", changed.OldState, changed.Connection.State)
+	}
+	if changed.ID == "" || changed.ID != collector.ConnectionID(web) {
+		t.Errorf("expected the state change to keep the connection id, got %q", changed.ID)
+	}
+	if byEvent["opened"].Connection.Lport != 8080 || byEvent["closed"].Connection.Lport != 53 {
+		t.Errorf("unexpected opened/closed events: %+v", events)
+	}
+	if byEvent["closed"].LastState != "UNCONN" {
+		t.Errorf("expected the closed event to carry the last state, got %q", byEvent["closed"].LastState)
+	}
+
+	// an active close orphans the socket, and the kernel clears its inode
+	client := collector.Connection{Proto: "tcp", State: "ESTABLISHED", Laddr: "10.0.0.1", Lport: 40000, Raddr: "10.0.0.3", Rport: 443, Inode: 400, PID: 10}
+	timeWait := client
+	timeWait.State, timeWait.Inode, timeWait.PID = "TIME_WAIT", 0, 0
+
+	events = diffConnections(snapshot(client), snapshot(timeWait), time.Now())
+	if len(events) != 1 || events[0].Event != "state_changed" || events[0].OldState != "ESTABLISHED" || events[0].ID != collector.ConnectionID(client) {
+		t.Errorf("expected the orphaned socket to change state under the same id, got %+v", events)
+	}
+
+	// a new connection reusing the addresses has another inode
+	reused := client
+	reused.Inode = 401
+	events = diffConnections(snapshot(client), snapshot(reused), time.Now())
+	if len(events) != 2 {
+		t.Errorf("expected the old connection closed and the new one opened, got %+v", events)
+	}
+}
+
+func TestClosedSummary(t *testing.T) {
+	end := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	conn := collector.Connection{
+		Proto:     "tcp",
+		State:     "CLOSE",
+		FirstSeen: end.Add(-90 * time.Second),
+		RxBytes:   512,
+		TxBytes:   87,
+		RttMs:     1.25,
+	}
+
+	event := newClosedEvent(end, conn, "ESTABLISHED")
+	if event.LifetimeMs != 90000 || event.LastState != "ESTABLISHED" {
+		t.Errorf("unexpected lifetime %dms / last state %q", event.LifetimeMs, event.LastState)
+	}
+	if got, want := closedSummary(event), " 1m30s rx=512 tx=87 rtt=1.25ms"; got != want {
+		t.Errorf("closedSummary() = %q, want %q", got, want)
+	}
+
+	// never seen opening: no lifetime to report
+	conn.FirstSeen = time.Time{}
+	conn.RxBytes, conn.TxBytes, conn.RttMs = 0, 0, 0
+	if event := newClosedEvent(end, conn, ""); event.LifetimeMs != 0 || closedSummary(event) != "" {
+		t.Errorf("expected an empty summary, got %+v", event)
+	}
+}
+
+func TestTraceClosedRemnant(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	poll := func(conns ...collector.Connection) map[string]collector.Connection {
+		m := make(map[string]collector.Connection)
+		for _, c := range conns {
+			c.ID = collector.ConnectionID(c)
+			m[getConnectionKey(c)] = c
+		}
+		return m
+	}
+
+	client := collector.Connection{Proto: "tcp", State: "ESTABLISHED", Laddr: "10.0.0.1", Lport: 40000, Raddr: "10.0.0.3", Rport: 443, Inode: 400, FirstSeen: start}
+	current := poll(client)
+	remnants := make(closedRemnants)
+
+	// the client closes: the kernel destroys the socket with its counters and
+	// keeps an orphaned TIME_WAIT socket on the same addresses
+	destroyed := client
+	destroyed.TS, destroyed.State, destroyed.Inode, destroyed.TxBytes = start.Add(time.Minute), "FIN_WAIT1", 0, 87
+	timeWait := client
+	timeWait.State, timeWait.Inode = "TIME_WAIT", 0
+
+	var closed []TraceEvent
+	if event, ok := traceClosed(current, remnants, destroyed, collector.FilterOptions{}); ok {
+		closed = append(closed, event)
+	}
+	for _, next := range []map[string]collector.Connection{poll(timeWait), poll(timeWait), poll()} {
+		remnants.filter(next)
+		for _, event := range diffConnections(current, next, start.Add(2*time.Minute)) {
+			if event.Event == "closed" {
+				closed = append(closed, event)
+			}
+		}
+		current = next
+	}
+
+	if len(closed) != 1 || closed[0].Connection.TxBytes != 87 || closed[0].LifetimeMs != 60000 {
+		t.Fatalf("expected the connection closed once, with its counters, got %+v", closed)
+	}
+	if len(remnants) != 0 {
+		t.Errorf("expected the remnant to be forgotten once gone, got %v", remnants)
+	}
+
+	// a new connection on the same addresses is reported again
+	remnants[getConnectionKey(client)] = true
+	next := poll(client)
+	remnants.filter(next)
+	if len(next) != 1 || len(remnants) != 0 {
+		t.Errorf("expected a new connection to be kept, got %v / %v", next, remnants)
+	}
+}
+
+func TestHookEnv(t *testing.T) {
+	event := newTraceEvent(time.Unix(0, 0), "opened", collector.Connection{
+		ID: "abc", Proto: "tcp", State: "ESTABLISHED", PID: 42, Process: "curl",
+		Laddr: "10.0.0.1", Lport: 50000, Raddr: "1.1.1.1", Rport: 443,
+	})
+
+	env := make(map[string]string)
+	for _, kv := range hookEnv(event) {
+		name, value, _ := strings.Cut(kv, "=")
+		env[name] = value
+	}
+	for name, want := range map[string]string{
+		"SNITCH_EVENT":   "opened",
+		"SNITCH_ID":      "abc",
+		"SNITCH_PID":     "42",
+		"SNITCH_PROCESS": "curl",
+		"SNITCH_RADDR":   "1.1.1.1",
+		"SNITCH_RPORT":   "443",
+		"SNITCH_STATE":   "ESTABLISHED",
+	} {
+		if env[name] != want {
+			t.Errorf("%s = %q, want %q", name, env[name], want)
+		}
+	}
+}
+
+func TestEventHook(t *testing.T) {
+	if _, err := newEventHook("true", []string{"bogus"}, 1, 1, 0); err == nil {
+		t.Error("expected an unknown --on event to be rejected")
+	}
+	if _, err := newEventHook("true", nil, 0, 1, 0); err == nil {
+		t.Error("expected a concurrency of 0 to be rejected")
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	hook, err := newEventHook(`{ echo "$SNITCH_EVENT $SNITCH_PID"; cat; } >> `+out, []string{"closed"}, 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := collector.Connection{ID: "abc", Proto: "tcp", PID: 42}
+	hook.run(newTraceEvent(time.Now(), "opened", conn))
+	hook.run(newTraceEvent(time.Now(), "closed", conn))
+	hook.wait()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || lines[0] != "closed 42" || !strings.Contains(lines[1], `"event":"closed"`) {
+		t.Errorf("expected one closed event in env and on stdin, got %q", data)
+	}
+
+	slow, err := newEventHook("sleep 10", nil, 1, 1, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	slow.run(newTraceEvent(time.Now(), "opened", conn))
+	slow.wait()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the command to be killed after its timeout, ran %s", elapsed)
+	}
+}
+
+func TestEventHookQueue(t *testing.T) {
+	hook, err := newEventHook("sleep 1", nil, 1, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stderr strings.Builder
+	hook.errw = &stderr
+
+	// one command runs and two wait, the rest must be dropped rather than
+	// holding up the caller. the first may still be queued when the queue
+	// fills up, so one more can be dropped.
+	conn := collector.Connection{ID: "abc", Proto: "tcp"}
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		hook.run(newTraceEvent(time.Now(), "opened", conn))
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected run not to wait for a free slot, took %s", elapsed)
+	}
+	hook.wait()
+
+	dropped := stderr.String()
+	if !strings.Contains(dropped, "dropping events") || (!strings.Contains(dropped, "dropped 7 events") && !strings.Contains(dropped, "dropped 8 events")) {
+		t.Errorf("expected the drops to be reported, got %q", dropped)
+	}
+}
@@ -11,6 +11,9 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/karol-broda/snitch/internal/collector"
+	"github.com/karol-broda/snitch/internal/errutil"
 )
 
 var (
@@ -26,8 +29,9 @@ var watchCmd = &cobra.Command{
 Filters are specified in key=value format. For example:
   snitch watch proto=tcp state=established
 
-Available filters:
-  proto, state, pid, proc, lport, rport, user, laddr, raddr, contains
+` + filterKeysHelp + `
+With --sink, frames are also written to a rotating file, syslog, journald,
+a webhook or a unix socket, see 'snitch trace --help'.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		runWatchCommand(args)
@@ -50,6 +54,9 @@ func runWatchCommand(args []string) {
 		cancel()
 	}()
 
+	sinks := openSinks()
+	defer errutil.Close(sinks)
+
 	ticker := time.NewTicker(watchInterval)
 	defer ticker.Stop()
 
@@ -70,6 +77,9 @@ func runWatchCommand(args []string) {
 				"connections": connections,
 				"count":       len(connections),
 			}
+			if warnings := collector.GetWarnings(); len(warnings) > 0 {
+				frame["warnings"] = warnings
+			}
 
 			jsonOutput, err := json.Marshal(frame)
 			if err != nil {
@@ -78,6 +88,7 @@ func runWatchCommand(args []string) {
 			}
 
 			fmt.Println(string(jsonOutput))
+			sinks.Write(jsonOutput)
 
 			count++
 			if watchCount > 0 && count >= watchCount {
@@ -96,4 +107,5 @@ func init() {
 
 	// shared filter flags
 	addFilterFlags(watchCmd)
+	addSinkFlags(watchCmd)
 }
@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// socketKey identifies a socket across refreshes. the addresses of a tcp
+// connection are unique in its namespace, but its inode is not stable: the
+// kernel clears it once the socket is orphaned, in FIN_WAIT, LAST_ACK or
+// TIME_WAIT. other sockets, like listeners sharing a port with SO_REUSEPORT
+// or unix sockets, can have the same addresses and are told apart by inode.
+type socketKey struct {
+	namespace string
+	proto     string
+	inode     int64
+	laddr     string
+	lport     int
+	raddr     string
+	rport     int
+}
+
+func keyOf(c Connection) socketKey {
+	k := socketKey{
+		namespace: c.Namespace,
+		proto:     c.Proto,
+		laddr:     c.Laddr,
+		lport:     c.Lport,
+		raddr:     c.Raddr,
+		rport:     c.Rport,
+	}
+	if !strings.HasPrefix(c.Proto, "tcp") || c.Rport == 0 {
+		k.inode = c.Inode
+	}
+	return k
+}
+
+// SameSocket reports whether a and b, which have the same id, are one socket
+// rather than a new connection on the addresses of one that closed. that is
+// only known while both still have their inode.
+func SameSocket(a, b Connection) bool {
+	return a.Inode == 0 || b.Inode == 0 || a.Inode == b.Inode
+}
+
+// id renders the key as a Connection.ID
+func (k socketKey) id() string {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%s|%s|%d|%s|%d|%s|%d", k.namespace, k.proto, k.inode, k.laddr, k.lport, k.raddr, k.rport)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// ConnectionID identifies a socket by its namespace, protocol and addresses,
+// and its inode when those can be shared, so the id survives refreshes,
+// state changes, the socket being orphaned and a different process being
+// attributed, and is the same in every snitch command
+func ConnectionID(c Connection) string {
+	return keyOf(c).id()
+}
+
+func setIDs(conns []Connection) {
+	for i := range conns {
+		conns[i].ID = ConnectionID(conns[i])
+	}
+}
+
+// ageTracker remembers when each socket was first seen by a long-running
+// command such as top, watch or trace
+type ageTracker struct {
+	mu        sync.Mutex
+	firstSeen map[socketKey]seenSocket
+	observed  bool // a collection was seen before
+}
+
+// seenSocket is when a socket was first seen, and its last known inode
+type seenSocket struct {
+	at    time.Time
+	inode int64
+}
+
+func newAgeTracker() *ageTracker {
+	return &ageTracker{firstSeen: make(map[socketKey]seenSocket)}
+}
+
+// observe sets FirstSeen and Age on conns, which must be a full collection:
+// sockets missing from it are forgotten. sockets in the first collection
+// keep the estimate they were collected with, if any; later ones opened
+// since the previous collection and date from now. a nil tracker remembers
+// nothing.
+func (t *ageTracker) observe(conns []Connection) {
+	if t == nil {
+		for i := range conns {
+			setAge(&conns[i])
+		}
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	present := make(map[socketKey]bool, len(conns))
+	for i := range conns {
+		key := keyOf(conns[i])
+		present[key] = true
+
+		seen, ok := t.firstSeen[key]
+		switch {
+		case ok && SameSocket(Connection{Inode: seen.inode}, conns[i]):
+			conns[i].FirstSeen = seen.at
+		case t.observed:
+			conns[i].FirstSeen = conns[i].TS
+		}
+		setAge(&conns[i])
+		if conns[i].Inode != 0 {
+			seen.inode = conns[i].Inode
+		}
+		t.firstSeen[key] = seenSocket{at: conns[i].FirstSeen, inode: seen.inode}
+	}
+
+	t.observed = true
+	for key := range t.firstSeen {
+		if !present[key] {
+			delete(t.firstSeen, key)
+		}
+	}
+}
+
+// setAge fills in Age, dating a socket without FirstSeen from TS
+func setAge(c *Connection) {
+	if c.FirstSeen.IsZero() || c.FirstSeen.After(c.TS) {
+		c.FirstSeen = c.TS
+	}
+	c.Age = int64(c.TS.Sub(c.FirstSeen) / time.Second)
+}
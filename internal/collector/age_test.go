@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgeTracker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newAgeTracker()
+
+	listener := Connection{TS: start, Proto: "tcp", Lport: 80, Inode: 100, FirstSeen: start.Add(-time.Hour)}
+	client := Connection{TS: start, Proto: "tcp", Lport: 40000, Raddr: "10.0.0.1", Rport: 443, Inode: 101}
+	conns := []Connection{listener, client}
+	tracker.observe(conns)
+	if conns[0].Age != 3600 {
+		t.Errorf("estimated age should be kept on first sight, got %d", conns[0].Age)
+	}
+	if !conns[1].FirstSeen.Equal(start) || conns[1].Age != 0 {
+		t.Errorf("socket without estimate should date from now, got %v", conns[1].FirstSeen)
+	}
+
+	// a minute later the listener is still there and the client's inode was
+	// reused for a connection elsewhere. the estimate of a socket that
+	// appeared since, the start of its process, is not used.
+	later := start.Add(time.Minute)
+	listener.TS, listener.FirstSeen = later, later
+	client.TS, client.Raddr, client.FirstSeen = later, "10.0.0.2", start.Add(-time.Hour)
+	conns = []Connection{listener, client}
+	tracker.observe(conns)
+	if conns[0].Age != 3660 {
+		t.Errorf("expected age to grow across refreshes, got %d", conns[0].Age)
+	}
+	if !conns[1].FirstSeen.Equal(later) {
+		t.Errorf("reused inode should count as a new socket, got %v", conns[1].FirstSeen)
+	}
+	if len(tracker.firstSeen) != 2 {
+		t.Errorf("closed socket not forgotten: %d tracked", len(tracker.firstSeen))
+	}
+
+	// orphaned in TIME_WAIT the client has no inode but is the same socket,
+	// and a new connection on its addresses is not
+	orphaned := client
+	orphaned.TS, orphaned.Inode = later.Add(time.Minute), 0
+	conns = []Connection{listener, orphaned}
+	tracker.observe(conns)
+	if !conns[1].FirstSeen.Equal(later) {
+		t.Errorf("orphaned socket should keep its age, got %v", conns[1].FirstSeen)
+	}
+	reconnected := client
+	reconnected.TS, reconnected.Inode = later.Add(2*time.Minute), 102
+	conns = []Connection{listener, reconnected}
+	tracker.observe(conns)
+	if !conns[1].FirstSeen.Equal(reconnected.TS) {
+		t.Errorf("new connection on the same addresses should be new, got %v", conns[1].FirstSeen)
+	}
+
+	// without a tracker only the estimate is used
+	conns = []Connection{{TS: later, FirstSeen: start}}
+	(*ageTracker)(nil).observe(conns)
+	if conns[0].Age != 60 {
+		t.Errorf("nil tracker age = %d, want 60", conns[0].Age)
+	}
+}
+
+func TestConnectionID(t *testing.T) {
+	c := Connection{Namespace: "host", Proto: "tcp", Laddr: "10.0.0.1", Lport: 443, Raddr: "10.0.0.2", Rport: 50000, Inode: 100, PID: 10, State: "ESTABLISHED"}
+	id := ConnectionID(c)
+	if len(id) != 16 {
+		t.Fatalf("expected a 16 character id, got %q", id)
+	}
+
+	same := c
+	same.PID = 11
+	same.State = "CLOSE_WAIT"
+	same.TS = time.Now()
+	if ConnectionID(same) != id {
+		t.Error("expected the id to survive a state change and a new owner")
+	}
+
+	// the kernel clears the inode of an orphaned tcp socket
+	orphaned := c
+	orphaned.Inode = 0
+	orphaned.State = "TIME_WAIT"
+	if ConnectionID(orphaned) != id {
+		t.Error("expected the id to survive the socket being orphaned")
+	}
+	if reused := (Connection{Inode: 101}); SameSocket(c, reused) || !SameSocket(c, orphaned) {
+		t.Error("expected only inodes that differ to tell sockets apart")
+	}
+
+	// listeners can share their addresses
+	listener := Connection{Namespace: "host", Proto: "tcp", Laddr: "0.0.0.0", Lport: 80, Inode: 200}
+	other := listener
+	other.Inode = 201
+	if ConnectionID(listener) == ConnectionID(other) {
+		t.Error("expected listeners sharing a port to get different ids")
+	}
+}
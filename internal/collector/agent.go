@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AgentPath is where an agent serves the connections of its host
+const AgentPath = "/v1/connections"
+
+// AgentOptions configures NewAgentHandler
+type AgentOptions struct {
+	// Token is the bearer token requests must carry. empty allows any.
+	Token string
+
+	// Collector configures the collection of every request. requests can
+	// add unix, netlink and conntrack to it.
+	Collector Options
+
+	// SnitchVersion is reported in the snapshots served
+	SnitchVersion string
+
+	// NewCollector creates the collector for a set of options,
+	// NewDefaultCollector when nil
+	NewCollector func(Options) Collector
+}
+
+// agentHandler serves the connections of this host to RemoteCollectors
+type agentHandler struct {
+	opts AgentOptions
+
+	// collections run one at a time, on a collector per set of options
+	mu         sync.Mutex
+	collectors map[Options]Collector
+}
+
+// NewAgentHandler returns the http handler of a snitch agent. it answers
+// GET AgentPath with a Snapshot of this host, filtered by the json encoded
+// FilterOptions in the filter query parameter.
+func NewAgentHandler(opts AgentOptions) http.Handler {
+	if opts.NewCollector == nil {
+		opts.NewCollector = func(o Options) Collector { return NewDefaultCollector(o) }
+	}
+	mux := http.NewServeMux()
+	mux.Handle(AgentPath, &agentHandler{opts: opts, collectors: make(map[Options]Collector)})
+	return mux
+}
+
+func (h *agentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.opts.Token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.opts.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="snitch"`)
+			http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+			return
+		}
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	var filters FilterOptions
+	if f := query.Get("filter"); f != "" {
+		if err := json.Unmarshal([]byte(f), &filters); err != nil {
+			http.Error(w, "invalid filter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	flag := func(name string) bool {
+		set, _ := strconv.ParseBool(query.Get(name))
+		return set
+	}
+	opts := h.opts.Collector
+	opts.IncludeUnix = opts.IncludeUnix || flag("unix") || filters.WantsProto("unix")
+	opts.IncludeNetlink = opts.IncludeNetlink || flag("netlink") || filters.WantsProto("netlink")
+	opts.Conntrack = opts.Conntrack || flag("conntrack") || filters.Mark != ""
+
+	s, err := h.collect(opts, filters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+func (h *agentHandler) collect(opts Options, filters FilterOptions) (*Snapshot, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := h.collectors[opts]
+	if c == nil {
+		c = h.opts.NewCollector(opts)
+		h.collectors[opts] = c
+	}
+	conns, err := c.GetConnections()
+	if err != nil {
+		return nil, err
+	}
+	var warnings []Warning
+	if r, ok := c.(WarningReporter); ok {
+		warnings = r.Warnings()
+	}
+	return newSnapshot(FilterConnections(conns, filters), warnings, h.opts.SnitchVersion), nil
+}
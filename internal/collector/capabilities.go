@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+)
+
+// capability names by bit, from linux/capability.h
+var capabilityNames = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_DAC_READ_SEARCH",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETPCAP",
+	"CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN",
+	"CAP_NET_RAW",
+	"CAP_IPC_LOCK",
+	"CAP_IPC_OWNER",
+	"CAP_SYS_MODULE",
+	"CAP_SYS_RAWIO",
+	"CAP_SYS_CHROOT",
+	"CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT",
+	"CAP_SYS_ADMIN",
+	"CAP_SYS_BOOT",
+	"CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME",
+	"CAP_SYS_TTY_CONFIG",
+	"CAP_MKNOD",
+	"CAP_LEASE",
+	"CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL",
+	"CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE",
+	"CAP_MAC_ADMIN",
+	"CAP_SYSLOG",
+	"CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND",
+	"CAP_AUDIT_READ",
+	"CAP_PERFMON",
+	"CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// CapabilityNames decodes a capability set in the hex form of
+// /proc/<pid>/status. bits newer than this list are shown as cap_<n>.
+func CapabilityNames(capSet string) []string {
+	caps, err := strconv.ParseUint(strings.TrimSpace(capSet), 16, 64)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for bit := 0; bit < 64; bit++ {
+		if caps&(1<<bit) == 0 {
+			continue
+		}
+		if bit < len(capabilityNames) {
+			names = append(names, capabilityNames[bit])
+		} else {
+			names = append(names, "cap_"+strconv.Itoa(bit))
+		}
+	}
+	return names
+}
+
+// HasAllCapabilities reports whether a capability set holds every
+// capability snitch knows about, as a root process usually does
+func HasAllCapabilities(capSet string) bool {
+	caps, err := strconv.ParseUint(strings.TrimSpace(capSet), 16, 64)
+	if err != nil {
+		return false
+	}
+	all := uint64(1)<<len(capabilityNames) - 1
+	return caps&all == all
+}
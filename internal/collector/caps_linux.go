@@ -0,0 +1,64 @@
+//go:build linux
+
+package collector
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/karol-broda/snitch/internal/errutil"
+)
+
+// capability bits from linux/capability.h
+const (
+	capDacOverride   = 1
+	capDacReadSearch = 2
+	capSysPtrace     = 19
+)
+
+// missingCapabilities lists the capabilities snitch needs to read the fds of
+// other users' processes but does not have. it always looks at snitch itself,
+// whatever proc root is configured.
+func missingCapabilities() []string {
+	effective, ok := effectiveCapabilities()
+	if !ok {
+		return nil
+	}
+	return missingFrom(effective)
+}
+
+func missingFrom(effective uint64) []string {
+	has := func(bit uint) bool { return effective&(1<<bit) != 0 }
+
+	var missing []string
+	// /proc/<pid>/fd is only readable by the owner
+	if !has(capDacReadSearch) && !has(capDacOverride) {
+		missing = append(missing, "CAP_DAC_READ_SEARCH")
+	}
+	// and its links need ptrace read access to the process
+	if !has(capSysPtrace) {
+		missing = append(missing, "CAP_SYS_PTRACE")
+	}
+	return missing
+}
+
+func effectiveCapabilities() (uint64, bool) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, false
+	}
+	defer errutil.Close(file)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		return caps, err == nil
+	}
+	return 0, false
+}
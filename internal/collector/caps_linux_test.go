@@ -0,0 +1,29 @@
+//go:build linux
+
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMissingCapabilities(t *testing.T) {
+	testCases := []struct {
+		name      string
+		effective uint64
+		expected  []string
+	}{
+		{"root", 0x000001ffffffffff, nil},
+		{"unprivileged", 0, []string{"CAP_DAC_READ_SEARCH", "CAP_SYS_PTRACE"}},
+		{"dac override is enough for the fd dir", 1 << capDacOverride, []string{"CAP_SYS_PTRACE"}},
+		{"ptrace only", 1 << capSysPtrace, []string{"CAP_DAC_READ_SEARCH"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := missingFrom(tc.effective); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("missingFrom(%#x) = %v, want %v", tc.effective, got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+//go:build linux
+
+package collector
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/karol-broda/snitch/internal/errutil"
+)
+
+// containerInfo is what a process's cgroup path says about the container
+// and kubernetes pod it belongs to
+type containerInfo struct {
+	id      string
+	runtime string
+	podUID  string
+	podQoS  string
+}
+
+// name prefixes of container cgroups, e.g. docker-<id>.scope with the
+// systemd driver or libpod-<id> under podman's cgroupfs libpod_parent
+var scopeRuntimes = []struct {
+	prefix  string
+	runtime string
+}{
+	{"docker-", "docker"},
+	{"cri-containerd-", "containerd"},
+	{"crio-", "cri-o"},
+	{"libpod-", "podman"},
+}
+
+// parent directories used with the cgroupfs driver, e.g. /docker/<id>
+var parentRuntimes = map[string]string{
+	"docker": "docker",
+	"crio":   "cri-o",
+}
+
+// readCgroup parses /proc/<pid>/cgroup into the container the process runs
+// in and the systemd unit it belongs to
+func (p procFS) readCgroup(pid int) (containerInfo, string) {
+	file, err := os.Open(p.pidPath(pid, "cgroup"))
+	if err != nil {
+		return containerInfo{}, ""
+	}
+	defer errutil.Close(file)
+
+	var info containerInfo
+	var unit string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// hierarchy-id:controllers:path, a single 0::path line on cgroup v2
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		// systemd's own hierarchy on v1, the unified one on v2
+		if parts[1] == "" || parts[1] == "name=systemd" {
+			unit = parseSystemdUnit(parts[2])
+		}
+		if info.id == "" {
+			info = parseCgroupPath(parts[2])
+		}
+	}
+	return info, unit
+}
+
+// parseSystemdUnit returns the innermost service or scope in a cgroup path,
+// or the innermost slice if there is none. user services nest under
+// user@<uid>.service, so the deepest unit is the most specific one.
+func parseSystemdUnit(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	slice := ""
+	for i := len(segments) - 1; i >= 0; i-- {
+		seg := segments[i]
+		if strings.HasSuffix(seg, ".service") || strings.HasSuffix(seg, ".scope") {
+			return seg
+		}
+		if slice == "" && strings.HasSuffix(seg, ".slice") {
+			slice = seg
+		}
+	}
+	return slice
+}
+
+// parseCgroupPath recognises the docker, containerd, cri-o, podman and
+// kubepods layouts of both the systemd and cgroupfs drivers
+func parseCgroupPath(path string) containerInfo {
+	var info containerInfo
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	inKubepods := false
+	for i, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, "kubepods"):
+			inKubepods = true
+			parseKubepodsSegment(seg, &info)
+		case inKubepods && seg == "burstable":
+			info.podQoS = "Burstable"
+		case inKubepods && seg == "besteffort":
+			info.podQoS = "BestEffort"
+		case inKubepods && strings.HasPrefix(seg, "pod"):
+			info.podUID = strings.TrimPrefix(seg, "pod")
+		}
+
+		if id, runtime, ok := containerSegment(seg); ok {
+			info.id = id
+			info.runtime = runtime
+			if runtime == "" && i > 0 {
+				info.runtime = parentRuntimes[segments[i-1]]
+			}
+		}
+	}
+
+	if info.podUID != "" && info.podQoS == "" {
+		// guaranteed pods sit directly under kubepods
+		info.podQoS = "Guaranteed"
+	}
+	return info
+}
+
+// parseKubepodsSegment handles the systemd driver's slice names:
+// kubepods-burstable.slice, kubepods-besteffort-pod<uid>.slice and
+// kubepods-pod<uid>.slice. uids use _ in place of - in slice names.
+func parseKubepodsSegment(seg string, info *containerInfo) {
+	name := strings.TrimSuffix(seg, ".slice")
+	if strings.Contains(name, "-burstable") {
+		info.podQoS = "Burstable"
+	} else if strings.Contains(name, "-besteffort") {
+		info.podQoS = "BestEffort"
+	}
+	if i := strings.LastIndex(name, "-pod"); i >= 0 {
+		info.podUID = strings.ReplaceAll(name[i+len("-pod"):], "_", "-")
+	}
+}
+
+// containerSegment reports whether one path segment names a container. the
+// runtime is empty when only the parent directory can tell.
+func containerSegment(seg string) (id, runtime string, ok bool) {
+	name := strings.TrimSuffix(seg, ".scope")
+	for _, r := range scopeRuntimes {
+		if rest, found := strings.CutPrefix(name, r.prefix); found && isContainerID(rest) {
+			return rest, r.runtime, true
+		}
+	}
+	if isContainerID(name) {
+		return name, "", true
+	}
+	return "", "", false
+}
+
+// isContainerID matches the 64 hex digit ids every supported runtime uses
+func isContainerID(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
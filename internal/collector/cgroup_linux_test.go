@@ -0,0 +1,96 @@
+//go:build linux
+
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCgroupPath(t *testing.T) {
+	id := strings.Repeat("ab12", 16)
+	uid := "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"
+	uidSlice := strings.ReplaceAll(uid, "-", "_")
+
+	tests := []struct {
+		name string
+		path string
+		want containerInfo
+	}{
+		{"docker cgroupfs", "/docker/" + id, containerInfo{id: id, runtime: "docker"}},
+		{"docker systemd", "/system.slice/docker-" + id + ".scope", containerInfo{id: id, runtime: "docker"}},
+		{"podman systemd", "/machine.slice/libpod-" + id + ".scope/container", containerInfo{id: id, runtime: "podman"}},
+		{"podman cgroupfs", "/libpod_parent/libpod-" + id, containerInfo{id: id, runtime: "podman"}},
+		{"podman conmon is not the container", "/machine.slice/libpod-conmon-" + id + ".scope", containerInfo{}},
+		{
+			"kubepods containerd burstable",
+			"/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod" + uidSlice + ".slice/cri-containerd-" + id + ".scope",
+			containerInfo{id: id, runtime: "containerd", podUID: uid, podQoS: "Burstable"},
+		},
+		{
+			"kubepods crio guaranteed",
+			"/kubepods.slice/kubepods-pod" + uidSlice + ".slice/crio-" + id + ".scope",
+			containerInfo{id: id, runtime: "cri-o", podUID: uid, podQoS: "Guaranteed"},
+		},
+		{
+			"kubepods cgroupfs besteffort",
+			"/kubepods/besteffort/pod" + uid + "/" + id,
+			containerInfo{id: id, podUID: uid, podQoS: "BestEffort"},
+		},
+		{"host process", "/user.slice/user-1000.slice/session-2.scope", containerInfo{}},
+		{"root", "/", containerInfo{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCgroupPath(tt.path); got != tt.want {
+				t.Errorf("parseCgroupPath(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerAttribution(t *testing.T) {
+	id := strings.Repeat("c0ffee", 10) + "abcd"
+	fp := newFakeProc(t)
+	for _, table := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		fp.writeFile("net/"+table, procNetHeader)
+	}
+	fp.writeFile("net/tcp", procNetHeader+
+		"   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 3333 1 0000000000000000 100 0 0 10 0\n")
+	fp.addProcess(77, "python", 0, 3333)
+	fp.writeFile("77/cgroup", "12:memory:/docker/"+id+"\n0::/system.slice/docker-"+id+".scope\n")
+
+	conns, err := NewDefaultCollector(Options{ProcRoot: fp.root}).GetConnections()
+	if err != nil {
+		t.Fatalf("GetConnections() error: %v", err)
+	}
+	if len(conns) != 1 || conns[0].ContainerID != id || conns[0].ContainerRuntime != "docker" {
+		t.Fatalf("expected docker container on the listener, got %+v", conns)
+	}
+	if ShortContainerID(conns[0].ContainerID) != "c0ffeec0ffee" {
+		t.Errorf("ShortContainerID = %q", ShortContainerID(conns[0].ContainerID))
+	}
+}
+
+func TestParseSystemdUnit(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/system.slice/sshd.service", "sshd.service"},
+		{"/init.scope", "init.scope"},
+		{"/user.slice/user-1000.slice/session-2.scope", "session-2.scope"},
+		{"/user.slice/user-1000.slice/user@1000.service/app.slice/pipewire.service", "pipewire.service"},
+		{"/system.slice/docker-" + strings.Repeat("ab12", 16) + ".scope", "docker-" + strings.Repeat("ab12", 16) + ".scope"},
+		{"/machine.slice", "machine.slice"},
+		{"/docker/" + strings.Repeat("ab12", 16), ""},
+		{"/", ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseSystemdUnit(tt.path); got != tt.want {
+			t.Errorf("parseSystemdUnit(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
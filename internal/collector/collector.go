@@ -1,7 +1,6 @@
 package collector
 
 import (
-	"net"
 	"strings"
 )
 
@@ -10,6 +9,44 @@ type Collector interface {
 	GetConnections() ([]Connection, error)
 }
 
+// Backend names accepted by Options.Backend
+const (
+	BackendNetlink = "netlink"
+	BackendProc    = "proc"
+)
+
+// HostNamespace is the Connection.Namespace of sockets in the network
+// namespace of pid 1
+const HostNamespace = "host"
+
+// Options configures the platform collector created by NewDefaultCollector.
+// fields that do not apply to the current platform are ignored.
+type Options struct {
+	// Backend selects how sockets are enumerated on linux. netlink (the
+	// default) uses sock_diag and falls back to /proc when it is unavailable.
+	Backend string
+
+	// ProcRoot is where the proc filesystem is mounted on linux, /proc when
+	// empty. any other root is always read through its files, since netlink
+	// can only describe the running kernel.
+	ProcRoot string
+
+	// AllNamespaces collects sockets from every network namespace on linux
+	// instead of only the one snitch runs in
+	AllNamespaces bool
+
+	// IncludeUnix adds unix domain sockets to the inet sockets on linux
+	IncludeUnix bool
+
+	// IncludeNetlink adds netlink sockets on linux. every process that talks
+	// to the kernel has some, so they are left out unless asked for.
+	IncludeNetlink bool
+
+	// Conntrack joins the netfilter conntrack table to connections on linux,
+	// adding their nat tuples, mark and zone
+	Conntrack bool
+}
+
 // Global collector instance (can be overridden for testing)
 var globalCollector Collector = &DefaultCollector{}
 
@@ -42,23 +79,13 @@ func FilterConnections(conns []Connection, filters FilterOptions) []Connection {
 	return filtered
 }
 
-func guessNetworkInterface(addr string) string {
-	if addr == "127.0.0.1" || addr == "::1" {
-		return "lo"
-	}
-
-	ip := net.ParseIP(addr)
-	if ip == nil {
-		return ""
+// ShortContainerID abbreviates a container id to the 12 characters docker
+// and kubectl show
+func ShortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
 	}
-
-	if ip.IsLoopback() {
-		return "lo"
-	}
-
-	// default interface name varies by OS but we return a generic value
-	// actual interface detection would require routing table analysis
-	return ""
+	return id
 }
 
 func simplifyIPv6(addr string) string {
@@ -125,6 +125,12 @@ import (
 // DefaultCollector implements the Collector interface using libproc on macOS
 type DefaultCollector struct{}
 
+// NewDefaultCollector creates a collector. libproc has a single backend,
+// so the linux specific options are ignored.
+func NewDefaultCollector(opts Options) *DefaultCollector {
+	return &DefaultCollector{}
+}
+
 // GetConnections fetches all network connections using libproc
 func (dc *DefaultCollector) GetConnections() ([]Connection, error) {
 	pids, err := listAllPids()
@@ -142,6 +148,12 @@ func (dc *DefaultCollector) GetConnections() ([]Connection, error) {
 		connections = append(connections, procConns...)
 	}
 
+	// without a routing table only exact address matches are possible
+	ifaces := newInterfaceResolver()
+	ifaces.loadHostInterfaces()
+	ifaces.apply(connections)
+	setIDs(connections)
+
 	return connections, nil
 }
 
@@ -293,7 +305,6 @@ func getSocketInfo(pid, fd int, procName, cwd string, uid int, user string) (Con
 		Cwd:       cwd,
 		UID:       uid,
 		User:      user,
-		Interface: guessNetworkInterface(laddr),
 	}
 
 	return conn, true
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +17,7 @@ import (
 	"time"
 
 	"github.com/karol-broda/snitch/internal/errutil"
+	"golang.org/x/sys/unix"
 )
 
 // set SNITCH_DEBUG_TIMING=1 to enable timing diagnostics
@@ -65,48 +67,281 @@ func lookupUsername(uid int) string {
 	return username
 }
 
-// DefaultCollector implements the Collector interface using /proc filesystem
-type DefaultCollector struct{}
+// DefaultCollector implements the Collector interface using the netlink
+// sock_diag interface, falling back to parsing the /proc filesystem
+type DefaultCollector struct {
+	backend        string
+	proc           procFS
+	allNamespaces  bool
+	includeUnix    bool
+	includeNetlink bool
+	conntrack      bool
+	inodes         *inodeCache
+	ages           *ageTracker
+	unixAges       *ageTracker // GetUnixSockets returns a set of its own
+	socketUnits    *socketUnitCache
+
+	mu       sync.Mutex
+	warnings []Warning // from the last GetConnections
+}
+
+// NewDefaultCollector creates a collector configured by opts
+func NewDefaultCollector(opts Options) *DefaultCollector {
+	return &DefaultCollector{
+		backend:        opts.Backend,
+		proc:           newProcFS(opts.ProcRoot),
+		allNamespaces:  opts.AllNamespaces,
+		includeUnix:    opts.IncludeUnix,
+		includeNetlink: opts.IncludeNetlink,
+		conntrack:      opts.Conntrack,
+		inodes:         newInodeCache(),
+		ages:           newAgeTracker(),
+		unixAges:       newAgeTracker(),
+		socketUnits:    newSocketUnitCache(systemdUnitDirs),
+	}
+}
+
+// inetTable describes one kind of inet socket and where to find it
+type inetTable struct {
+	name      string // file name under /proc/net
+	proto     string
+	ipVersion int
+	family    uint8
+	protocol  uint8
+	procOnly  bool // not available over inet_diag
+}
 
-// GetConnections fetches all network connections by parsing /proc files
+// raw and icmp (ping) sockets use the udp table layout; for raw sockets the
+// local port is the ip protocol number
+var inetTables = []inetTable{
+	{name: "tcp", proto: "tcp", ipVersion: 4, family: unix.AF_INET, protocol: unix.IPPROTO_TCP},
+	{name: "tcp6", proto: "tcp6", ipVersion: 6, family: unix.AF_INET6, protocol: unix.IPPROTO_TCP},
+	{name: "udp", proto: "udp", ipVersion: 4, family: unix.AF_INET, protocol: unix.IPPROTO_UDP},
+	{name: "udp6", proto: "udp6", ipVersion: 6, family: unix.AF_INET6, protocol: unix.IPPROTO_UDP},
+	{name: "udplite", proto: "udplite", ipVersion: 4, family: unix.AF_INET, protocol: unix.IPPROTO_UDPLITE},
+	{name: "udplite6", proto: "udplite6", ipVersion: 6, family: unix.AF_INET6, protocol: unix.IPPROTO_UDPLITE},
+	{name: "raw", proto: "raw", ipVersion: 4, family: unix.AF_INET, protocol: unix.IPPROTO_RAW, procOnly: true},
+	{name: "raw6", proto: "raw6", ipVersion: 6, family: unix.AF_INET6, protocol: unix.IPPROTO_RAW, procOnly: true},
+	{name: "icmp", proto: "icmp", ipVersion: 4, family: unix.AF_INET, protocol: unix.IPPROTO_ICMP, procOnly: true},
+	{name: "icmp6", proto: "icmp6", ipVersion: 6, family: unix.AF_INET6, protocol: unix.IPPROTO_ICMPV6, procOnly: true},
+}
+
+// userHZ is the clock tick /proc uses for timer values and process times
+// (USER_HZ). it is 100 on every architecture linux supports today.
+const userHZ = 100
+
+// GetConnections fetches all network connections from the configured backend
 func (dc *DefaultCollector) GetConnections() ([]Connection, error) {
 	totalStart := time.Now()
 	defer func() { logTiming("GetConnections total", totalStart) }()
 
+	var diag *sockDiagConn
+	switch dc.backend {
+	case "", BackendNetlink:
+		if !dc.proc.isLive() {
+			// netlink would describe this kernel, not the tree at proc root
+			break
+		}
+		var err error
+		diag, err = dialSockDiag()
+		if err != nil {
+			if debugTiming {
+				fmt.Fprintf(os.Stderr, "[timing] netlink unavailable, using /proc: %v\n", err)
+			}
+		} else {
+			defer errutil.Close(diag)
+		}
+	case BackendProc:
+	default:
+		return nil, fmt.Errorf("unknown collector backend %q", dc.backend)
+	}
+
 	inodeStart := time.Now()
-	inodeMap, err := buildInodeToProcessMap()
+	inodeMap, denied, err := dc.inodeMap()
 	logTiming("buildInodeToProcessMap", inodeStart, fmt.Sprintf("%d inodes", len(inodeMap)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to build inode map: %w", err)
 	}
 
+	parseStart := time.Now()
 	var connections []Connection
+	if dc.allNamespaces {
+		connections, err = dc.collectAllNamespaces(diag, inodeMap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list network namespaces: %w", err)
+		}
+	} else {
+		connections = dc.collectSockets(diag, dc.proc.path("net"), dc.proc.isLive(), inodeMap)
+		if own := dc.proc.netnsInode("self"); own != 0 {
+			name := netnsIdentity(own, dc.proc.netnsInode("1"), dc.proc.namedNetns())
+			setNamespace(connections, name)
+		}
+	}
+	logTiming("collect sockets (all)", parseStart, fmt.Sprintf("%d connections", len(connections)))
 
-	parseStart := time.Now()
-	tcpConns, err := parseProcNet("/proc/net/tcp", "tcp", 4, inodeMap)
-	if err == nil {
-		connections = append(connections, tcpConns...)
+	if dc.inodes != nil {
+		dc.inodes.resolve(dc.proc, connections, inodeMap)
 	}
+	dc.attributeSocketUnits(connections)
+	setIDs(connections)
+	dc.ages.observe(connections)
+	dc.setWarnings(denied, connections)
 
-	tcpConns6, err := parseProcNet("/proc/net/tcp6", "tcp6", 6, inodeMap)
-	if err == nil {
-		connections = append(connections, tcpConns6...)
+	return connections, nil
+}
+
+// Warnings reports what the last GetConnections could not see
+func (dc *DefaultCollector) Warnings() []Warning {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.warnings
+}
+
+func (dc *DefaultCollector) setWarnings(denied int, connections []Connection) {
+	unattributed := 0
+	for _, c := range connections {
+		if c.Inode != 0 && c.PID == 0 {
+			unattributed++
+		}
 	}
 
-	udpConns, err := parseProcNet("/proc/net/udp", "udp", 4, inodeMap)
-	if err == nil {
-		connections = append(connections, udpConns...)
+	var missingCaps []string
+	if denied > 0 {
+		missingCaps = missingCapabilities()
 	}
 
-	udpConns6, err := parseProcNet("/proc/net/udp6", "udp6", 6, inodeMap)
-	if err == nil {
-		connections = append(connections, udpConns6...)
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.warnings = permissionWarnings(denied, unattributed, missingCaps)
+}
+
+// inodeMap maps socket inodes to the processes holding them, kept up to
+// date incrementally when the collector has an inode cache. it also returns
+// how many processes could not be inspected.
+func (dc *DefaultCollector) inodeMap() (map[int64][]inodeEntry, int, error) {
+	if dc.inodes == nil {
+		return dc.proc.scanAllInodes()
+	}
+	return dc.inodes.refresh(dc.proc)
+}
+
+// collectSockets reads the sockets of one namespace: the inet tables and
+// packet sockets, plus unix and netlink sockets when they were asked for
+func (dc *DefaultCollector) collectSockets(diag *sockDiagConn, netDir string, live bool, inodeMap map[int64][]inodeEntry) []Connection {
+	connections := dc.collectInet(diag, netDir, live, inodeMap)
+	if conns, err := parseProcPacket(filepath.Join(netDir, "packet"), live, inodeMap); err == nil {
+		connections = append(connections, conns...)
+	}
+	if dc.includeNetlink {
+		conns, err := parseProcNetlink(filepath.Join(netDir, "netlink"), inodeMap)
+		if err == nil {
+			connections = append(connections, conns...)
+		}
+	}
+	if dc.includeUnix {
+		conns, err := parseProcUnix(filepath.Join(netDir, "unix"), inodeMap)
+		if err == nil {
+			connections = append(connections, conns...)
+		}
 	}
-	logTiming("parseProcNet (all)", parseStart, fmt.Sprintf("%d connections", len(connections)))
+	return connections
+}
 
+// collectInet reads every inet table of one namespace, over netlink when diag
+// is set and from the files in netDir otherwise. live marks snitch's own
+// namespace on the running kernel.
+func (dc *DefaultCollector) collectInet(diag *sockDiagConn, netDir string, live bool, inodeMap map[int64][]inodeEntry) []Connection {
+	var connections []Connection
+	for _, t := range inetTables {
+		if diag != nil && !t.procOnly {
+			conns, err := diag.dumpInet(t, inodeMap)
+			if err == nil {
+				connections = append(connections, conns...)
+				continue
+			}
+			// e.g. udp_diag not loaded - this table still works through /proc
+			if debugTiming {
+				fmt.Fprintf(os.Stderr, "[timing] netlink dump of %s failed, using /proc: %v\n", t.name, err)
+			}
+		}
+
+		conns, err := parseProcNet(filepath.Join(netDir, t.name), t.proto, t.ipVersion, inodeMap)
+		if err == nil {
+			connections = append(connections, conns...)
+		}
+	}
+
+	// missing unless the sctp module is loaded
+	if conns, err := parseProcSCTP(filepath.Join(netDir, "sctp"), inodeMap); err == nil {
+		connections = append(connections, conns...)
+	}
+
+	dc.proc.interfaceResolver(netDir, live).apply(connections)
+	if dc.conntrack {
+		dc.joinConntrack(netDir, live, connections)
+	}
+	return connections
+}
+
+// joinConntrack adds the conntrack entries of one namespace to its sockets
+func (dc *DefaultCollector) joinConntrack(netDir string, live bool, connections []Connection) {
+	start := time.Now()
+	table, err := readConntrack(netDir, live && dc.backend != BackendProc)
+	if err != nil {
+		// nf_conntrack is not loaded, or reading it needs root
+		if debugTiming {
+			fmt.Fprintf(os.Stderr, "[timing] conntrack unavailable in %s: %v\n", netDir, err)
+		}
+		return
+	}
+	table.apply(connections)
+	logTiming("conntrack", start, fmt.Sprintf("%d flows", len(table.byOriginal)))
+}
+
+// collectAllNamespaces reads the sockets of every network namespace. the one
+// snitch runs in goes through the normal path (and netlink); the others are
+// read from /proc/<pid>/net of a process living there, or entered when
+// nothing lives there.
+func (dc *DefaultCollector) collectAllNamespaces(diag *sockDiagConn, inodeMap map[int64][]inodeEntry) ([]Connection, error) {
+	namespaces, err := dc.proc.netNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	own := dc.proc.netnsInode("self")
+	var connections []Connection
+	for _, ns := range namespaces {
+		var conns []Connection
+		switch {
+		case ns.inode == own:
+			conns = dc.collectSockets(diag, dc.proc.path("net"), dc.proc.isLive(), inodeMap)
+		case ns.mount != "":
+			var err error
+			if conns, err = dc.collectEnteredNamespace(ns.mount, inodeMap); err != nil && debugTiming {
+				fmt.Fprintf(os.Stderr, "[timing] skipping namespace %s: %v\n", ns.name, err)
+			}
+		default:
+			for _, pid := range ns.pids {
+				netDir := dc.proc.pidPath(pid, "net")
+				if _, err := os.Stat(netDir); err != nil {
+					continue // exited since the namespaces were listed
+				}
+				conns = dc.collectSockets(nil, netDir, false, inodeMap)
+				break
+			}
+		}
+		setNamespace(conns, ns.name)
+		connections = append(connections, conns...)
+	}
 	return connections, nil
 }
 
+func setNamespace(conns []Connection, name string) {
+	for i := range conns {
+		conns[i].Namespace = name
+	}
+}
+
 // GetAllConnections returns both network and Unix domain socket connections
 func GetAllConnections() ([]Connection, error) {
 	networkConns, err := GetConnections()
@@ -129,16 +364,76 @@ type processInfo struct {
 	cwd     string
 	uid     int
 	user    string
+
+	ppid       int
+	startTime  time.Time
+	exe        string
+	exeDeleted bool
+	capEff     string
+	label      string
+
+	container containerInfo
+	unit      string // systemd unit, from the cgroup path
 }
 
+// inodeEntry is one socket fd of a process. the inode map keeps every entry
+// of a socket, since forked processes share their parent's sockets.
 type inodeEntry struct {
-	inode int64
-	info  *processInfo
+	inode  int64
+	fd     int
+	opened time.Time // when the fd link was first looked up, if before the scan
+	info   *processInfo
+}
+
+// addHolders records entries under their socket inodes
+func addHolders(inodeMap map[int64][]inodeEntry, entries []inodeEntry) {
+	for _, e := range entries {
+		inodeMap[e.inode] = append(inodeMap[e.inode], e)
+	}
 }
 
-func buildInodeToProcessMap() (map[int64]*processInfo, error) {
+// sortHolders orders the holders of each socket by pid and fd, so the
+// process a shared socket is attributed to does not depend on scan order
+func sortHolders(inodeMap map[int64][]inodeEntry) {
+	for _, holders := range inodeMap {
+		if len(holders) < 2 {
+			continue
+		}
+		sort.Slice(holders, func(i, j int) bool {
+			if holders[i].info.pid != holders[j].info.pid {
+				return holders[i].info.pid < holders[j].info.pid
+			}
+			return holders[i].fd < holders[j].fd
+		})
+	}
+}
+
+func (p procFS) buildInodeToProcessMap() (map[int64][]inodeEntry, error) {
+	inodeMap, _, err := p.scanAllInodes()
+	return inodeMap, err
+}
+
+// scanAllInodes reads the socket fds of every process, returning the inode
+// map and how many processes were unreadable
+func (p procFS) scanAllInodes() (map[int64][]inodeEntry, int, error) {
+	pids, err := p.listPids()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	inodeMap := make(map[int64][]inodeEntry)
+	entries, denied := p.scanPids(pids)
+	for _, pidEntries := range entries {
+		addHolders(inodeMap, pidEntries)
+	}
+	sortHolders(inodeMap)
+	return inodeMap, len(denied), nil
+}
+
+// listPids returns the pids of every process under the proc root
+func (p procFS) listPids() ([]int, error) {
 	readDirStart := time.Now()
-	procDir, err := os.Open("/proc")
+	procDir, err := os.Open(p.path())
 	if err != nil {
 		return nil, err
 	}
@@ -149,7 +444,6 @@ func buildInodeToProcessMap() (map[int64]*processInfo, error) {
 		return nil, err
 	}
 
-	// collect pids first
 	pids := make([]int, 0, len(entries))
 	for _, entry := range entries {
 		if !entry.IsDir() {
@@ -163,11 +457,19 @@ func buildInodeToProcessMap() (map[int64]*processInfo, error) {
 	}
 	logTiming("  readdir /proc", readDirStart, fmt.Sprintf("%d pids", len(pids)))
 
+	return pids, nil
+}
+
+// scanPids reads the socket fds of the given processes, keyed by pid.
+// processes without sockets, or that exited meanwhile, are left out; those
+// whose fds snitch may not read are returned as denied.
+func (p procFS) scanPids(pids []int) (map[int][]inodeEntry, map[int]bool) {
 	// process pids in parallel with limited concurrency
 	scanStart := time.Now()
 	const numWorkers = 8
 	pidChan := make(chan int, len(pids))
 	resultChan := make(chan []inodeEntry, len(pids))
+	deniedChan := make(chan int, len(pids))
 
 	var totalFDs atomic.Int64
 	var wg sync.WaitGroup
@@ -176,7 +478,10 @@ func buildInodeToProcessMap() (map[int64]*processInfo, error) {
 		go func() {
 			defer wg.Done()
 			for pid := range pidChan {
-				entries := scanProcessSockets(pid)
+				entries, err := p.scanProcessSockets(pid)
+				if os.IsPermission(err) {
+					deniedChan <- pid
+				}
 				if len(entries) > 0 {
 					totalFDs.Add(int64(len(entries)))
 					resultChan <- entries
@@ -193,36 +498,42 @@ func buildInodeToProcessMap() (map[int64]*processInfo, error) {
 	go func() {
 		wg.Wait()
 		close(resultChan)
+		close(deniedChan)
 	}()
 
-	inodeMap := make(map[int64]*processInfo)
+	results := make(map[int][]inodeEntry)
 	for entries := range resultChan {
-		for _, e := range entries {
-			inodeMap[e.inode] = e.info
-		}
+		results[entries[0].info.pid] = entries
+	}
+	denied := make(map[int]bool)
+	for pid := range deniedChan {
+		denied[pid] = true
 	}
-	logTiming("  scan all processes", scanStart, fmt.Sprintf("%d socket fds scanned", totalFDs.Load()))
+	logTiming("  scan processes", scanStart, fmt.Sprintf("%d pids, %d socket fds scanned, %d denied", len(pids), totalFDs.Load(), len(denied)))
 
-	return inodeMap, nil
+	return results, denied
 }
 
-func scanProcessSockets(pid int) []inodeEntry {
+func (p procFS) scanProcessSockets(pid int) ([]inodeEntry, error) {
 	start := time.Now()
 
-	procInfo, err := getProcessInfo(pid)
+	procInfo, err := p.getProcessInfo(pid)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	pidStr := strconv.Itoa(pid)
-	fdDir := filepath.Join("/proc", pidStr, "fd")
+	fdDir := p.pidPath(pid, "fd")
 	fdEntries, err := os.ReadDir(fdDir)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	var results []inodeEntry
 	for _, fdEntry := range fdEntries {
+		fd, err := strconv.Atoi(fdEntry.Name())
+		if err != nil {
+			continue
+		}
 		fdPath := filepath.Join(fdDir, fdEntry.Name())
 		link, err := os.Readlink(fdPath)
 		if err != nil {
@@ -235,7 +546,7 @@ func scanProcessSockets(pid int) []inodeEntry {
 			if err != nil {
 				continue
 			}
-			results = append(results, inodeEntry{inode: inode, info: procInfo})
+			results = append(results, inodeEntry{inode: inode, fd: fd, opened: fdLookedUp(fdPath, start), info: procInfo})
 		}
 	}
 
@@ -245,20 +556,46 @@ func scanProcessSockets(pid int) []inodeEntry {
 			pid, procInfo.command, len(fdEntries), elapsed)
 	}
 
-	return results
+	return results, nil
+}
+
+// fdCount returns how many fds a process has open, which proc reports as
+// the size of /proc/<pid>/fd since linux 6.2, or 0 when it is unknown
+func (p procFS) fdCount(pid int) int64 {
+	var st unix.Stat_t
+	if err := unix.Stat(p.pidPath(pid, "fd"), &st); err != nil {
+		return 0
+	}
+	return st.Size
+}
+
+// fdLookedUp returns the ctime of a /proc/<pid>/fd link, if it is older than
+// the scan that started at scanned. proc creates the link when it is first
+// looked up, by snitch or anything else, so the socket is at least that old:
+// a lower bound on its age. a link this scan created says nothing.
+func fdLookedUp(path string, scanned time.Time) time.Time {
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return time.Time{}
+	}
+	// the ctime comes from a coarse clock that lags time.Now a little
+	ctime := time.Unix(st.Ctim.Unix())
+	if !ctime.Before(scanned.Add(-time.Second)) {
+		return time.Time{}
+	}
+	return ctime
 }
 
-func getProcessInfo(pid int) (*processInfo, error) {
+func (p procFS) getProcessInfo(pid int) (*processInfo, error) {
 	info := &processInfo{pid: pid}
-	pidStr := strconv.Itoa(pid)
 
-	commPath := filepath.Join("/proc", pidStr, "comm")
+	commPath := p.pidPath(pid, "comm")
 	commData, err := os.ReadFile(commPath)
 	if err == nil && len(commData) > 0 {
 		info.command = strings.TrimSpace(string(commData))
 	}
 
-	cmdlinePath := filepath.Join("/proc", pidStr, "cmdline")
+	cmdlinePath := p.pidPath(pid, "cmdline")
 	cmdlineData, err := os.ReadFile(cmdlinePath)
 	if err == nil && len(cmdlineData) > 0 {
 		parts := bytes.Split(cmdlineData, []byte{0})
@@ -282,13 +619,22 @@ func getProcessInfo(pid int) (*processInfo, error) {
 		return nil, err
 	}
 
-	cwdPath := filepath.Join("/proc", pidStr, "cwd")
+	info.container, info.unit = p.readCgroup(pid)
+
+	cwdPath := p.pidPath(pid, "cwd")
 	cwdLink, err := os.Readlink(cwdPath)
 	if err == nil {
 		info.cwd = cwdLink
 	}
 
-	statusPath := filepath.Join("/proc", pidStr, "status")
+	if stat, err := p.readProcStat(pid); err == nil {
+		info.ppid = stat.ppid
+		info.startTime = p.startTime(stat.startTime)
+	}
+	info.exe, info.exeDeleted = p.readExe(pid)
+	info.label = p.readSecurityLabel(pid)
+
+	statusPath := p.pidPath(pid, "status")
 	statusFile, err := os.Open(statusPath)
 	if err != nil {
 		return info, nil
@@ -307,14 +653,44 @@ func getProcessInfo(pid int) (*processInfo, error) {
 					info.user = lookupUsername(uid)
 				}
 			}
-			break
+		} else if value, ok := strings.CutPrefix(line, "CapEff:"); ok {
+			info.capEff = strings.TrimSpace(value)
+			break // comes after Uid
 		}
 	}
 
 	return info, nil
 }
 
-func parseProcNet(path, proto string, ipVersion int, inodeMap map[int64]*processInfo) ([]Connection, error) {
+// readExe resolves /proc/<pid>/exe. the kernel appends " (deleted)" when the
+// binary was removed or replaced since the process started.
+func (p procFS) readExe(pid int) (string, bool) {
+	exe, err := os.Readlink(p.pidPath(pid, "exe"))
+	if err != nil {
+		return "", false
+	}
+	if trimmed, ok := strings.CutSuffix(exe, " (deleted)"); ok {
+		return trimmed, true
+	}
+	return exe, false
+}
+
+// readSecurityLabel returns the selinux context or apparmor profile of a
+// process. newer kernels give each lsm its own attr directory.
+func (p procFS) readSecurityLabel(pid int) string {
+	for _, rel := range []string{"attr/current", "attr/apparmor/current", "attr/selinux/current"} {
+		data, err := os.ReadFile(p.pidPath(pid, rel))
+		if err != nil {
+			continue
+		}
+		if label := strings.TrimRight(string(data), "\x00\n"); label != "" {
+			return label
+		}
+	}
+	return ""
+}
+
+func parseProcNet(path, proto string, ipVersion int, inodeMap map[int64][]inodeEntry) ([]Connection, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -352,35 +728,32 @@ func parseProcNet(path, proto string, ipVersion int, inodeMap map[int64]*process
 
 		inode, _ := strconv.ParseInt(fields[9], 10, 64)
 
-		// refine udp state: if unconnected and remote is wildcard, it's listening
-		if strings.HasPrefix(proto, "udp") && state == "UNCONNECTED" {
-			if remoteAddr == "*" && remotePort == 0 {
-				state = "LISTEN"
-			}
-		}
+		// tx_queue:rx_queue and tr:tm->when, all hex
+		sendQ, recvQ := parseHexPair(fields[4])
+		timer, when := parseHexPair(fields[5])
+		retransmits, _ := strconv.ParseInt(fields[6], 16, 64)
 
 		conn := Connection{
-			TS:        time.Now(),
-			Proto:     proto,
-			IPVersion: fmt.Sprintf("IPv%d", ipVersion),
-			State:     state,
-			Laddr:     localAddr,
-			Lport:     localPort,
-			Raddr:     remoteAddr,
-			Rport:     remotePort,
-			Inode:     inode,
+			TS:          time.Now(),
+			Proto:       proto,
+			IPVersion:   fmt.Sprintf("IPv%d", ipVersion),
+			State:       state,
+			Laddr:       localAddr,
+			Lport:       localPort,
+			Raddr:       remoteAddr,
+			Rport:       remotePort,
+			Inode:       inode,
+			SendQ:       sendQ,
+			RecvQ:       recvQ,
+			Timer:       timerName(timer),
+			Retransmits: int(retransmits),
 		}
-
-		if procInfo, exists := inodeMap[inode]; exists {
-			conn.PID = procInfo.pid
-			conn.Process = procInfo.command
-			conn.Cmdline = procInfo.cmdline
-			conn.Cwd = procInfo.cwd
-			conn.UID = procInfo.uid
-			conn.User = procInfo.user
+		if timer != 0 {
+			// tm->when is printed in clock ticks
+			conn.TimerExpiresMs = when * 1000 / userHZ
 		}
 
-		conn.Interface = guessNetworkInterface(localAddr)
+		finishConnection(&conn, inodeMap)
 
 		connections = append(connections, conn)
 	}
@@ -388,12 +761,81 @@ func parseProcNet(path, proto string, ipVersion int, inodeMap map[int64]*process
 	return connections, scanner.Err()
 }
 
+// finishConnection applies the steps shared by every backend once the
+// socket fields are known: state refinement and process attribution
+func finishConnection(conn *Connection, inodeMap map[int64][]inodeEntry) {
+	// refine udp state: if unconnected and remote is wildcard, it's listening
+	if strings.HasPrefix(conn.Proto, "udp") && conn.State == "UNCONNECTED" {
+		if conn.Raddr == "*" && conn.Rport == 0 {
+			conn.State = "LISTEN"
+		}
+	}
+
+	attributeConnection(conn, inodeMap)
+}
+
+// attributeConnection fills in the processes holding the socket, if known
+func attributeConnection(conn *Connection, inodeMap map[int64][]inodeEntry) {
+	holders := inodeMap[conn.Inode]
+	if len(holders) == 0 {
+		return
+	}
+
+	procInfo := holders[0].info
+	// init only holds sockets to activate a service on them, and that
+	// service, once started, is the more useful owner
+	for _, h := range holders {
+		if h.info.pid != 1 {
+			procInfo = h.info
+			break
+		}
+	}
+	conn.PID = procInfo.pid
+	conn.Process = procInfo.command
+	conn.Cmdline = procInfo.cmdline
+	conn.Cwd = procInfo.cwd
+	conn.UID = procInfo.uid
+	conn.User = procInfo.user
+	conn.PPID = procInfo.ppid
+	conn.StartTime = procInfo.startTime
+	conn.Exe = procInfo.exe
+	conn.ExeDeleted = procInfo.exeDeleted
+	conn.CapEff = procInfo.capEff
+	conn.SecurityLabel = procInfo.label
+	conn.ContainerID = procInfo.container.id
+	conn.ContainerRuntime = procInfo.container.runtime
+	conn.PodUID = procInfo.container.podUID
+	conn.PodQoS = procInfo.container.podQoS
+	conn.Unit = procInfo.unit
+
+	// the earliest lookup of one of its fds, or else the start of the oldest
+	// process holding it: the socket is no older than that
+	var started time.Time
+	conn.Holders = make([]Holder, len(holders))
+	for i, h := range holders {
+		conn.Holders[i] = Holder{PID: h.info.pid, FD: h.fd, Process: h.info.command}
+		if !h.opened.IsZero() && (conn.FirstSeen.IsZero() || h.opened.Before(conn.FirstSeen)) {
+			conn.FirstSeen = h.opened
+		}
+		if !h.info.startTime.IsZero() && (started.IsZero() || h.info.startTime.Before(started)) {
+			started = h.info.startTime
+		}
+	}
+	if conn.FirstSeen.IsZero() {
+		conn.FirstSeen = started
+	}
+}
+
 func parseState(hexState, proto string) string {
 	state, err := strconv.ParseInt(hexState, 16, 32)
 	if err != nil {
 		return ""
 	}
+	return stateName(state, proto)
+}
 
+// stateName maps a kernel socket state number to its display name
+func stateName(state int64, proto string) string {
 	tcpStates := map[int64]string{
 		0x01: "ESTABLISHED",
 		0x02: "SYN_SENT",
@@ -406,6 +848,7 @@ func parseState(hexState, proto string) string {
 		0x09: "LAST_ACK",
 		0x0A: "LISTEN",
 		0x0B: "CLOSING",
+		0x0C: "SYN_RECV", // TCP_NEW_SYN_RECV, only reported over netlink
 	}
 
 	if strings.HasPrefix(proto, "tcp") {
@@ -431,6 +874,33 @@ func parseState(hexState, proto string) string {
 	return ""
 }
 
+// timerName maps the kernel's timer kind (the "tr" column in /proc/net/tcp,
+// idiag_timer over netlink) to the names ss uses
+func timerName(kind int64) string {
+	switch kind {
+	case 0:
+		return "off"
+	case 1:
+		return "on"
+	case 2:
+		return "keepalive"
+	case 3:
+		return "timewait"
+	case 4:
+		return "persist"
+	default:
+		return "unknown"
+	}
+}
+
+// parseHexPair parses a "hex:hex" column such as tx_queue:rx_queue
+func parseHexPair(s string) (int64, int64) {
+	a, b, _ := strings.Cut(s, ":")
+	x, _ := strconv.ParseInt(a, 16, 64)
+	y, _ := strconv.ParseInt(b, 16, 64)
+	return x, y
+}
+
 func parseHexAddr(hexAddr string) (string, int, error) {
 	parts := strings.Split(hexAddr, ":")
 	if len(parts) != 2 {
@@ -483,44 +953,119 @@ func parseHexAddr(hexAddr string) (string, int, error) {
 	return "", 0, fmt.Errorf("unsupported address format")
 }
 
+// GetUnixSockets returns unix domain sockets, read through the global
+// collector's proc root when it is a DefaultCollector
 func GetUnixSockets() ([]Connection, error) {
-	connections := []Connection{}
+	if dc, ok := globalCollector.(*DefaultCollector); ok {
+		return dc.GetUnixSockets()
+	}
+	return (&DefaultCollector{}).GetUnixSockets()
+}
 
-	file, err := os.Open("/proc/net/unix")
+// GetUnixSockets returns the unix domain sockets listed under the proc root,
+// attributed to the processes holding them
+func (dc *DefaultCollector) GetUnixSockets() ([]Connection, error) {
+	inodeMap, _, err := dc.inodeMap()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build inode map: %w", err)
+	}
+	conns, err := parseProcUnix(dc.proc.path("net", "unix"), inodeMap)
 	if err != nil {
-		return connections, nil
+		return nil, err
+	}
+	if dc.inodes != nil {
+		dc.inodes.resolve(dc.proc, conns, inodeMap)
+	}
+	dc.attributeSocketUnits(conns)
+	setIDs(conns)
+	dc.unixAges.observe(conns)
+	return conns, nil
+}
+
+// flags and states of /proc/net/unix, from linux/net.h
+const (
+	unixAcceptCon = 0x10000 // __SO_ACCEPTCON, set on listening sockets
+
+	unixUnconnected   = 1 // SS_UNCONNECTED
+	unixConnecting    = 2 // SS_CONNECTING
+	unixConnected     = 3 // SS_CONNECTED
+	unixDisconnecting = 4 // SS_DISCONNECTING
+)
+
+// parseProcUnix reads a /proc/net/unix file. the columns are
+// Num RefCount Protocol Flags Type St Inode Path, all hex but the inode.
+func parseProcUnix(path string, inodeMap map[int64][]inodeEntry) ([]Connection, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
 	defer errutil.Close(file)
 
+	now := time.Now()
+	connections := []Connection{}
 	scanner := bufio.NewScanner(file)
-	scanner.Scan()
+	scanner.Scan() // header
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		fields := strings.Fields(line)
+		fields := strings.Fields(scanner.Text())
 		if len(fields) < 7 {
 			continue
 		}
 
+		flags, _ := strconv.ParseUint(fields[3], 16, 32)
+		sockType, _ := strconv.ParseUint(fields[4], 16, 16)
+		state, _ := strconv.ParseUint(fields[5], 16, 8)
 		inode, _ := strconv.ParseInt(fields[6], 10, 64)
-		path := ""
-		if len(fields) > 7 {
-			path = fields[7]
-		}
 
 		conn := Connection{
-			TS:        time.Now(),
-			Proto:     "unix",
-			Laddr:     path,
-			Raddr:     "",
-			State:     "CONNECTED",
-			Inode:     inode,
-			Interface: "unix",
+			TS:       now,
+			Proto:    "unix",
+			State:    unixStateName(flags, state),
+			SockType: unixTypeName(sockType),
+			Laddr:    unixPath(fields[7:]),
+			Inode:    inode,
 		}
-
+		finishConnection(&conn, inodeMap)
 		connections = append(connections, conn)
 	}
 
-	return connections, nil
+	return connections, scanner.Err()
 }
 
+func unixStateName(flags, state uint64) string {
+	if flags&unixAcceptCon != 0 {
+		return "LISTEN"
+	}
+	switch state {
+	case unixUnconnected:
+		return "UNCONNECTED"
+	case unixConnecting:
+		return "CONNECTING"
+	case unixConnected:
+		return "CONNECTED"
+	case unixDisconnecting:
+		return "DISCONNECTING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func unixTypeName(sockType uint64) string {
+	switch sockType {
+	case unix.SOCK_STREAM:
+		return "stream"
+	case unix.SOCK_DGRAM:
+		return "dgram"
+	case unix.SOCK_SEQPACKET:
+		return "seqpacket"
+	default:
+		return "unknown"
+	}
+}
+
+// unixPath rejoins the path column, which is empty for unnamed sockets and
+// may itself contain spaces. the kernel prints abstract names with a leading
+// '@' in place of the nul byte, the same form ss uses, so they are kept as is.
+func unixPath(fields []string) string {
+	return strings.Join(fields, " ")
+}
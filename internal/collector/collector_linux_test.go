@@ -0,0 +1,453 @@
+//go:build linux
+
+package collector
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// fakeProc builds a synthetic proc tree under a temp dir
+type fakeProc struct {
+	t    *testing.T
+	root string
+}
+
+func newFakeProc(t *testing.T) *fakeProc {
+	t.Helper()
+	return &fakeProc{t: t, root: t.TempDir()}
+}
+
+func (f *fakeProc) writeFile(rel, content string) {
+	f.t.Helper()
+	path := filepath.Join(f.root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		f.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// addProcess creates /proc/<pid> with comm, stat, cmdline, status and one
+// fd symlink per socket inode
+func (f *fakeProc) addProcess(pid int, comm string, uid int, inodes ...int64) {
+	f.t.Helper()
+	dir := strconv.Itoa(pid)
+	f.writeFile(filepath.Join(dir, "comm"), comm+"\n")
+	f.writeStat(pid, comm, 1000)
+	f.writeFile(filepath.Join(dir, "cmdline"), "/usr/bin/"+comm+"\x00--flag\x00")
+	f.writeFile(filepath.Join(dir, "status"), "Name:\t"+comm+"\nUid:\t"+strconv.Itoa(uid)+"\t"+strconv.Itoa(uid)+"\n")
+
+	fdDir := filepath.Join(f.root, dir, "fd")
+	if err := os.MkdirAll(fdDir, 0o755); err != nil {
+		f.t.Fatal(err)
+	}
+	for i, inode := range inodes {
+		target := "socket:[" + strconv.FormatInt(inode, 10) + "]"
+		if err := os.Symlink(target, filepath.Join(fdDir, strconv.Itoa(i+3))); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+}
+
+// writeStat writes /proc/<pid>/stat with the given start time
+func (f *fakeProc) writeStat(pid int, comm string, startTime uint64) {
+	f.t.Helper()
+	f.writeFile(filepath.Join(strconv.Itoa(pid), "stat"), strconv.Itoa(pid)+" ("+comm+") S 1 1 1 0 -1 4194560 0 0 0 0 0 0 0 0 20 0 1 0 "+
+		strconv.FormatUint(startTime, 10)+" 0 0\n")
+}
+
+// addSocket adds another socket fd to an existing fake process
+func (f *fakeProc) addSocket(pid, fd int, inode int64) {
+	f.t.Helper()
+	target := "socket:[" + strconv.FormatInt(inode, 10) + "]"
+	if err := os.Symlink(target, filepath.Join(f.root, strconv.Itoa(pid), "fd", strconv.Itoa(fd))); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// ownedBy is the inode map value for a socket held by a single process
+func ownedBy(pid int, command string) []inodeEntry {
+	return []inodeEntry{{fd: 3, info: &processInfo{pid: pid, command: command}}}
+}
+
+const procNetHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+
+func TestParseProcNetQueuesAndTimers(t *testing.T) {
+	content := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:1F90 00000000:0000 0A 00000000:00000005 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:C350 01 00001000:00000000 01:0000001E 00000003  1000        0 1002 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:1F90 0100007F:C351 01 00000000:00000000 02:00000BB8 00000000  1000        0 1003 1 0000000000000000 20 4 30 10 -1
+`
+	path := filepath.Join(t.TempDir(), "tcp")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	conns, err := parseProcNet(path, "tcp", 4, nil)
+	if err != nil {
+		t.Fatalf("parseProcNet() error: %v", err)
+	}
+	if len(conns) != 3 {
+		t.Fatalf("expected 3 connections, got %d", len(conns))
+	}
+
+	listen := conns[0]
+	if listen.RecvQ != 5 || listen.SendQ != 0 || listen.Timer != "off" || listen.TimerExpiresMs != 0 {
+		t.Errorf("listener: recvq=%d sendq=%d timer=%s expires=%d", listen.RecvQ, listen.SendQ, listen.Timer, listen.TimerExpiresMs)
+	}
+
+	stuck := conns[1]
+	if stuck.SendQ != 4096 || stuck.Timer != "on" || stuck.TimerExpiresMs != 300 || stuck.Retransmits != 3 {
+		t.Errorf("retransmitting: sendq=%d timer=%s expires=%d retransmits=%d", stuck.SendQ, stuck.Timer, stuck.TimerExpiresMs, stuck.Retransmits)
+	}
+
+	keepalive := conns[2]
+	if keepalive.Timer != "keepalive" || keepalive.TimerExpiresMs != 30000 {
+		t.Errorf("keepalive: timer=%s expires=%d", keepalive.Timer, keepalive.TimerExpiresMs)
+	}
+}
+
+func TestCollectorReadsProcRoot(t *testing.T) {
+	fp := newFakeProc(t)
+	fp.writeFile("net/tcp", procNetHeader+
+		"   0: 00000000:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 5555 1 0000000000000000 100 0 0 10 0\n"+
+		"   1: 0100007F:9C40 0100007F:0050 01 00000000:00000000 00:00000000 00000000  1000        0 6666 1 0000000000000000 20 4 30 10 -1\n")
+	fp.writeFile("net/tcp6", procNetHeader)
+	fp.writeFile("net/udp", procNetHeader)
+	fp.writeFile("net/udp6", procNetHeader)
+	fp.writeFile("net/unix", "Num       RefCount Protocol Flags    Type St Inode Path\n"+
+		"0000000000000000: 00000002 00000000 00010000 0001 01 7777 /run/app.sock\n")
+	fp.addProcess(4242, "nginx", 0, 5555, 7777)
+	fp.addProcess(4343, "curl", 0, 6666)
+
+	// netlink must not be used for a foreign tree, even when asked for
+	dc := NewDefaultCollector(Options{Backend: BackendNetlink, ProcRoot: fp.root})
+	conns, err := dc.GetConnections()
+	if err != nil {
+		t.Fatalf("GetConnections() error: %v", err)
+	}
+	if len(conns) != 2 {
+		t.Fatalf("expected 2 connections from the fake tree, got %d", len(conns))
+	}
+
+	byInode := map[int64]Connection{}
+	for _, c := range conns {
+		byInode[c.Inode] = c
+	}
+	if c := byInode[5555]; c.PID != 4242 || c.Process != "nginx" || c.State != "LISTEN" || c.Lport != 80 {
+		t.Errorf("listener not attributed: %+v", c)
+	}
+	if c := byInode[6666]; c.PID != 4343 || c.Cmdline != "/usr/bin/curl --flag" || c.Rport != 80 {
+		t.Errorf("client not attributed: %+v", c)
+	}
+
+	unixConns, err := dc.GetUnixSockets()
+	if err != nil {
+		t.Fatalf("GetUnixSockets() error: %v", err)
+	}
+	if len(unixConns) != 1 || unixConns[0].Laddr != "/run/app.sock" || unixConns[0].PID != 4242 {
+		t.Errorf("unexpected unix sockets: %+v", unixConns)
+	}
+
+	// with unix sockets enabled they are part of the regular results
+	dc = NewDefaultCollector(Options{ProcRoot: fp.root, IncludeUnix: true})
+	conns, err = dc.GetConnections()
+	if err != nil {
+		t.Fatalf("GetConnections() error: %v", err)
+	}
+	if len(conns) != 3 {
+		t.Errorf("expected 3 connections with unix sockets, got %d", len(conns))
+	}
+}
+
+func TestSharedSocketHolders(t *testing.T) {
+	fp := newFakeProc(t)
+	fp.addProcess(1003, "nginx", 33, 9001)
+	fp.addProcess(1001, "nginx", 0, 8000, 9001)
+	fp.addProcess(1002, "nginx", 33, 9001)
+	fp.addSocket(1001, 7, 9001) // dup'd in the master
+	fp.writeStat(1002, "nginx", 500)
+	fp.writeFile("stat", "cpu  1 2 3 4\nbtime 1700000100\nprocesses 42\n")
+
+	inodeMap, _, err := newProcFS(fp.root).scanAllInodes()
+	if err != nil {
+		t.Fatalf("scanAllInodes() error: %v", err)
+	}
+
+	// no fd link was looked up before, so the socket is dated from the
+	// oldest process holding it
+	conn := Connection{Inode: 9001}
+	attributeConnection(&conn, inodeMap)
+	if want := time.Unix(1700000105, 0); !conn.FirstSeen.Equal(want) {
+		t.Errorf("expected the age to be bounded by the oldest holder, got %v, want %v", conn.FirstSeen, want)
+	}
+	if conn.PID != 1001 || conn.UID != 0 {
+		t.Errorf("shared socket should be attributed to the lowest pid, got %+v", conn)
+	}
+
+	expected := []Holder{
+		{PID: 1001, FD: 4, Process: "nginx"},
+		{PID: 1001, FD: 7, Process: "nginx"},
+		{PID: 1002, FD: 3, Process: "nginx"},
+		{PID: 1003, FD: 3, Process: "nginx"},
+	}
+	if !reflect.DeepEqual(conn.Holders, expected) {
+		t.Errorf("holders = %+v, want %+v", conn.Holders, expected)
+	}
+
+	// a socket-activated service is preferred over init
+	fp.addProcess(1, "systemd", 0, 7001)
+	fp.addProcess(640, "sshd", 0, 7001)
+	inodeMap, _, _ = newProcFS(fp.root).scanAllInodes()
+	conn = Connection{Inode: 7001}
+	attributeConnection(&conn, inodeMap)
+	if conn.PID != 640 || len(conn.Holders) != 2 || conn.Holders[0].PID != 1 {
+		t.Errorf("expected sshd to own the activated socket, got %+v", conn)
+	}
+}
+
+func TestProcessMetadata(t *testing.T) {
+	fp := newFakeProc(t)
+	fp.writeFile("stat", "cpu  1 2 3 4\nbtime 1700000000\nprocesses 42\n")
+	fp.addProcess(22, "sshd", 0, 5001)
+	fp.writeStat(22, "sshd", 250)
+	fp.writeFile("22/status", "Name:\tsshd\nUid:\t0\t0\t0\t0\nCapInh:\t0000000000000000\nCapPrm:\t0000000000003000\nCapEff:\t0000000000003000\n")
+	fp.writeFile("22/attr/current", "unconfined\n")
+	if err := os.Symlink("/usr/sbin/sshd (deleted)", filepath.Join(fp.root, "22", "exe")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := newProcFS(fp.root).getProcessInfo(22)
+	if err != nil {
+		t.Fatalf("getProcessInfo() error: %v", err)
+	}
+	if info.ppid != 1 {
+		t.Errorf("ppid = %d, want 1", info.ppid)
+	}
+	if want := time.Unix(1700000002, int64(500*time.Millisecond)); !info.startTime.Equal(want) {
+		t.Errorf("startTime = %v, want %v", info.startTime, want)
+	}
+	if info.exe != "/usr/sbin/sshd" || !info.exeDeleted {
+		t.Errorf("exe = %q deleted=%v", info.exe, info.exeDeleted)
+	}
+	if info.capEff != "0000000000003000" || info.label != "unconfined" {
+		t.Errorf("capEff = %q, label = %q", info.capEff, info.label)
+	}
+	if names := CapabilityNames(info.capEff); !reflect.DeepEqual(names, []string{"CAP_NET_ADMIN", "CAP_NET_RAW"}) {
+		t.Errorf("CapabilityNames() = %v", names)
+	}
+}
+
+func TestParseProcUnix(t *testing.T) {
+	fp := newFakeProc(t)
+	fp.writeFile("net/unix", "Num       RefCount Protocol Flags    Type St Inode Path\n"+
+		"0000000000000000: 00000002 00000000 00010000 0001 01 100 /run/docker.sock\n"+
+		"0000000000000000: 00000003 00000000 00000000 0001 03 101\n"+
+		"0000000000000000: 00000002 00000000 00000000 0002 01 102 @/tmp/.X11-unix/X0\n"+
+		"0000000000000000: 00000002 00000000 00000000 0005 03 103 /run/my app.sock\n")
+
+	conns, err := parseProcUnix(filepath.Join(fp.root, "net", "unix"), map[int64][]inodeEntry{
+		100: ownedBy(10, "dockerd"),
+	})
+	if err != nil {
+		t.Fatalf("parseProcUnix() error: %v", err)
+	}
+	if len(conns) != 4 {
+		t.Fatalf("expected 4 sockets, got %d", len(conns))
+	}
+
+	expected := []struct {
+		state, sockType, laddr string
+		pid                    int
+	}{
+		{"LISTEN", "stream", "/run/docker.sock", 10},
+		{"CONNECTED", "stream", "", 0},
+		{"UNCONNECTED", "dgram", "@/tmp/.X11-unix/X0", 0},
+		{"CONNECTED", "seqpacket", "/run/my app.sock", 0},
+	}
+	for i, want := range expected {
+		c := conns[i]
+		if c.Proto != "unix" || c.State != want.state || c.SockType != want.sockType || c.Laddr != want.laddr || c.PID != want.pid {
+			t.Errorf("socket %d: got proto=%s state=%s type=%s laddr=%q pid=%d, want %+v",
+				i, c.Proto, c.State, c.SockType, c.Laddr, c.PID, want)
+		}
+	}
+}
+
+func TestProcFSPaths(t *testing.T) {
+	if got := (procFS{}).pidPath(1, "fd"); got != "/proc/1/fd" {
+		t.Errorf("zero procFS pidPath = %q", got)
+	}
+	if got := newProcFS("/host/proc/").path("net", "tcp"); got != "/host/proc/net/tcp" {
+		t.Errorf("path = %q", got)
+	}
+	if !newProcFS("/proc/").isLive() || newProcFS("/host/proc").isLive() {
+		t.Error("isLive misreports the proc root")
+	}
+}
+
+// setNetns links /proc/<dir>/ns/net to the given namespace inode
+func (f *fakeProc) setNetns(dir string, inode uint64) {
+	f.t.Helper()
+	nsDir := filepath.Join(f.root, dir, "ns")
+	if err := os.MkdirAll(nsDir, 0o755); err != nil {
+		f.t.Fatal(err)
+	}
+	target := "net:[" + strconv.FormatUint(inode, 10) + "]"
+	if err := os.Symlink(target, filepath.Join(nsDir, "net")); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func TestCollectAllNamespaces(t *testing.T) {
+	fp := newFakeProc(t)
+	for _, table := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		fp.writeFile(filepath.Join("net", table), procNetHeader)
+		fp.writeFile(filepath.Join("5000", "net", table), procNetHeader)
+	}
+	fp.writeFile("net/tcp", procNetHeader+
+		"   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1111 1 0000000000000000 100 0 0 10 0\n")
+	fp.writeFile("5000/net/tcp", procNetHeader+
+		"   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 2222 1 0000000000000000 100 0 0 10 0\n")
+
+	fp.addProcess(1, "init", 0)
+	fp.addProcess(4242, "sshd", 0, 1111)
+	fp.addProcess(5000, "app", 0, 2222)
+	fp.addProcess(5001, "sidecar", 0)
+	fp.setNetns("1", 100)
+	fp.setNetns("4242", 100)
+	fp.setNetns("self", 100)
+	fp.setNetns("5000", 200)
+	fp.setNetns("5001", 200)
+
+	namespaces, err := newProcFS(fp.root).netNamespaces()
+	if err != nil {
+		t.Fatalf("netNamespaces() error: %v", err)
+	}
+	if len(namespaces) != 2 || namespaces[0].name != HostNamespace || namespaces[1].name != "200" {
+		t.Fatalf("unexpected namespaces: %+v", namespaces)
+	}
+	if len(namespaces[1].pids) != 2 {
+		t.Errorf("expected both pids of namespace 200, got %v", namespaces[1].pids)
+	}
+
+	conns, err := NewDefaultCollector(Options{ProcRoot: fp.root, AllNamespaces: true}).GetConnections()
+	if err != nil {
+		t.Fatalf("GetConnections() error: %v", err)
+	}
+	got := map[int]string{}
+	for _, c := range conns {
+		got[c.Lport] = c.Namespace + "/" + c.Process
+	}
+	if got[22] != "host/sshd" || got[8080] != "200/app" || len(got) != 2 {
+		t.Errorf("unexpected sockets: %v", got)
+	}
+
+	// without all namespaces only the own one is read, but still tagged
+	conns, err = NewDefaultCollector(Options{ProcRoot: fp.root}).GetConnections()
+	if err != nil {
+		t.Fatalf("GetConnections() error: %v", err)
+	}
+	if len(conns) != 1 || conns[0].Namespace != HostNamespace {
+		t.Errorf("expected only the host listener, got %+v", conns)
+	}
+}
+
+func TestCollectEnteredNamespace(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating and entering a namespace needs root")
+	}
+
+	// a namespace no process lives in, like one from `ip netns add`, with a
+	// socket this process holds in it
+	mount := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(mount, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	type created struct {
+		conn net.PacketConn
+		err  error
+	}
+	done := make(chan created, 1)
+	go func() {
+		runtime.LockOSThread()
+		own, err := unix.Open("/proc/thread-self/ns/net", unix.O_RDONLY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			done <- created{err: err}
+			return
+		}
+		defer func() { _ = unix.Close(own) }()
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			done <- created{err: err}
+			return
+		}
+		c := created{err: unix.Mount("/proc/thread-self/ns/net", mount, "", unix.MS_BIND, "")}
+		if c.err == nil {
+			c.conn, c.err = net.ListenPacket("udp4", "0.0.0.0:0")
+		}
+		// the thread stays locked, and exits with the goroutine, unless it is back home
+		if unix.Setns(own, unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+		done <- c
+	}()
+	c := <-done
+	if c.err != nil {
+		t.Skipf("cannot create a namespace: %v", c.err)
+	}
+	t.Cleanup(func() {
+		_ = c.conn.Close()
+		_ = unix.Unmount(mount, unix.MNT_DETACH)
+	})
+	port := c.conn.LocalAddr().(*net.UDPAddr).Port
+
+	for _, backend := range []string{BackendNetlink, BackendProc} {
+		dc := NewDefaultCollector(Options{Backend: backend})
+		conns, err := dc.collectEnteredNamespace(mount, map[int64][]inodeEntry{})
+		if err != nil {
+			t.Fatalf("%s: %v", backend, err)
+		}
+		found := false
+		for _, conn := range conns {
+			found = found || (conn.Proto == "udp" && conn.Lport == port)
+		}
+		if !found {
+			t.Errorf("%s: expected the udp socket on port %d in the entered namespace, got %d sockets", backend, port, len(conns))
+		}
+	}
+
+	// no thread is left behind in the entered namespace
+	self, err := os.Readlink("/proc/self/ns/net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, _ := os.ReadDir("/proc/self/task")
+	for _, task := range tasks {
+		if ns, err := os.Readlink(filepath.Join("/proc/self/task", task.Name(), "ns", "net")); err == nil && ns != self {
+			t.Errorf("thread %s is still in %s", task.Name(), ns)
+		}
+	}
+}
+
+func TestFdLookedUp(t *testing.T) {
+	link := filepath.Join(t.TempDir(), "3")
+	if err := os.Symlink("socket:[1]", link); err != nil {
+		t.Fatal(err)
+	}
+	if opened := fdLookedUp(link, time.Now()); !opened.IsZero() {
+		t.Errorf("a link created by the scan says nothing about the socket, got %v", opened)
+	}
+	if opened := fdLookedUp(link, time.Now().Add(time.Minute)); opened.IsZero() {
+		t.Error("expected a link looked up before the scan to date the socket")
+	}
+}
@@ -112,7 +112,7 @@ func BenchmarkBuildInodeMap(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = buildInodeToProcessMap()
+		_, _ = procFS{}.buildInodeToProcessMap()
 	}
 }
 
@@ -158,7 +158,7 @@ func TestConnectionHasCmdlineAndCwd(t *testing.T) {
 
 func TestGetProcessInfoPopulatesCmdlineAndCwd(t *testing.T) {
 	// test that getProcessInfo correctly populates cmdline and cwd for our own process
-	info, err := getProcessInfo(1) // init process (usually has cwd of /)
+	info, err := procFS{}.getProcessInfo(1) // init process (usually has cwd of /)
 	if err != nil {
 		t.Logf("could not get process info for pid 1: %v", err)
 		t.Skip("skipping - may not have permission")
@@ -0,0 +1,345 @@
+//go:build linux
+
+package collector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/karol-broda/snitch/internal/errutil"
+	"golang.org/x/sys/unix"
+)
+
+// ctnetlink message and attribute types from linux/netfilter/nfnetlink_conntrack.h
+const (
+	ipctnlMsgCtGet = 1
+
+	ctaTupleOrig  = 1
+	ctaTupleReply = 2
+	ctaMark       = 8
+	ctaZone       = 18
+
+	ctaTupleIP    = 1
+	ctaTupleProto = 2
+
+	ctaIPv4Src = 1
+	ctaIPv4Dst = 2
+	ctaIPv6Src = 3
+	ctaIPv6Dst = 4
+
+	ctaProtoNum     = 1
+	ctaProtoSrcPort = 2
+	ctaProtoDstPort = 3
+)
+
+// conntrackProtos are the transport protocols whose entries have ports, by
+// ip protocol number
+var conntrackProtos = map[uint8]string{
+	unix.IPPROTO_TCP:     "tcp",
+	unix.IPPROTO_UDP:     "udp",
+	unix.IPPROTO_UDPLITE: "udplite",
+	unix.IPPROTO_SCTP:    "sctp",
+}
+
+// conntrackEntry is one flow of the netfilter connection tracking table
+type conntrackEntry struct {
+	proto    string // tcp, udp, udplite or sctp
+	original tupleKey
+	reply    tupleKey
+	mark     uint32
+	zone     uint16
+}
+
+// tupleKey is a flow as conntrack sees it, with unmapped addresses
+type tupleKey struct {
+	src, dst     netip.Addr
+	sport, dport uint16
+}
+
+// conntrackTable indexes entries by both of their tuples
+type conntrackTable struct {
+	byOriginal map[string]*conntrackEntry
+	byReply    map[string]*conntrackEntry
+}
+
+func newConntrackTable(entries []conntrackEntry) *conntrackTable {
+	t := &conntrackTable{
+		byOriginal: make(map[string]*conntrackEntry, len(entries)),
+		byReply:    make(map[string]*conntrackEntry, len(entries)),
+	}
+	for i := range entries {
+		e := &entries[i]
+		t.byOriginal[e.proto+" "+e.original.String()] = e
+		t.byReply[e.proto+" "+e.reply.String()] = e
+	}
+	return t
+}
+
+func (k tupleKey) String() string {
+	return netip.AddrPortFrom(k.src, k.sport).String() + "->" + netip.AddrPortFrom(k.dst, k.dport).String()
+}
+
+func (k tupleKey) tuple() ConntrackTuple {
+	return ConntrackTuple{Src: k.src.String(), Sport: int(k.sport), Dst: k.dst.String(), Dport: int(k.dport)}
+}
+
+// apply attaches its conntrack entry to every connection that has one and
+// sets the connection's mark from it. a socket that opened the flow matches
+// the original tuple; one that accepted it matches the reply tuple, which
+// conntrack rewrites to where the flow really goes after dnat.
+func (t *conntrackTable) apply(conns []Connection) {
+	for i := range conns {
+		c := &conns[i]
+		proto, ok := conntrackProto(c.Proto)
+		if !ok || c.Lport == 0 || c.Rport == 0 {
+			continue
+		}
+		laddr, err1 := parseConnAddr(c.Laddr)
+		raddr, err2 := parseConnAddr(c.Raddr)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		key := proto + " " + tupleKey{src: laddr, dst: raddr, sport: uint16(c.Lport), dport: uint16(c.Rport)}.String()
+
+		if e := t.byOriginal[key]; e != nil {
+			// outgoing: the peer sees us as the reply's destination
+			c.Conntrack = e.conntrack(e.reply.dst, e.reply.dport, e.reply.src, e.reply.sport)
+		} else if e := t.byReply[key]; e != nil {
+			// incoming: the peer addressed the original destination
+			c.Conntrack = e.conntrack(e.original.dst, e.original.dport, e.original.src, e.original.sport)
+		} else {
+			continue
+		}
+		c.Mark = fmt.Sprintf("0x%x", c.Conntrack.Mark)
+	}
+}
+
+func (e *conntrackEntry) conntrack(local netip.Addr, lport uint16, remote netip.Addr, rport uint16) *Conntrack {
+	ct := &Conntrack{
+		Original: e.original.tuple(),
+		Reply:    e.reply.tuple(),
+		Mark:     e.mark,
+		Zone:     e.zone,
+		NATLaddr: local.String(),
+		NATLport: int(lport),
+		NATRaddr: remote.String(),
+		NATRport: int(rport),
+	}
+
+	// the reply of an untouched flow is the original turned around
+	dnat := e.reply.src != e.original.dst || e.reply.sport != e.original.dport
+	snat := e.reply.dst != e.original.src || e.reply.dport != e.original.sport
+	switch {
+	case snat && dnat:
+		ct.NAT = "snat+dnat"
+	case snat:
+		ct.NAT = "snat"
+	case dnat:
+		ct.NAT = "dnat"
+	}
+	return ct
+}
+
+// conntrackProto maps a connection's protocol, e.g. tcp6, to conntrack's
+func conntrackProto(proto string) (string, bool) {
+	base := strings.TrimSuffix(proto, "6")
+	for _, p := range conntrackProtos {
+		if p == base {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// parseConnAddr reads an address as the collectors format it, unmapping
+// v4-mapped addresses the way conntrack stores them
+func parseConnAddr(s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return addr, err
+	}
+	return addr.Unmap(), nil
+}
+
+// readConntrack reads the conntrack table of one namespace: over ctnetlink
+// when useNetlink is set, which only reaches snitch's own namespace, and
+// from nf_conntrack in netDir otherwise or when ctnetlink is unavailable
+func readConntrack(netDir string, useNetlink bool) (*conntrackTable, error) {
+	if useNetlink {
+		entries, err := dumpConntrack()
+		if err == nil {
+			return newConntrackTable(entries), nil
+		}
+		if debugTiming {
+			fmt.Fprintf(os.Stderr, "[timing] ctnetlink unavailable, using /proc: %v\n", err)
+		}
+	}
+
+	entries, err := parseProcConntrack(filepath.Join(netDir, "nf_conntrack"))
+	if err != nil {
+		return nil, err
+	}
+	return newConntrackTable(entries), nil
+}
+
+// dumpConntrack lists the conntrack table over NETLINK_NETFILTER
+func dumpConntrack() ([]conntrackEntry, error) {
+	nl, err := dialNetlink(unix.NETLINK_NETFILTER)
+	if err != nil {
+		return nil, err
+	}
+	defer errutil.Close(nl)
+
+	// struct nfgenmsg: every l3 family, version 0, resource id 0
+	req := []byte{unix.AF_UNSPEC, unix.NFNETLINK_V0, 0, 0}
+
+	var entries []conntrackEntry
+	msgType := uint16(unix.NFNL_SUBSYS_CTNETLINK<<8 | ipctnlMsgCtGet)
+	err = nl.dump(msgType, req, func(payload []byte) error {
+		if len(payload) < 4 {
+			return nil
+		}
+		if e, ok := parseConntrackAttrs(parseNetlinkAttrs(payload[4:])); ok {
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func parseConntrackAttrs(attrs map[uint16][]byte) (conntrackEntry, bool) {
+	var e conntrackEntry
+	original, proto, ok := parseConntrackTuple(attrs[ctaTupleOrig])
+	if !ok {
+		return e, false
+	}
+	reply, _, ok := parseConntrackTuple(attrs[ctaTupleReply])
+	if !ok {
+		return e, false
+	}
+	e.proto, e.original, e.reply = proto, original, reply
+
+	if b := attrs[ctaMark]; len(b) >= 4 {
+		e.mark = binary.BigEndian.Uint32(b)
+	}
+	if b := attrs[ctaZone]; len(b) >= 2 {
+		e.zone = binary.BigEndian.Uint16(b)
+	}
+	return e, true
+}
+
+func parseConntrackTuple(b []byte) (tupleKey, string, bool) {
+	var k tupleKey
+	attrs := parseNetlinkAttrs(b)
+
+	ip := parseNetlinkAttrs(attrs[ctaTupleIP])
+	switch {
+	case len(ip[ctaIPv4Src]) == 4 && len(ip[ctaIPv4Dst]) == 4:
+		k.src = netip.AddrFrom4([4]byte(ip[ctaIPv4Src]))
+		k.dst = netip.AddrFrom4([4]byte(ip[ctaIPv4Dst]))
+	case len(ip[ctaIPv6Src]) == 16 && len(ip[ctaIPv6Dst]) == 16:
+		k.src = netip.AddrFrom16([16]byte(ip[ctaIPv6Src])).Unmap()
+		k.dst = netip.AddrFrom16([16]byte(ip[ctaIPv6Dst])).Unmap()
+	default:
+		return k, "", false
+	}
+
+	l4 := parseNetlinkAttrs(attrs[ctaTupleProto])
+	if len(l4[ctaProtoNum]) < 1 || len(l4[ctaProtoSrcPort]) < 2 || len(l4[ctaProtoDstPort]) < 2 {
+		return k, "", false
+	}
+	proto, ok := conntrackProtos[l4[ctaProtoNum][0]]
+	if !ok {
+		return k, "", false
+	}
+	k.sport = binary.BigEndian.Uint16(l4[ctaProtoSrcPort])
+	k.dport = binary.BigEndian.Uint16(l4[ctaProtoDstPort])
+	return k, proto, true
+}
+
+// parseProcConntrack reads /proc/net/nf_conntrack, where each line is
+//
+//	ipv4 2 tcp 6 431999 ESTABLISHED src=10.0.0.2 dst=1.1.1.1 sport=51234 dport=443 src=1.1.1.1 dst=192.168.1.5 sport=443 dport=51234 [ASSURED] mark=0 zone=0 use=2
+//
+// the first src/dst/sport/dport are the original tuple, the second the reply
+func parseProcConntrack(path string) ([]conntrackEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []conntrackEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		num, err := strconv.ParseUint(fields[3], 10, 8)
+		if err != nil {
+			continue
+		}
+		proto, ok := conntrackProtos[uint8(num)]
+		if !ok {
+			continue
+		}
+
+		e := conntrackEntry{proto: proto}
+		tuples := [2]*tupleKey{&e.original, &e.reply}
+		var addrs, ports int
+		for _, field := range fields[4:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "src", "dst":
+				if addrs >= 4 {
+					continue
+				}
+				addr, err := netip.ParseAddr(value)
+				if err != nil {
+					break
+				}
+				t := tuples[addrs/2]
+				if key == "src" {
+					t.src = addr.Unmap()
+				} else {
+					t.dst = addr.Unmap()
+				}
+				addrs++
+			case "sport", "dport":
+				if ports >= 4 {
+					continue
+				}
+				port, err := strconv.ParseUint(value, 10, 16)
+				if err != nil {
+					break
+				}
+				t := tuples[ports/2]
+				if key == "sport" {
+					t.sport = uint16(port)
+				} else {
+					t.dport = uint16(port)
+				}
+				ports++
+			case "mark":
+				if mark, err := strconv.ParseUint(value, 10, 32); err == nil {
+					e.mark = uint32(mark)
+				}
+			case "zone", "zone-orig":
+				if zone, err := strconv.ParseUint(value, 10, 16); err == nil {
+					e.zone = uint16(zone)
+				}
+			}
+		}
+		if addrs == 4 && ports == 4 {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package collector
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+const procConntrackSample = `ipv4     2 tcp      6 431999 ESTABLISHED src=172.17.0.2 dst=1.1.1.1 sport=51234 dport=443 src=1.1.1.1 dst=192.168.1.5 sport=443 dport=51234 [ASSURED] mark=16 zone=3 use=2
+ipv4     2 tcp      6 86399 ESTABLISHED src=203.0.113.9 dst=192.168.1.5 sport=40000 dport=8080 src=172.17.0.3 dst=203.0.113.9 sport=80 dport=40000 [ASSURED] mark=0 use=1
+ipv6     10 udp      17 29 src=2001:db8::1 dst=2001:db8::2 sport=5353 dport=53 [UNREPLIED] src=2001:db8::2 dst=2001:db8::1 sport=53 dport=5353 mark=0 use=1
+ipv4     2 icmp     1 29 src=10.0.0.1 dst=10.0.0.2 type=8 code=0 id=7 src=10.0.0.2 dst=10.0.0.1 type=0 code=0 id=7 mark=0 use=1
+`
+
+func TestParseProcConntrack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nf_conntrack")
+	if err := os.WriteFile(path, []byte(procConntrackSample), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := parseProcConntrack(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries with ports, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.proto != "tcp" || e.original.String() != "172.17.0.2:51234->1.1.1.1:443" || e.reply.String() != "1.1.1.1:443->192.168.1.5:51234" {
+		t.Errorf("unexpected tuples %s %s / %s", e.proto, e.original, e.reply)
+	}
+	if e.mark != 16 || e.zone != 3 {
+		t.Errorf("expected mark 16 and zone 3, got %d and %d", e.mark, e.zone)
+	}
+	if entries[2].proto != "udp" || entries[2].original.String() != "[2001:db8::1]:5353->[2001:db8::2]:53" {
+		t.Errorf("unexpected ipv6 entry %s %s", entries[2].proto, entries[2].original)
+	}
+}
+
+func TestConntrackApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nf_conntrack")
+	if err := os.WriteFile(path, []byte(procConntrackSample), 0644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := readConntrack(filepath.Dir(path), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conns := []Connection{
+		// a container connecting out through masquerade
+		{Proto: "tcp", Laddr: "172.17.0.2", Lport: 51234, Raddr: "1.1.1.1", Rport: 443},
+		// a container serving a published port, on a dual-stack socket
+		{Proto: "tcp6", Laddr: "0:0:0:0:0:ffff:ac11:3", Lport: 80, Raddr: "0:0:0:0:0:ffff:cb00:7109", Rport: 40000},
+		// no flow
+		{Proto: "tcp", Laddr: "10.0.0.1", Lport: 22, Raddr: "10.0.0.9", Rport: 50000},
+	}
+	table.apply(conns)
+
+	out := conns[0].Conntrack
+	if out == nil || out.NAT != "snat" || out.NATLaddr != "192.168.1.5" || out.NATLport != 51234 || conns[0].Mark != "0x10" {
+		t.Errorf("outgoing: unexpected conntrack %+v, mark %q", out, conns[0].Mark)
+	}
+	in := conns[1].Conntrack
+	if in == nil || in.NAT != "dnat" || in.NATLaddr != "192.168.1.5" || in.NATLport != 8080 || in.NATRaddr != "203.0.113.9" {
+		t.Errorf("incoming: unexpected conntrack %+v", in)
+	}
+	if conns[2].Conntrack != nil || conns[2].Mark != "" {
+		t.Errorf("expected no conntrack for a connection without flow, got %+v", conns[2].Conntrack)
+	}
+}
+
+func TestParseConntrackAttrs(t *testing.T) {
+	attr := func(typ uint16, value []byte) []byte {
+		b := make([]byte, nlmsgAlign(unix.NLA_HDRLEN+len(value)))
+		binary.NativeEndian.PutUint16(b[0:2], uint16(unix.NLA_HDRLEN+len(value)))
+		binary.NativeEndian.PutUint16(b[2:4], typ)
+		copy(b[unix.NLA_HDRLEN:], value)
+		return b
+	}
+	nested := func(typ uint16, attrs ...[]byte) []byte {
+		var value []byte
+		for _, a := range attrs {
+			value = append(value, a...)
+		}
+		return attr(typ|unix.NLA_F_NESTED, value)
+	}
+	port := func(p uint16) []byte { return binary.BigEndian.AppendUint16(nil, p) }
+	tuple := func(typ uint16, src, dst string, sport, dport uint16) []byte {
+		return nested(typ,
+			nested(ctaTupleIP, attr(ctaIPv4Src, net.ParseIP(src).To4()), attr(ctaIPv4Dst, net.ParseIP(dst).To4())),
+			nested(ctaTupleProto, attr(ctaProtoNum, []byte{unix.IPPROTO_TCP}), attr(ctaProtoSrcPort, port(sport)), attr(ctaProtoDstPort, port(dport))))
+	}
+
+	var msg []byte
+	msg = append(msg, tuple(ctaTupleOrig, "10.0.0.2", "1.1.1.1", 50000, 443)...)
+	msg = append(msg, tuple(ctaTupleReply, "1.1.1.1", "192.168.1.5", 443, 61000)...)
+	msg = append(msg, attr(ctaMark, binary.BigEndian.AppendUint32(nil, 0x2a))...)
+	msg = append(msg, attr(ctaZone, port(7))...)
+
+	e, ok := parseConntrackAttrs(parseNetlinkAttrs(msg))
+	if !ok {
+		t.Fatal("parseConntrackAttrs() rejected a valid entry")
+	}
+	if e.proto != "tcp" || e.original.String() != "10.0.0.2:50000->1.1.1.1:443" || e.reply.String() != "1.1.1.1:443->192.168.1.5:61000" {
+		t.Errorf("unexpected tuples %s %s / %s", e.proto, e.original, e.reply)
+	}
+	if e.mark != 0x2a || e.zone != 7 {
+		t.Errorf("expected mark 0x2a and zone 7, got %#x and %d", e.mark, e.zone)
+	}
+}
@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrDestroyEventsUnsupported is returned by WatchDestroyed when the
+// collector cannot report closing sockets as they happen
+var ErrDestroyEventsUnsupported = errors.New("socket destroy events are not supported by this collector")
+
+// DestroyWatcher is implemented by collectors that can report sockets as the
+// kernel destroys them, instead of only noticing they are gone on the next
+// GetConnections
+type DestroyWatcher interface {
+	WatchDestroyed(ctx context.Context) (<-chan Connection, error)
+}
+
+// WatchDestroyed streams the sockets the global collector sees destroyed
+// until ctx is done, when the channel is closed
+func WatchDestroyed(ctx context.Context) (<-chan Connection, error) {
+	if w, ok := globalCollector.(DestroyWatcher); ok {
+		return w.WatchDestroyed(ctx)
+	}
+	return nil, ErrDestroyEventsUnsupported
+}
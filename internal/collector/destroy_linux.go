@@ -0,0 +1,125 @@
+//go:build linux
+
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// destroy groups of NETLINK_SOCK_DIAG, one socket per protocol so that every
+// message can be told apart by its family alone
+var destroyGroups = []struct {
+	protocol uint8
+	groups   []int
+}{
+	{unix.IPPROTO_TCP, []int{unix.SKNLGRP_INET_TCP_DESTROY, unix.SKNLGRP_INET6_TCP_DESTROY}},
+	{unix.IPPROTO_UDP, []int{unix.SKNLGRP_INET_UDP_DESTROY, unix.SKNLGRP_INET6_UDP_DESTROY}},
+}
+
+// destroyPollTimeout bounds how long a read blocks, so a cancelled watch
+// notices within that time
+const destroyPollTimeout = 200 * time.Millisecond
+
+// WatchDestroyed subscribes to the kernel's socket destroy notifications for
+// tcp and udp. every event carries the socket's final state and, for tcp,
+// its final tcp_info counters; the process is unknown, since the socket no
+// longer has an inode. joining the groups needs CAP_NET_ADMIN.
+func (dc *DefaultCollector) WatchDestroyed(ctx context.Context) (<-chan Connection, error) {
+	if !dc.proc.isLive() {
+		return nil, ErrDestroyEventsUnsupported
+	}
+
+	conns := make([]*netlinkConn, 0, len(destroyGroups))
+	closeAll := func() {
+		for _, nl := range conns {
+			_ = nl.Close()
+		}
+	}
+	for _, g := range destroyGroups {
+		nl, err := dialNetlink(unix.NETLINK_SOCK_DIAG)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		conns = append(conns, nl)
+		if err := nl.subscribe(g.groups...); err != nil {
+			closeAll()
+			return nil, err
+		}
+		if err := nl.setReadTimeout(destroyPollTimeout); err != nil {
+			closeAll()
+			return nil, err
+		}
+	}
+
+	out := make(chan Connection, 64)
+	var wg sync.WaitGroup
+	for i, nl := range conns {
+		wg.Add(1)
+		go func(nl *netlinkConn, protocol uint8) {
+			defer wg.Done()
+			defer func() { _ = nl.Close() }()
+			nl.readDestroyed(ctx, protocol, out)
+		}(nl, destroyGroups[i].protocol)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+// readDestroyed forwards destroy notifications until ctx is done or the
+// socket fails. ENOBUFS means the kernel dropped events because we fell
+// behind; the rest still arrive, so it is not fatal.
+func (c *netlinkConn) readDestroyed(ctx context.Context, protocol uint8, out chan<- Connection) {
+	for ctx.Err() == nil {
+		n, _, err := unix.Recvfrom(c.fd, c.buf, 0)
+		if err != nil {
+			switch err {
+			case unix.EAGAIN, unix.EINTR:
+				continue
+			case unix.ENOBUFS:
+				if debugTiming {
+					fmt.Fprintf(os.Stderr, "[timing] socket destroy events dropped\n")
+				}
+				continue
+			default:
+				return
+			}
+		}
+
+		_, _ = walkNetlinkMessages(c.buf[:n], 0, func(payload []byte) error {
+			msg, err := parseInetDiagMsg(payload)
+			if err != nil {
+				return nil
+			}
+			t, ok := tableFor(msg.family, protocol)
+			if !ok {
+				return nil
+			}
+			select {
+			case out <- msg.connection(t, time.Now(), nil):
+			case <-ctx.Done():
+			}
+			return nil
+		})
+	}
+}
+
+// tableFor finds the inet table of a family and protocol
+func tableFor(family, protocol uint8) (inetTable, bool) {
+	for _, t := range inetTables {
+		if t.family == family && t.protocol == protocol && !t.procOnly {
+			return t, true
+		}
+	}
+	return inetTable{}, false
+}
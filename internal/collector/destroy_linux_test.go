@@ -0,0 +1,65 @@
+//go:build linux
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestWatchDestroyed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := NewDefaultCollector(Options{}).WatchDestroyed(ctx)
+	if errors.Is(err, unix.EPERM) || errors.Is(err, unix.EACCES) || errors.Is(err, unix.EPROTONOSUPPORT) {
+		t.Skipf("destroy events unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("WatchDestroyed() error: %v", err)
+	}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			_, _ = c.Write([]byte("hello"))
+			_ = c.Close()
+		}
+	}()
+
+	client, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lport := client.LocalAddr().(*net.TCPAddr).Port
+	buf := make([]byte, 5)
+	_, _ = client.Read(buf)
+	_ = client.Close()
+
+	for {
+		select {
+		case conn, ok := <-events:
+			if !ok {
+				t.Fatal("no destroy event for the closed client")
+			}
+			if conn.Proto == "tcp" && conn.Lport == lport {
+				// the peer's fin counts as a byte received too
+				if conn.RxBytes < 5 {
+					t.Errorf("expected the final counters, got rx=%d", conn.RxBytes)
+				}
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for a destroy event")
+		}
+	}
+}
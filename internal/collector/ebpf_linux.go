@@ -0,0 +1,744 @@
+//go:build linux
+
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/cilium/ebpf/rlimit"
+	"golang.org/x/sys/unix"
+)
+
+// the event the programs below send to user space:
+//
+//	u64 ts; u32 pid; u8 kind; u8 proto; u16 family; u16 lport; u16 rport;
+//	u8 oldstate; u8 pad[3]; u8 laddr[16]; u8 raddr[16]; char comm[16]
+//
+// ports are in host order. ipv4 addresses are stored v4-mapped.
+const (
+	evTS        = 0
+	evPID       = 8
+	evKind      = 12
+	evProto     = 13
+	evFamily    = 14
+	evLport     = 16
+	evRport     = 18
+	evOldState  = 20 // closes only
+	evLaddr     = 24
+	evRaddr     = 40
+	evComm      = 56
+	sizeofEvent = 72
+)
+
+const (
+	bpfConnect = 1
+	bpfAccept  = 2
+	bpfClose   = 3
+	bpfSend    = 4
+)
+
+var bpfEventKinds = map[uint8]string{
+	bpfConnect: SocketEventConnect,
+	bpfAccept:  SocketEventAccept,
+	bpfClose:   SocketEventClose,
+	bpfSend:    SocketEventSend,
+}
+
+// the ::ffff: prefix of a v4-mapped address, as the little-endian word at
+// byte 8 of the address
+const v4MappedPrefix = -0x10000
+
+// stack slots of the programs, below the frame pointer
+const (
+	stackEvent   = -sizeofEvent
+	stackKey     = stackEvent - 8 // the socket's address
+	stackScratch = stackKey - 16
+)
+
+// fields of the sock:inet_sock_set_state tracepoint. the ipv6 fields hold
+// v4-mapped addresses for ipv4 sockets.
+const (
+	tpSkaddr   = 8
+	tpOldstate = 16
+	tpNewstate = 20
+	tpSport    = 24
+	tpDport    = 26
+	tpFamily   = 28
+	tpProtocol = 30
+	tpSaddrV6  = 40
+	tpDaddrV6  = 56
+)
+
+// tcp states, from include/net/tcp_states.h
+const (
+	tcpEstablished = 1
+	tcpSynSent     = 2
+	tcpSynRecv     = 3
+	tcpClose       = 7
+	tcpListen      = 10
+)
+
+// udpSendInterval is how long a udp flow stays quiet before another send on
+// it is reported
+const udpSendInterval = time.Minute
+
+// kernelLayout is what the fentry/fexit programs need to know about the
+// running kernel, read from its btf: where struct sock_common keeps the
+// addresses, and how many arguments inet_csk_accept takes, which changed in
+// 6.10. the ipv6 fields depend on the kernel config and may be missing.
+type kernelLayout struct {
+	daddr, rcvSaddr, dport, num, family int16
+	v6Daddr, v6RcvSaddr                 int16 // -1 when unknown
+	acceptArgs                          int   // 0 when unknown
+}
+
+// socketTracer holds the loaded programs and their maps
+type socketTracer struct {
+	events  *ebpf.Map // ring buffer of events
+	owners  *ebpf.Map // socket address -> its connect or accept event
+	passive *ebpf.Map // socket address -> event of a passive open not yet accepted
+	progs   []*ebpf.Program
+	links   []link.Link
+}
+
+// WatchSocketEvents loads eBPF programs that report tcp connects, accepts
+// and closes and udp sends with the calling process. a close is attributed
+// to the process that connected or accepted the socket, when that happened
+// while tracing. loading them needs CAP_BPF and CAP_PERFMON, or root.
+func (dc *DefaultCollector) WatchSocketEvents(ctx context.Context) (<-chan SocketEvent, error) {
+	if !dc.proc.isLive() {
+		return nil, ErrSocketEventsUnsupported
+	}
+
+	tracer, err := newSocketTracer()
+	if err != nil {
+		return nil, err
+	}
+	rd, err := ringbuf.NewReader(tracer.events)
+	if err != nil {
+		tracer.Close()
+		return nil, err
+	}
+
+	boot, err := monotonicEpoch()
+	if err != nil {
+		_ = rd.Close()
+		tracer.Close()
+		return nil, err
+	}
+
+	out := make(chan SocketEvent, 64)
+	go func() {
+		<-ctx.Done()
+		_ = rd.Close()
+	}()
+	go func() {
+		defer close(out)
+		defer tracer.Close()
+
+		sends := make(udpFlows)
+		for {
+			record, err := rd.Read()
+			if err != nil {
+				if !errors.Is(err, ringbuf.ErrClosed) && debugTiming {
+					fmt.Fprintf(os.Stderr, "[timing] socket events: %v\n", err)
+				}
+				return
+			}
+			event, ok := decodeSocketEvent(record.RawSample, boot)
+			if !ok || (event.Kind == SocketEventSend && !sends.first(event.Connection)) {
+				continue
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// monotonicEpoch is the wall clock time at which CLOCK_MONOTONIC, the clock
+// of bpf_ktime_get_ns, was zero
+func monotonicEpoch() (time.Time, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(-time.Duration(ts.Nano())), nil
+}
+
+func decodeSocketEvent(raw []byte, boot time.Time) (SocketEvent, bool) {
+	if len(raw) < sizeofEvent {
+		return SocketEvent{}, false
+	}
+	kind, ok := bpfEventKinds[raw[evKind]]
+	if !ok {
+		return SocketEvent{}, false
+	}
+
+	proto := "tcp"
+	if raw[evProto] == unix.IPPROTO_UDP {
+		proto = "udp"
+	}
+	laddr := raw[evLaddr : evLaddr+16]
+	raddr := raw[evRaddr : evRaddr+16]
+	ipVersion := "IPv4"
+	switch binary.NativeEndian.Uint16(raw[evFamily:]) {
+	case unix.AF_INET:
+		laddr, raddr = laddr[12:], raddr[12:]
+	case unix.AF_INET6:
+		proto += "6"
+		ipVersion = "IPv6"
+	default:
+		return SocketEvent{}, false
+	}
+
+	rport := int(binary.NativeEndian.Uint16(raw[evRport:]))
+	if kind == SocketEventSend && rport == 0 {
+		return SocketEvent{}, false
+	}
+
+	comm := raw[evComm : evComm+16]
+	for i, b := range comm {
+		if b == 0 {
+			comm = comm[:i]
+			break
+		}
+	}
+
+	var state string
+	switch kind {
+	case SocketEventConnect, SocketEventAccept:
+		state = "ESTABLISHED"
+	case SocketEventClose:
+		state = "CLOSE"
+	}
+
+	conn := Connection{
+		TS:        boot.Add(time.Duration(binary.NativeEndian.Uint64(raw[evTS:]))),
+		Proto:     proto,
+		IPVersion: ipVersion,
+		State:     state,
+		Laddr:     formatSockAddr(laddr),
+		Lport:     int(binary.NativeEndian.Uint16(raw[evLport:])),
+		Raddr:     formatSockAddr(raddr),
+		Rport:     rport,
+		PID:       int(binary.NativeEndian.Uint32(raw[evPID:])),
+		Process:   string(comm),
+	}
+	conn.ID = ConnectionID(conn)
+	event := SocketEvent{Kind: kind, Connection: conn}
+	if kind == SocketEventClose {
+		event.OldState = stateName(int64(raw[evOldState]), "tcp")
+	}
+	return event, true
+}
+
+type udpFlow struct {
+	pid   int
+	proto string
+	lport int
+	raddr string
+	rport int
+}
+
+// udpFlows remembers when each udp flow was last reported. the local
+// address is not part of a flow, since unbound sockets only get one when
+// the kernel routes the datagram.
+type udpFlows map[udpFlow]time.Time
+
+func (f udpFlows) first(c Connection) bool {
+	key := udpFlow{pid: c.PID, proto: c.Proto, lport: c.Lport, raddr: c.Raddr, rport: c.Rport}
+	if last, ok := f[key]; ok && c.TS.Sub(last) < udpSendInterval {
+		return false
+	}
+
+	if len(f) >= 4096 {
+		for k, last := range f {
+			if c.TS.Sub(last) >= udpSendInterval {
+				delete(f, k)
+			}
+		}
+	}
+	f[key] = c.TS
+	return true
+}
+
+func newSocketTracer() (*socketTracer, error) {
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return nil, err
+	}
+
+	t := &socketTracer{}
+	var err error
+	if t.events, err = ebpf.NewMap(&ebpf.MapSpec{
+		Name:       "snitch_events",
+		Type:       ebpf.RingBuf,
+		MaxEntries: 1 << 20,
+	}); err != nil {
+		return nil, err
+	}
+	if t.owners, err = newEventMap("snitch_owners", 65536); err != nil {
+		t.Close()
+		return nil, err
+	}
+	if t.passive, err = newEventMap("snitch_passive", 8192); err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	// the state changes are what make connects and closes visible at all.
+	// accepts and udp sends need fentry/fexit, which need the kernel's btf,
+	// and are left out where they cannot be attached.
+	layout, haveBTF := readKernelLayout()
+	probes := []struct {
+		spec   ebpf.ProgramSpec
+		attach func(*ebpf.Program) (link.Link, error)
+	}{
+		{ebpf.ProgramSpec{Name: "snitch_state", Type: ebpf.TracePoint, Instructions: t.setStateProgram()},
+			func(p *ebpf.Program) (link.Link, error) {
+				return link.Tracepoint("sock", "inet_sock_set_state", p, nil)
+			}},
+	}
+	if haveBTF {
+		tracing := func(p *ebpf.Program) (link.Link, error) {
+			return link.AttachTracing(link.TracingOptions{Program: p})
+		}
+		if layout.acceptArgs > 0 {
+			probes = append(probes, struct {
+				spec   ebpf.ProgramSpec
+				attach func(*ebpf.Program) (link.Link, error)
+			}{ebpf.ProgramSpec{Name: "snitch_accept", Type: ebpf.Tracing, AttachType: ebpf.AttachTraceFExit,
+				AttachTo: "inet_csk_accept", Instructions: t.acceptProgram(layout.acceptArgs)}, tracing})
+		}
+		for _, v6 := range []bool{false, true} {
+			name, fn := "snitch_udp", "udp_sendmsg"
+			if v6 {
+				name, fn = "snitch_udp6", "udpv6_sendmsg"
+			}
+			probes = append(probes, struct {
+				spec   ebpf.ProgramSpec
+				attach func(*ebpf.Program) (link.Link, error)
+			}{ebpf.ProgramSpec{Name: name, Type: ebpf.Tracing, AttachType: ebpf.AttachTraceFEntry,
+				AttachTo: fn, Instructions: t.udpSendProgram(v6, layout)}, tracing})
+		}
+	}
+
+	for i, probe := range probes {
+		err := t.attach(&probe.spec, probe.attach)
+		if err == nil {
+			continue
+		}
+		if i == 0 {
+			t.Close()
+			return nil, err
+		}
+		if debugTiming {
+			fmt.Fprintf(os.Stderr, "[timing] socket events: %v\n", err)
+		}
+	}
+
+	return t, nil
+}
+
+func newEventMap(name string, entries uint32) (*ebpf.Map, error) {
+	return ebpf.NewMap(&ebpf.MapSpec{
+		Name:       name,
+		Type:       ebpf.LRUHash,
+		KeySize:    8,
+		ValueSize:  sizeofEvent,
+		MaxEntries: entries,
+	})
+}
+
+func (t *socketTracer) attach(spec *ebpf.ProgramSpec, attach func(*ebpf.Program) (link.Link, error)) error {
+	spec.License = "Dual MIT/GPL"
+	prog, err := ebpf.NewProgram(spec)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", spec.Name, err)
+	}
+	t.progs = append(t.progs, prog)
+
+	l, err := attach(prog)
+	if err != nil {
+		return fmt.Errorf("attach %s: %w", spec.Name, err)
+	}
+	t.links = append(t.links, l)
+	return nil
+}
+
+func (t *socketTracer) Close() {
+	for _, l := range t.links {
+		_ = l.Close()
+	}
+	for _, p := range t.progs {
+		_ = p.Close()
+	}
+	for _, m := range []*ebpf.Map{t.events, t.owners, t.passive} {
+		if m != nil {
+			_ = m.Close()
+		}
+	}
+}
+
+// readKernelLayout reads the kernel layout from btf. it reports false when
+// the kernel has no btf, and so no fentry/fexit either.
+func readKernelLayout() (kernelLayout, bool) {
+	layout := kernelLayout{v6Daddr: -1, v6RcvSaddr: -1}
+	spec, err := btf.LoadKernelSpec()
+	if err != nil {
+		return layout, false
+	}
+	var common *btf.Struct
+	if err := spec.TypeByName("sock_common", &common); err != nil {
+		return layout, false
+	}
+
+	fields := map[string]*int16{
+		"skc_daddr":        &layout.daddr,
+		"skc_rcv_saddr":    &layout.rcvSaddr,
+		"skc_dport":        &layout.dport,
+		"skc_num":          &layout.num,
+		"skc_family":       &layout.family,
+		"skc_v6_daddr":     &layout.v6Daddr,
+		"skc_v6_rcv_saddr": &layout.v6RcvSaddr,
+	}
+	for name, dst := range fields {
+		off, ok := memberOffset(common.Members, name, 0)
+		switch {
+		case ok:
+			*dst = int16(off)
+		case *dst != -1:
+			return layout, false
+		}
+	}
+
+	var accept *btf.Func
+	if err := spec.TypeByName("inet_csk_accept", &accept); err == nil {
+		if proto, ok := accept.Type.(*btf.FuncProto); ok {
+			layout.acceptArgs = len(proto.Params)
+		}
+	}
+	return layout, true
+}
+
+// memberOffset finds a member by name, looking into anonymous structs and
+// unions, and returns its byte offset
+func memberOffset(members []btf.Member, name string, base uint32) (uint32, bool) {
+	for _, m := range members {
+		off := base + m.Offset.Bytes()
+		if m.Name == name {
+			return off, true
+		}
+		if m.Name != "" {
+			continue
+		}
+		switch typ := btf.UnderlyingType(m.Type).(type) {
+		case *btf.Struct:
+			if found, ok := memberOffset(typ.Members, name, off); ok {
+				return found, true
+			}
+		case *btf.Union:
+			if found, ok := memberOffset(typ.Members, name, off); ok {
+				return found, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// setStateProgram follows tcp state changes. the process calling connect
+// is remembered at SYN_SENT, before the socket has a local port, and
+// reported once SYN_SENT turns ESTABLISHED. SYN_RECV to ESTABLISHED is a
+// passive open, kept until acceptProgram sees who accepts it. CLOSE ends
+// either.
+func (t *socketTracer) setStateProgram() asm.Instructions {
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMem(asm.R0, asm.R6, tpProtocol, asm.Half),
+		asm.JNE.Imm(asm.R0, unix.IPPROTO_TCP, "exit"),
+		asm.LoadMem(asm.R7, asm.R6, tpOldstate, asm.Word),
+		asm.LoadMem(asm.R8, asm.R6, tpNewstate, asm.Word),
+		asm.LoadMem(asm.R1, asm.R6, tpSkaddr, asm.DWord),
+		asm.StoreMem(asm.RFP, stackKey, asm.R1, asm.DWord),
+	}
+	insns = append(insns, zeroStack(stackEvent, sizeofEvent)...)
+	insns = append(insns,
+		asm.StoreImm(asm.RFP, stackEvent+evProto, unix.IPPROTO_TCP, asm.Byte),
+		asm.LoadMem(asm.R1, asm.R6, tpFamily, asm.Half),
+		asm.StoreMem(asm.RFP, stackEvent+evFamily, asm.R1, asm.Half),
+		asm.LoadMem(asm.R1, asm.R6, tpSport, asm.Half),
+		asm.StoreMem(asm.RFP, stackEvent+evLport, asm.R1, asm.Half),
+		asm.LoadMem(asm.R1, asm.R6, tpDport, asm.Half),
+		asm.StoreMem(asm.RFP, stackEvent+evRport, asm.R1, asm.Half),
+	)
+	insns = append(insns, copyMem(asm.RFP, stackEvent+evLaddr, asm.R6, tpSaddrV6, 16)...)
+	insns = append(insns, copyMem(asm.RFP, stackEvent+evRaddr, asm.R6, tpDaddrV6, 16)...)
+	insns = append(insns,
+		asm.FnKtimeGetNs.Call(),
+		asm.StoreMem(asm.RFP, stackEvent+evTS, asm.R0, asm.DWord),
+		asm.JEq.Imm(asm.R8, tcpSynSent, "connecting"),
+		asm.JEq.Imm(asm.R8, tcpClose, "close"),
+		asm.JNE.Imm(asm.R8, tcpEstablished, "exit"),
+		asm.JEq.Imm(asm.R7, tcpSynSent, "connected"),
+		asm.JNE.Imm(asm.R7, tcpSynRecv, "exit"),
+	)
+	insns = append(insns, mapUpdate(t.passive, asm.RFP, stackEvent)...)
+	insns = append(insns, asm.Ja.Label("exit"))
+
+	insns = append(insns, asm.StoreImm(asm.RFP, stackEvent+evKind, bpfConnect, asm.Byte).WithSymbol("connecting"))
+	insns = append(insns, currentTask(asm.RFP, stackEvent)...)
+	insns = append(insns, mapUpdate(t.owners, asm.RFP, stackEvent)...)
+	insns = append(insns, asm.Ja.Label("exit"))
+
+	// reported with the time connect was called
+	insns = append(insns, asm.StoreImm(asm.RFP, stackEvent+evKind, bpfConnect, asm.Byte).WithSymbol("connected"))
+	insns = append(insns, lookupOwner(t.owners)...)
+	insns = append(insns, copyMem(asm.RFP, stackEvent+evTS, asm.R0, evTS, 8)...)
+	insns = append(insns, asm.Ja.Label("output"))
+
+	insns = append(insns,
+		asm.JEq.Imm(asm.R7, tcpListen, "exit").WithSymbol("close"),
+		asm.StoreImm(asm.RFP, stackEvent+evKind, bpfClose, asm.Byte),
+		asm.StoreMem(asm.RFP, stackEvent+evOldState, asm.R7, asm.Byte),
+	)
+	insns = append(insns, mapDelete(t.passive)...)
+	insns = append(insns, lookupOwner(t.owners)...)
+	insns = append(insns, mapDelete(t.owners)...)
+
+	insns = append(insns, labeled("output", ringbufOutput(t.events, asm.RFP, stackEvent))...)
+	return append(insns, exit()...)
+}
+
+// lookupOwner copies the pid and comm of the socket's owner into the event
+// on the stack, leaving the owner's event in R0. without an owner it jumps
+// to output.
+func lookupOwner(owners *ebpf.Map) asm.Instructions {
+	insns := asm.Instructions{
+		asm.LoadMapPtr(asm.R1, owners.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "output"),
+		asm.LoadMem(asm.R1, asm.R0, evPID, asm.Word),
+		asm.StoreMem(asm.RFP, stackEvent+evPID, asm.R1, asm.Word),
+	}
+	return append(insns, copyMem(asm.RFP, stackEvent+evComm, asm.R0, evComm, 16)...)
+}
+
+// acceptProgram runs when inet_csk_accept returns a socket, completing the
+// passive open setStateProgram saw with the accepting process. an fexit
+// program gets the arguments and then the return value, 8 bytes each.
+func (t *socketTracer) acceptProgram(args int) asm.Instructions {
+	insns := asm.Instructions{
+		asm.LoadMem(asm.R6, asm.R1, int16(8*args), asm.DWord),
+		asm.JEq.Imm(asm.R6, 0, "exit"),
+		asm.StoreMem(asm.RFP, stackKey, asm.R6, asm.DWord),
+		asm.LoadMapPtr(asm.R1, t.passive.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "exit"),
+		asm.Mov.Reg(asm.R7, asm.R0),
+		asm.StoreImm(asm.R7, evKind, bpfAccept, asm.Byte),
+		asm.FnKtimeGetNs.Call(),
+		asm.StoreMem(asm.R7, evTS, asm.R0, asm.DWord),
+	}
+	insns = append(insns, currentTask(asm.R7, 0)...)
+	insns = append(insns, ringbufOutput(t.events, asm.R7, 0)...)
+	insns = append(insns, mapUpdate(t.owners, asm.R7, 0)...)
+	insns = append(insns, mapDelete(t.passive)...)
+	return append(insns, exit()...)
+}
+
+// udpSendProgram runs on entry to udp_sendmsg or udpv6_sendmsg. the
+// destination is the msg_name of the call, or the peer of a connected
+// socket.
+func (t *socketTracer) udpSendProgram(v6 bool, offsets kernelLayout) asm.Instructions {
+	insns := asm.Instructions{
+		asm.LoadMem(asm.R6, asm.R1, 0, asm.DWord), // struct sock *
+		asm.LoadMem(asm.R7, asm.R1, 8, asm.DWord), // struct msghdr *
+	}
+	insns = append(insns, zeroStack(stackScratch, 16)...)
+	insns = append(insns, zeroStack(stackEvent, sizeofEvent)...)
+	insns = append(insns,
+		asm.StoreImm(asm.RFP, stackEvent+evKind, bpfSend, asm.Byte),
+		asm.StoreImm(asm.RFP, stackEvent+evProto, unix.IPPROTO_UDP, asm.Byte),
+		asm.FnKtimeGetNs.Call(),
+		asm.StoreMem(asm.RFP, stackEvent+evTS, asm.R0, asm.DWord),
+	)
+	insns = append(insns, currentTask(asm.RFP, stackEvent)...)
+
+	insns = append(insns, probeRead(stackScratch, 2, asm.R6, offsets.family)...)
+	insns = append(insns,
+		asm.LoadMem(asm.R1, asm.RFP, stackScratch, asm.Half),
+		asm.StoreMem(asm.RFP, stackEvent+evFamily, asm.R1, asm.Half),
+	)
+	insns = append(insns, probeRead(stackScratch, 2, asm.R6, offsets.num)...)
+	insns = append(insns,
+		asm.LoadMem(asm.R1, asm.RFP, stackScratch, asm.Half),
+		asm.StoreMem(asm.RFP, stackEvent+evLport, asm.R1, asm.Half),
+	)
+	switch {
+	case !v6:
+		insns = append(insns, probeRead(stackEvent+evLaddr+12, 4, asm.R6, offsets.rcvSaddr)...)
+		insns = append(insns, asm.StoreImm(asm.RFP, stackEvent+evLaddr+8, v4MappedPrefix, asm.Word))
+	case offsets.v6RcvSaddr >= 0:
+		insns = append(insns, probeRead(stackEvent+evLaddr, 16, asm.R6, offsets.v6RcvSaddr)...)
+	}
+
+	// msg_name is the first member of struct msghdr
+	insns = append(insns, probeRead(stackScratch, 8, asm.R7, 0)...)
+	insns = append(insns,
+		asm.LoadMem(asm.R8, asm.RFP, stackScratch, asm.DWord),
+		asm.JEq.Imm(asm.R8, 0, "connected"),
+	)
+	if v6 {
+		// sockaddr_in to a v6 socket is handed on to udp_sendmsg
+		insns = append(insns, probeRead(stackScratch, 4, asm.R8, 0)...)
+		insns = append(insns,
+			asm.LoadMem(asm.R1, asm.RFP, stackScratch, asm.Half),
+			asm.JNE.Imm(asm.R1, unix.AF_INET6, "exit"),
+		)
+		insns = append(insns, probeRead(stackEvent+evRaddr, 16, asm.R8, 8)...)
+	} else {
+		insns = append(insns, probeRead(stackScratch, 8, asm.R8, 0)...)
+		insns = append(insns,
+			asm.LoadMem(asm.R1, asm.RFP, stackScratch+4, asm.Word),
+			asm.StoreMem(asm.RFP, stackEvent+evRaddr+12, asm.R1, asm.Word),
+			asm.StoreImm(asm.RFP, stackEvent+evRaddr+8, v4MappedPrefix, asm.Word),
+		)
+	}
+	insns = append(insns,
+		asm.LoadMem(asm.R1, asm.RFP, stackScratch+2, asm.Half),
+		asm.HostTo(asm.BE, asm.R1, asm.Half),
+		asm.StoreMem(asm.RFP, stackEvent+evRport, asm.R1, asm.Half),
+		asm.Ja.Label("output"),
+	)
+
+	insns = append(insns, labeled("connected", probeRead(stackScratch, 2, asm.R6, offsets.dport))...)
+	insns = append(insns,
+		asm.LoadMem(asm.R1, asm.RFP, stackScratch, asm.Half),
+		asm.HostTo(asm.BE, asm.R1, asm.Half),
+		asm.StoreMem(asm.RFP, stackEvent+evRport, asm.R1, asm.Half),
+	)
+	switch {
+	case !v6:
+		insns = append(insns, probeRead(stackEvent+evRaddr+12, 4, asm.R6, offsets.daddr)...)
+		insns = append(insns, asm.StoreImm(asm.RFP, stackEvent+evRaddr+8, v4MappedPrefix, asm.Word))
+	case offsets.v6Daddr >= 0:
+		insns = append(insns, probeRead(stackEvent+evRaddr, 16, asm.R6, offsets.v6Daddr)...)
+	default:
+		insns = append(insns, asm.Ja.Label("exit"))
+	}
+
+	insns = append(insns, labeled("output", ringbufOutput(t.events, asm.RFP, stackEvent))...)
+	return append(insns, exit()...)
+}
+
+// zeroStack clears n bytes of stack at off, n a multiple of 8, through R1
+func zeroStack(off int16, n int) asm.Instructions {
+	insns := asm.Instructions{asm.Mov.Imm(asm.R1, 0)}
+	for i := 0; i < n; i += 8 {
+		insns = append(insns, asm.StoreMem(asm.RFP, off+int16(i), asm.R1, asm.DWord))
+	}
+	return insns
+}
+
+// copyMem copies n bytes, a multiple of 8, through R1
+func copyMem(dst asm.Register, dstOff int16, src asm.Register, srcOff int16, n int) asm.Instructions {
+	var insns asm.Instructions
+	for i := 0; i < n; i += 8 {
+		insns = append(insns,
+			asm.LoadMem(asm.R1, src, srcOff+int16(i), asm.DWord),
+			asm.StoreMem(dst, dstOff+int16(i), asm.R1, asm.DWord),
+		)
+	}
+	return insns
+}
+
+// probeRead reads n bytes of kernel memory at src+srcOff onto the stack
+func probeRead(off int16, n int32, src asm.Register, srcOff int16) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Reg(asm.R1, asm.RFP),
+		asm.Add.Imm(asm.R1, int32(off)),
+		asm.Mov.Imm(asm.R2, n),
+		asm.Mov.Reg(asm.R3, src),
+		asm.Add.Imm(asm.R3, int32(srcOff)),
+		asm.FnProbeReadKernel.Call(),
+	}
+}
+
+// currentTask stores the pid and comm of the current process into the
+// event at base+off. base must survive calls.
+func currentTask(base asm.Register, off int16) asm.Instructions {
+	return asm.Instructions{
+		asm.FnGetCurrentPidTgid.Call(),
+		asm.RSh.Imm(asm.R0, 32),
+		asm.StoreMem(base, off+evPID, asm.R0, asm.Word),
+		asm.Mov.Reg(asm.R1, base),
+		asm.Add.Imm(asm.R1, int32(off+evComm)),
+		asm.Mov.Imm(asm.R2, 16),
+		asm.FnGetCurrentComm.Call(),
+	}
+}
+
+// mapUpdate stores the event at base+off under the socket key
+func mapUpdate(m *ebpf.Map, base asm.Register, off int16) asm.Instructions {
+	return asm.Instructions{
+		asm.LoadMapPtr(asm.R1, m.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.Mov.Reg(asm.R3, base),
+		asm.Add.Imm(asm.R3, int32(off)),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnMapUpdateElem.Call(),
+	}
+}
+
+func mapDelete(m *ebpf.Map) asm.Instructions {
+	return asm.Instructions{
+		asm.LoadMapPtr(asm.R1, m.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.FnMapDeleteElem.Call(),
+	}
+}
+
+// ringbufOutput sends the event at base+off to user space
+func ringbufOutput(m *ebpf.Map, base asm.Register, off int16) asm.Instructions {
+	return asm.Instructions{
+		asm.LoadMapPtr(asm.R1, m.FD()),
+		asm.Mov.Reg(asm.R2, base),
+		asm.Add.Imm(asm.R2, int32(off)),
+		asm.Mov.Imm(asm.R3, sizeofEvent),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnRingbufOutput.Call(),
+	}
+}
+
+// labeled makes insns a jump target
+func labeled(label string, insns asm.Instructions) asm.Instructions {
+	insns[0] = insns[0].WithSymbol(label)
+	return insns
+}
+
+func exit() asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Imm(asm.R0, 0).WithSymbol("exit"),
+		asm.Return(),
+	}
+}
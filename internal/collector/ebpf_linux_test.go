@@ -0,0 +1,154 @@
+//go:build linux
+
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestWatchSocketEvents(t *testing.T) {
+	tracer, err := newSocketTracer()
+	if errors.Is(err, unix.EPERM) || errors.Is(err, unix.EACCES) || errors.Is(err, ebpf.ErrNotSupported) {
+		t.Skipf("socket events unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("newSocketTracer() error: %v", err)
+	}
+	// accepts and udp sends need fentry/fexit, which not every kernel allows
+	extras := len(tracer.links) == 4
+	tracer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := NewDefaultCollector(Options{}).WatchSocketEvents(ctx)
+	if err != nil {
+		t.Fatalf("WatchSocketEvents() error: %v", err)
+	}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			_ = c.Close()
+		}
+	}()
+
+	client, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lport := client.LocalAddr().(*net.TCPAddr).Port
+	_ = client.Close()
+
+	udp, err := net.Dial("udp4", "127.0.0.1:9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = udp.Write([]byte("x"))
+	_, _ = udp.Write([]byte("x"))
+	_ = udp.Close()
+
+	pid := os.Getpid()
+	want := map[string]bool{
+		SocketEventConnect: false,
+		SocketEventAccept:  !extras,
+		SocketEventClose:   false,
+		SocketEventSend:    !extras,
+	}
+	sends := 0
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("events closed early, seen %v", want)
+			}
+			c := event.Connection
+			if c.PID != pid {
+				continue
+			}
+			switch {
+			case event.Kind == SocketEventConnect && c.Lport == lport:
+			case event.Kind == SocketEventAccept && c.Rport == lport:
+			case event.Kind == SocketEventClose && c.Lport == lport:
+				if event.OldState == "" {
+					t.Error("expected the close to carry the state before it")
+				}
+			case event.Kind == SocketEventSend && c.Proto == "udp" && c.Rport == 9:
+				if sends++; sends > 1 {
+					t.Error("expected one send event per udp flow")
+				}
+			default:
+				continue
+			}
+			if c.Raddr != "127.0.0.1" && c.Laddr != "127.0.0.1" {
+				t.Errorf("%s: unexpected addresses %s -> %s", event.Kind, c.Laddr, c.Raddr)
+			}
+			want[event.Kind] = true
+			if want[SocketEventConnect] && want[SocketEventAccept] && want[SocketEventClose] && want[SocketEventSend] {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out, seen %v", want)
+		}
+	}
+}
+
+func TestDecodeSocketEvent(t *testing.T) {
+	raw := make([]byte, sizeofEvent)
+	binary.NativeEndian.PutUint64(raw[evTS:], uint64(2*time.Second))
+	binary.NativeEndian.PutUint32(raw[evPID:], 42)
+	raw[evKind] = bpfConnect
+	raw[evProto] = unix.IPPROTO_TCP
+	binary.NativeEndian.PutUint16(raw[evFamily:], unix.AF_INET)
+	binary.NativeEndian.PutUint16(raw[evLport:], 50000)
+	binary.NativeEndian.PutUint16(raw[evRport:], 443)
+	copy(raw[evLaddr:], net.ParseIP("10.0.0.1").To16())
+	copy(raw[evRaddr:], net.ParseIP("1.1.1.1").To16())
+	copy(raw[evComm:], "curl")
+
+	boot := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	event, ok := decodeSocketEvent(raw, boot)
+	if !ok {
+		t.Fatal("decodeSocketEvent() rejected a valid event")
+	}
+	c := event.Connection
+	if event.Kind != SocketEventConnect || c.Proto != "tcp" || c.IPVersion != "IPv4" || c.State != "ESTABLISHED" {
+		t.Errorf("unexpected kind/proto/state: %s %s %s %s", event.Kind, c.Proto, c.IPVersion, c.State)
+	}
+	if c.Laddr != "10.0.0.1" || c.Lport != 50000 || c.Raddr != "1.1.1.1" || c.Rport != 443 {
+		t.Errorf("unexpected tuple: %s:%d -> %s:%d", c.Laddr, c.Lport, c.Raddr, c.Rport)
+	}
+	if c.PID != 42 || c.Process != "curl" {
+		t.Errorf("unexpected process: %s[%d]", c.Process, c.PID)
+	}
+	if !c.TS.Equal(boot.Add(2 * time.Second)) {
+		t.Errorf("unexpected timestamp %v", c.TS)
+	}
+
+	raw[evKind] = bpfClose
+	raw[evOldState] = tcpEstablished
+	if event, _ := decodeSocketEvent(raw, boot); event.OldState != "ESTABLISHED" || event.Connection.State != "CLOSE" {
+		t.Errorf("expected a close from ESTABLISHED, got %q -> %q", event.OldState, event.Connection.State)
+	}
+
+	// unconnected udp sockets have no destination to report
+	raw[evKind] = bpfSend
+	raw[evProto] = unix.IPPROTO_UDP
+	binary.NativeEndian.PutUint16(raw[evRport:], 0)
+	if _, ok := decodeSocketEvent(raw, boot); ok {
+		t.Error("expected a send without destination port to be dropped")
+	}
+}
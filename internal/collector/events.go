@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"context"
+	"errors"
+)
+
+// Socket event kinds
+const (
+	SocketEventConnect = "connect"
+	SocketEventAccept  = "accept"
+	SocketEventClose   = "close"
+	SocketEventSend    = "send" // udp, once per flow and minute
+)
+
+// ErrSocketEventsUnsupported is returned by WatchSocketEvents when the
+// collector cannot trace socket calls in the kernel
+var ErrSocketEventsUnsupported = errors.New("socket events are not supported by this collector")
+
+// SocketEvent is a socket call seen as it happened. the connection carries
+// the kernel's timestamp, the calling process and the addresses, nothing
+// else.
+type SocketEvent struct {
+	Kind       string
+	Connection Connection
+	OldState   string // with close: the state the socket left
+}
+
+// SocketEventWatcher is implemented by collectors that can trace connects,
+// accepts, closes and udp sends in the kernel
+type SocketEventWatcher interface {
+	WatchSocketEvents(ctx context.Context) (<-chan SocketEvent, error)
+}
+
+// WatchSocketEvents streams the socket events of the global collector until
+// ctx is done, when the channel is closed
+func WatchSocketEvents(ctx context.Context) (<-chan SocketEvent, error) {
+	if w, ok := globalCollector.(SocketEventWatcher); ok {
+		return w.WatchSocketEvents(ctx)
+	}
+	return nil, ErrSocketEventsUnsupported
+}
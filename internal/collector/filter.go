@@ -1,6 +1,8 @@
 package collector
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -25,6 +27,65 @@ type FilterOptions struct {
 	Inode     int64
 	Since     time.Time
 	SinceRel  time.Duration
+
+	SendQ       NumericFilter
+	RecvQ       NumericFilter
+	Retransmits NumericFilter
+	Timer       string
+	Age         NumericFilter // in seconds
+
+	Container string // container id or a prefix of it
+	Pod       string // pod uid or a prefix of it
+
+	PPID int
+	Exe  string // substring of the resolved executable path
+
+	Unit string // systemd unit, with or without its .service/.socket suffix
+}
+
+// NumericFilter compares a numeric field against a value, e.g. sendq>0.
+// the zero value matches everything.
+type NumericFilter struct {
+	Op    string // one of =, !=, >, >=, <, <=
+	Value int64
+}
+
+// ParseNumericFilter builds a NumericFilter from an operator and a value
+func ParseNumericFilter(op, value string) (NumericFilter, error) {
+	switch op {
+	case "=", "!=", ">", ">=", "<", "<=":
+	default:
+		return NumericFilter{}, fmt.Errorf("unsupported operator: %s", op)
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return NumericFilter{}, fmt.Errorf("invalid number: %s", value)
+	}
+	return NumericFilter{Op: op, Value: n}, nil
+}
+
+func (n NumericFilter) IsSet() bool {
+	return n.Op != ""
+}
+
+func (n NumericFilter) Matches(v int64) bool {
+	switch n.Op {
+	case "=":
+		return v == n.Value
+	case "!=":
+		return v != n.Value
+	case ">":
+		return v > n.Value
+	case ">=":
+		return v >= n.Value
+	case "<":
+		return v < n.Value
+	case "<=":
+		return v <= n.Value
+	default:
+		return true
+	}
 }
 
 func (f *FilterOptions) IsEmpty() bool {
@@ -32,7 +93,10 @@ func (f *FilterOptions) IsEmpty() bool {
 		f.Lport == 0 && f.Rport == 0 && f.User == "" && f.UID == 0 &&
 		f.Laddr == "" && f.Raddr == "" && f.Contains == "" &&
 		f.Interface == "" && f.Mark == "" && f.Namespace == "" && f.Inode == 0 &&
-		f.Since.IsZero() && f.SinceRel == 0 && !f.IPv4 && !f.IPv6
+		f.Since.IsZero() && f.SinceRel == 0 && !f.IPv4 && !f.IPv6 &&
+		!f.SendQ.IsSet() && !f.RecvQ.IsSet() && !f.Retransmits.IsSet() && f.Timer == "" && !f.Age.IsSet() &&
+		f.Container == "" && f.Pod == "" && f.PPID == 0 && f.Exe == "" &&
+		f.Unit == ""
 }
 
 func (f *FilterOptions) Matches(c Connection) bool {
@@ -42,10 +106,19 @@ func (f *FilterOptions) Matches(c Connection) bool {
 	if f.State != "" && !strings.EqualFold(c.State, f.State) {
 		return false
 	}
-	if f.Pid != 0 && c.PID != f.Pid {
+	if f.Pid != 0 && !matchesPid(c, f.Pid) {
+		return false
+	}
+	if f.Proc != "" && !matchesProc(c, f.Proc) {
 		return false
 	}
-	if f.Proc != "" && !containsIgnoreCase(c.Process, f.Proc) {
+	if f.PPID != 0 && c.PPID != f.PPID {
+		return false
+	}
+	if f.Exe != "" && !containsIgnoreCase(c.Exe, f.Exe) {
+		return false
+	}
+	if f.Unit != "" && !matchesUnit(c.Unit, f.Unit) && !matchesUnit(c.SocketUnit, f.Unit) {
 		return false
 	}
 	if f.Lport != 0 && c.Lport != f.Lport {
@@ -78,7 +151,7 @@ func (f *FilterOptions) Matches(c Connection) bool {
 	if f.Interface != "" && !strings.EqualFold(c.Interface, f.Interface) {
 		return false
 	}
-	if f.Mark != "" && !strings.EqualFold(c.Mark, f.Mark) {
+	if f.Mark != "" && !matchesMark(c.Mark, f.Mark) {
 		return false
 	}
 	if f.Namespace != "" && !strings.EqualFold(c.Namespace, f.Namespace) {
@@ -87,12 +160,30 @@ func (f *FilterOptions) Matches(c Connection) bool {
 	if f.Inode != 0 && c.Inode != f.Inode {
 		return false
 	}
-	if !f.Since.IsZero() && c.TS.Before(f.Since) {
+	if !f.SendQ.Matches(c.SendQ) || !f.RecvQ.Matches(c.RecvQ) {
+		return false
+	}
+	if !f.Retransmits.Matches(int64(c.Retransmits)) {
+		return false
+	}
+	if f.Timer != "" && !strings.EqualFold(c.Timer, f.Timer) {
+		return false
+	}
+	if f.Container != "" && !hasPrefixIgnoreCase(c.ContainerID, f.Container) {
+		return false
+	}
+	if f.Pod != "" && !hasPrefixIgnoreCase(c.PodUID, f.Pod) {
+		return false
+	}
+	if f.Age.IsSet() && !f.Age.Matches(c.Age) {
+		return false
+	}
+	if !f.Since.IsZero() && firstSeen(c).Before(f.Since) {
 		return false
 	}
 	if f.SinceRel != 0 {
 		threshold := time.Now().Add(-f.SinceRel)
-		if c.TS.Before(threshold) {
+		if firstSeen(c).Before(threshold) {
 			return false
 		}
 	}
@@ -100,34 +191,104 @@ func (f *FilterOptions) Matches(c Connection) bool {
 	return true
 }
 
+// firstSeen is when the socket appeared, or when it was collected for
+// collectors that do not track ages
+func firstSeen(c Connection) time.Time {
+	if c.FirstSeen.IsZero() {
+		return c.TS
+	}
+	return c.FirstSeen
+}
+
 func containsIgnoreCase(s, substr string) bool {
 	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
 }
 
-// checks if a connection's protocol matches the filter.
-// treats "tcp" as matching "tcp" and "tcp6", same for "udp"/"udp6"
-func matchesProto(connProto, filterProto string) bool {
-	connLower := strings.ToLower(connProto)
-	filterLower := strings.ToLower(filterProto)
-
-	// exact match
-	if connLower == filterLower {
+// matchesPid checks the socket's process and every other process holding it
+func matchesPid(c Connection, pid int) bool {
+	if c.PID == pid {
 		return true
 	}
+	for _, h := range c.Holders {
+		if h.PID == pid {
+			return true
+		}
+	}
+	return false
+}
 
-	// "tcp" matches both "tcp" and "tcp6"
-	if filterLower == "tcp" && (connLower == "tcp" || connLower == "tcp6") {
+func matchesProc(c Connection, proc string) bool {
+	if containsIgnoreCase(c.Process, proc) {
 		return true
 	}
+	for _, h := range c.Holders {
+		if containsIgnoreCase(h.Process, proc) {
+			return true
+		}
+	}
+	return false
+}
 
-	// "udp" matches both "udp" and "udp6"
-	if filterLower == "udp" && (connLower == "udp" || connLower == "udp6") {
+// matchesUnit compares unit names, ignoring the unit type when the filter
+// leaves it out, so unit=sshd matches sshd.service and sshd.socket
+func matchesUnit(unit, filter string) bool {
+	if unit == "" {
+		return false
+	}
+	if strings.EqualFold(unit, filter) {
 		return true
 	}
+	if strings.Contains(filter, ".") {
+		return false
+	}
+	dot := strings.LastIndexByte(unit, '.')
+	return dot > 0 && strings.EqualFold(unit[:dot], filter)
+}
+
+func hasPrefixIgnoreCase(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// checks if a connection's protocol matches the filter, which may list
+// several protocols separated by commas.
+// treats "tcp" as matching "tcp" and "tcp6", same for "udp", "raw", "icmp"...
+func matchesProto(connProto, filterProto string) bool {
+	for _, proto := range strings.Split(filterProto, ",") {
+		if matchesSingleProto(connProto, strings.TrimSpace(proto)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMark compares marks as numbers, so mark=16 finds 0x10
+func matchesMark(mark, want string) bool {
+	a, errA := strconv.ParseUint(mark, 0, 32)
+	b, errB := strconv.ParseUint(want, 0, 32)
+	if errA == nil && errB == nil {
+		return a == b
+	}
+	return strings.EqualFold(mark, want)
+}
 
+// WantsProto reports whether the proto filter names proto explicitly
+func (f *FilterOptions) WantsProto(proto string) bool {
+	for _, p := range strings.Split(f.Proto, ",") {
+		if strings.EqualFold(strings.TrimSpace(p), proto) {
+			return true
+		}
+	}
 	return false
 }
 
+func matchesSingleProto(connProto, filterProto string) bool {
+	connLower := strings.ToLower(connProto)
+	filterLower := strings.ToLower(filterProto)
+
+	// exact match, or the ipv6 table of the same protocol
+	return connLower == filterLower || connLower == filterLower+"6"
+}
+
 func matchesContains(c Connection, query string) bool {
 	q := strings.ToLower(query)
 	return containsIgnoreCase(c.Process, q) ||
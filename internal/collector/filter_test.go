@@ -2,6 +2,7 @@ package collector
 
 import (
 	"testing"
+	"time"
 )
 
 func TestFilterConnections(t *testing.T) {
@@ -19,6 +20,7 @@ func TestFilterConnections(t *testing.T) {
 		{"No filters", FilterOptions{}, 3},
 		{"Filter by proto tcp", FilterOptions{Proto: "tcp"}, 2},
 		{"Filter by proto udp", FilterOptions{Proto: "udp"}, 1},
+		{"Filter by proto list", FilterOptions{Proto: "udp, unix"}, 1},
 		{"Filter by state", FilterOptions{State: "ESTABLISHED"}, 2},
 		{"Filter by pid", FilterOptions{Pid: 2}, 1},
 		{"Filter by proc", FilterOptions{Proc: "proc1"}, 2},
@@ -41,4 +43,236 @@ func TestFilterConnections(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestMatchesProto(t *testing.T) {
+	testCases := []struct {
+		conn, filter string
+		expected     bool
+	}{
+		{"tcp6", "tcp", true},
+		{"raw6", "raw", true},
+		{"icmp", "icmp", true},
+		{"udplite", "udp", false},
+		{"udplite6", "udplite", true},
+		{"tcp", "tcp6", false},
+		{"packet", "raw,packet", true},
+	}
+
+	for _, tc := range testCases {
+		if got := matchesProto(tc.conn, tc.filter); got != tc.expected {
+			t.Errorf("matchesProto(%q, %q) = %v, want %v", tc.conn, tc.filter, got, tc.expected)
+		}
+	}
+}
+
+func TestFilterByQueues(t *testing.T) {
+	conns := []Connection{
+		{Proto: "tcp", SendQ: 0, RecvQ: 0, Timer: "off"},
+		{Proto: "tcp", SendQ: 4096, RecvQ: 0, Timer: "on", Retransmits: 3},
+		{Proto: "tcp", SendQ: 0, RecvQ: 512, Timer: "keepalive"},
+	}
+
+	testCases := []struct {
+		name     string
+		filters  FilterOptions
+		expected int
+	}{
+		{"sendq>0", FilterOptions{SendQ: NumericFilter{Op: ">", Value: 0}}, 1},
+		{"recvq>=512", FilterOptions{RecvQ: NumericFilter{Op: ">=", Value: 512}}, 1},
+		{"sendq=0", FilterOptions{SendQ: NumericFilter{Op: "=", Value: 0}}, 2},
+		{"retransmits>2", FilterOptions{Retransmits: NumericFilter{Op: ">", Value: 2}}, 1},
+		{"timer", FilterOptions{Timer: "KEEPALIVE"}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.filters.IsEmpty() {
+				t.Fatal("filter should not be empty")
+			}
+			filtered := FilterConnections(conns, tc.filters)
+			if len(filtered) != tc.expected {
+				t.Errorf("Expected %d connections, but got %d", tc.expected, len(filtered))
+			}
+		})
+	}
+}
+
+func TestFilterByContainerAndPod(t *testing.T) {
+	conns := []Connection{
+		{Process: "nginx", ContainerID: "3f4e5d6c7b8a91", PodUID: "0f1e2d3c-4b5a"},
+		{Process: "redis", ContainerID: "aabbccddeeff00"},
+		{Process: "sshd"},
+	}
+
+	testCases := []struct {
+		name     string
+		filters  FilterOptions
+		expected int
+	}{
+		{"container short id", FilterOptions{Container: "3f4e5d"}, 1},
+		{"container case insensitive", FilterOptions{Container: "AABB"}, 1},
+		{"container no match", FilterOptions{Container: "ffff"}, 0},
+		{"pod uid prefix", FilterOptions{Pod: "0f1e2d3c"}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered := FilterConnections(conns, tc.filters)
+			if len(filtered) != tc.expected {
+				t.Errorf("Expected %d connections, but got %d", tc.expected, len(filtered))
+			}
+		})
+	}
+}
+
+func TestFilterBySharedSocketHolders(t *testing.T) {
+	conns := []Connection{
+		{PID: 100, Process: "nginx", Holders: []Holder{
+			{PID: 100, FD: 6, Process: "nginx"},
+			{PID: 101, FD: 6, Process: "nginx"},
+			{PID: 102, FD: 6, Process: "nginx"},
+		}},
+		{PID: 200, Process: "sshd", Holders: []Holder{
+			{PID: 200, FD: 3, Process: "sshd"},
+			{PID: 201, FD: 3, Process: "sshd-session"},
+		}},
+		{PID: 300, Process: "curl"},
+	}
+
+	testCases := []struct {
+		name     string
+		filters  FilterOptions
+		expected int
+	}{
+		{"primary pid", FilterOptions{Pid: 100}, 1},
+		{"worker pid", FilterOptions{Pid: 102}, 1},
+		{"pid without holders", FilterOptions{Pid: 300}, 1},
+		{"unknown pid", FilterOptions{Pid: 999}, 0},
+		{"proc of another holder", FilterOptions{Proc: "session"}, 1},
+		{"proc of primary", FilterOptions{Proc: "nginx"}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered := FilterConnections(conns, tc.filters)
+			if len(filtered) != tc.expected {
+				t.Errorf("Expected %d connections, but got %d", tc.expected, len(filtered))
+			}
+		})
+	}
+}
+
+func TestFilterByParentAndExe(t *testing.T) {
+	conns := []Connection{
+		{Process: "sshd", PPID: 1, Exe: "/usr/sbin/sshd"},
+		{Process: "sshd", PPID: 4121, Exe: "/tmp/.x/sshd", ExeDeleted: true},
+	}
+
+	testCases := []struct {
+		name     string
+		filters  FilterOptions
+		expected int
+	}{
+		{"started by init", FilterOptions{PPID: 1}, 1},
+		{"exe path", FilterOptions{Exe: "/usr/sbin/"}, 1},
+		{"exe basename", FilterOptions{Exe: "sshd"}, 2},
+		{"proc and exe", FilterOptions{Proc: "sshd", Exe: "/tmp"}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered := FilterConnections(conns, tc.filters)
+			if len(filtered) != tc.expected {
+				t.Errorf("Expected %d connections, but got %d", tc.expected, len(filtered))
+			}
+		})
+	}
+}
+
+func TestFilterByUnit(t *testing.T) {
+	conns := []Connection{
+		{Process: "sshd", Unit: "sshd.service", SocketUnit: "sshd.socket"},
+		{Process: "nginx", Unit: "nginx.service"},
+		{Process: "bash", Unit: "session-2.scope"},
+		{Process: "curl"},
+	}
+
+	testCases := []struct {
+		name     string
+		filters  FilterOptions
+		expected int
+	}{
+		{"without suffix", FilterOptions{Unit: "nginx"}, 1},
+		{"service", FilterOptions{Unit: "sshd.service"}, 1},
+		{"socket unit", FilterOptions{Unit: "sshd.socket"}, 1},
+		{"case insensitive", FilterOptions{Unit: "SSHD"}, 1},
+		{"no partial names", FilterOptions{Unit: "ngin"}, 0},
+		{"wrong type", FilterOptions{Unit: "nginx.socket"}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered := FilterConnections(conns, tc.filters)
+			if len(filtered) != tc.expected {
+				t.Errorf("Expected %d connections, but got %d", tc.expected, len(filtered))
+			}
+		})
+	}
+}
+
+func TestFilterByAge(t *testing.T) {
+	now := time.Now()
+	conns := []Connection{
+		{Process: "sshd", TS: now, FirstSeen: now.Add(-48 * time.Hour), Age: 48 * 3600},
+		{Process: "curl", TS: now, FirstSeen: now.Add(-10 * time.Second), Age: 10},
+	}
+
+	testCases := []struct {
+		name     string
+		filters  FilterOptions
+		expected int
+	}{
+		{"long-lived", FilterOptions{Age: NumericFilter{Op: ">", Value: 3600}}, 1},
+		{"just appeared", FilterOptions{SinceRel: time.Minute}, 1},
+		{"appeared since a time", FilterOptions{Since: now.Add(-72 * time.Hour)}, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered := FilterConnections(conns, tc.filters)
+			if len(filtered) != tc.expected {
+				t.Errorf("Expected %d connections, but got %d", tc.expected, len(filtered))
+			}
+		})
+	}
+}
+
+func TestFilterByMark(t *testing.T) {
+	conns := []Connection{
+		{Process: "nginx", Mark: "0x10"},
+		{Process: "curl", Mark: "0x0"},
+		{Process: "sshd"},
+	}
+
+	testCases := []struct {
+		name     string
+		filters  FilterOptions
+		expected int
+	}{
+		{"hex", FilterOptions{Mark: "0x10"}, 1},
+		{"decimal", FilterOptions{Mark: "16"}, 1},
+		{"upper case hex", FilterOptions{Mark: "0X10"}, 1},
+		{"zero", FilterOptions{Mark: "0"}, 1},
+		{"no match", FilterOptions{Mark: "0x11"}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered := FilterConnections(conns, tc.filters)
+			if len(filtered) != tc.expected {
+				t.Errorf("Expected %d connections, but got %d", tc.expected, len(filtered))
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"net"
+	"sort"
+)
+
+// InterfaceAll is the Interface of sockets bound to a wildcard address
+const InterfaceAll = "all"
+
+// Interface describes a network interface of the host
+type Interface struct {
+	Name  string   `json:"name"`
+	Index int      `json:"index"`
+	Up    bool     `json:"up"`
+	Addrs []string `json:"addrs"` // in CIDR notation
+}
+
+// ListInterfaces returns the host's network interfaces and their addresses
+func ListInterfaces() ([]Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		entry := Interface{
+			Name:  iface.Name,
+			Index: iface.Index,
+			Up:    iface.Flags&net.FlagUp != 0,
+			Addrs: []string{},
+		}
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				entry.Addrs = append(entry.Addrs, addr.String())
+			}
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+type ifaceRoute struct {
+	dst   *net.IPNet
+	iface string
+}
+
+// interfaceResolver maps socket addresses to the interface they use: first
+// by the addresses configured on each interface, then by the routing table
+type interfaceResolver struct {
+	local    map[string]string // ip.String() -> interface name
+	routes   []ifaceRoute
+	loopback string
+}
+
+func newInterfaceResolver() *interfaceResolver {
+	return &interfaceResolver{
+		local:    make(map[string]string),
+		loopback: "lo",
+	}
+}
+
+// loadHostInterfaces adds the addresses of the interfaces in the namespace
+// snitch runs in, as reported by the kernel
+func (r *interfaceResolver) loadHostInterfaces() {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			r.loopback = iface.Name
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				r.addAddr(ipNet.IP, iface.Name)
+			}
+		}
+	}
+}
+
+func (r *interfaceResolver) addAddr(ip net.IP, iface string) {
+	r.local[ip.String()] = iface
+}
+
+func (r *interfaceResolver) addRoute(dst *net.IPNet, iface string) {
+	r.routes = append(r.routes, ifaceRoute{dst: dst, iface: iface})
+}
+
+// sortRoutes orders routes so the longest prefix is tried first
+func (r *interfaceResolver) sortRoutes() {
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		oi, _ := r.routes[i].dst.Mask.Size()
+		oj, _ := r.routes[j].dst.Mask.Size()
+		return oi > oj
+	})
+}
+
+// lookupRoute returns the interface of the most specific route to ip.
+// the default route is skipped unless allowDefault is set.
+func (r *interfaceResolver) lookupRoute(ip net.IP, allowDefault bool) string {
+	for _, route := range r.routes {
+		if ones, _ := route.dst.Mask.Size(); ones == 0 && !allowDefault {
+			continue
+		}
+		if route.dst.Contains(ip) {
+			return route.iface
+		}
+	}
+	return ""
+}
+
+// resolve returns the interface a socket with the given local and remote
+// address uses, InterfaceAll for wildcard listeners, or "" if unknown
+func (r *interfaceResolver) resolve(laddr, raddr string) string {
+	if laddr == "*" {
+		return InterfaceAll
+	}
+
+	ip := net.ParseIP(laddr)
+	if ip == nil {
+		return ""
+	}
+	if ip.IsUnspecified() {
+		return InterfaceAll
+	}
+	if name, ok := r.local[ip.String()]; ok {
+		return name
+	}
+	if ip.IsLoopback() {
+		return r.loopback
+	}
+
+	// an address on a connected subnet matches that subnet's route
+	if name := r.lookupRoute(ip, false); name != "" {
+		return name
+	}
+
+	// otherwise the interface traffic to the peer is routed through
+	if rip := net.ParseIP(raddr); rip != nil && !rip.IsUnspecified() {
+		return r.lookupRoute(rip, true)
+	}
+	return ""
+}
+
+// apply sets the Interface of every connection
+func (r *interfaceResolver) apply(conns []Connection) {
+	for i := range conns {
+		conns[i].Interface = r.resolve(conns[i].Laddr, conns[i].Raddr)
+	}
+}
@@ -0,0 +1,120 @@
+//go:build linux
+
+package collector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/karol-broda/snitch/internal/errutil"
+	"golang.org/x/sys/unix"
+)
+
+// interfaceResolver builds the resolver for the namespace whose proc net
+// directory is netDir. live adds the kernel's own view of snitch's namespace,
+// which is the only source of exact ipv4 addresses.
+func (p procFS) interfaceResolver(netDir string, live bool) *interfaceResolver {
+	r := newInterfaceResolver()
+	if live {
+		r.loadHostInterfaces()
+	}
+	readIfInet6(filepath.Join(netDir, "if_inet6"), r)
+	readRoutes(filepath.Join(netDir, "route"), r)
+	readIPv6Routes(filepath.Join(netDir, "ipv6_route"), r)
+	r.sortRoutes()
+	return r
+}
+
+// readIfInet6 parses /proc/net/if_inet6:
+// address ifindex prefixlen scope flags name
+func readIfInet6(path string, r *interfaceResolver) {
+	forEachLine(path, false, func(fields []string) {
+		if len(fields) < 6 {
+			return
+		}
+		ip, err := hex.DecodeString(fields[0])
+		if err != nil || len(ip) != net.IPv6len {
+			return
+		}
+		r.addAddr(net.IP(ip), fields[5])
+		if fields[5] == "lo" || net.IP(ip).IsLoopback() {
+			r.loopback = fields[5]
+		}
+	})
+}
+
+// readRoutes parses /proc/net/route, which prints addresses and masks as
+// host-order hex of the network-order value
+func readRoutes(path string, r *interfaceResolver) {
+	forEachLine(path, true, func(fields []string) {
+		if len(fields) < 8 {
+			return
+		}
+		flags, _ := strconv.ParseUint(fields[3], 16, 32)
+		if flags&unix.RTF_UP == 0 {
+			return
+		}
+		dst, err1 := parseRouteIPv4(fields[1])
+		mask, err2 := parseRouteIPv4(fields[7])
+		if err1 != nil || err2 != nil {
+			return
+		}
+		r.addRoute(&net.IPNet{IP: dst, Mask: net.IPMask(mask)}, fields[0])
+	})
+}
+
+// readIPv6Routes parses /proc/net/ipv6_route:
+// dst dst_len src src_len gateway metric refcnt use flags iface
+func readIPv6Routes(path string, r *interfaceResolver) {
+	forEachLine(path, false, func(fields []string) {
+		if len(fields) < 10 {
+			return
+		}
+		flags, _ := strconv.ParseUint(fields[8], 16, 32)
+		if flags&unix.RTF_UP == 0 || flags&unix.RTF_REJECT != 0 {
+			return
+		}
+		dst, err := hex.DecodeString(fields[0])
+		if err != nil || len(dst) != net.IPv6len {
+			return
+		}
+		ones, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil {
+			return
+		}
+		r.addRoute(&net.IPNet{IP: net.IP(dst), Mask: net.CIDRMask(int(ones), 128)}, fields[9])
+	})
+}
+
+func parseRouteIPv4(s string) (net.IP, error) {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	ip := make(net.IP, net.IPv4len)
+	binary.NativeEndian.PutUint32(ip, uint32(v))
+	return ip, nil
+}
+
+// forEachLine calls fn with the fields of every line of a proc table
+func forEachLine(path string, skipHeader bool, fn func(fields []string)) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer errutil.Close(file)
+
+	scanner := bufio.NewScanner(file)
+	if skipHeader {
+		scanner.Scan()
+	}
+	for scanner.Scan() {
+		fn(strings.Fields(scanner.Text()))
+	}
+}
@@ -0,0 +1,47 @@
+//go:build linux
+
+package collector
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterfaceResolverFromProc(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("route fixtures below are little endian")
+	}
+
+	fp := newFakeProc(t)
+	fp.writeFile("net/route", "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"+
+		"eth0\t00000000\t0100000A\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"+
+		"eth0\t0000000A\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"+
+		"wg0\t0000080A\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n")
+	fp.writeFile("net/if_inet6", "00000000000000000000000000000001 01 80 10 80       lo\n"+
+		"fd000000000000000000000000000002 02 40 00 80     eth0\n")
+	fp.writeFile("net/ipv6_route", "fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0\n"+
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo\n")
+
+	r := newProcFS(fp.root).interfaceResolver(filepath.Join(fp.root, "net"), false)
+
+	tests := []struct {
+		laddr, raddr, want string
+	}{
+		{"*", "*", InterfaceAll},
+		{"127.0.0.1", "*", "lo"},
+		{"::1", "*", "lo"},
+		{"10.0.0.5", "*", "eth0"},           // connected subnet
+		{"10.8.0.2", "1.1.1.1", "wg0"},      // vpn subnet wins over default
+		{"fd00:0:0:0:0:0:0:2", "*", "eth0"}, // exact address from if_inet6
+		{"192.168.99.1", "8.8.8.8", "eth0"}, // only the default route knows
+		{"fd00::9", "*", "eth0"},
+		{"2001:db8::1", "*", ""}, // reject route is ignored
+	}
+
+	for _, tt := range tests {
+		if got := r.resolve(tt.laddr, tt.raddr); got != tt.want {
+			t.Errorf("resolve(%s, %s) = %q, want %q", tt.laddr, tt.raddr, got, tt.want)
+		}
+	}
+}
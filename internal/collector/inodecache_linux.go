@@ -0,0 +1,207 @@
+//go:build linux
+
+package collector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// inodeCache keeps the socket inodes of every process between refreshes, so
+// only processes that are new, or whose pid was reused or that exec'd,
+// have their fds read again. a socket that cannot be attributed has the
+// processes whose number of fds changed read again, then those already
+// holding sockets; inodes still unknown afterwards (kernel sockets, fds
+// passed to a process that had none) are not retried while they stay.
+type inodeCache struct {
+	mu         sync.Mutex
+	procs      map[int]*cachedProcess
+	unresolved map[int64]bool
+	complete   bool // the last refresh read every process, e.g. the first one
+}
+
+type cachedProcess struct {
+	startTime uint64 // in clock ticks since boot, from /proc/<pid>/stat
+	comm      string
+	fdCount   int64 // open fds when it was read, 0 when unknown
+	entries   []inodeEntry
+	denied    bool // its fds could not be read
+}
+
+func newInodeCache() *inodeCache {
+	return &inodeCache{
+		procs:      make(map[int]*cachedProcess),
+		unresolved: make(map[int64]bool),
+	}
+}
+
+// refresh brings the cache up to date with the running processes and
+// returns the resulting inode map and how many processes were unreadable
+func (c *inodeCache) refresh(p procFS) (map[int64][]inodeEntry, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	start := time.Now()
+	pids, err := p.listPids()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	alive := make(map[int]bool, len(pids))
+	stats := make(map[int]cachedProcess)
+	var changed []int
+	for _, pid := range pids {
+		stat, err := p.readProcStat(pid)
+		if err != nil {
+			continue // exited since the readdir
+		}
+		alive[pid] = true
+		if cached, ok := c.procs[pid]; ok && cached.startTime == stat.startTime && cached.comm == stat.comm {
+			continue
+		}
+		stats[pid] = cachedProcess{startTime: stat.startTime, comm: stat.comm, fdCount: p.fdCount(pid)}
+		changed = append(changed, pid)
+	}
+
+	dropped := 0
+	for pid := range c.procs {
+		if !alive[pid] {
+			delete(c.procs, pid)
+			dropped++
+		}
+	}
+
+	entries, denied := p.scanPids(changed)
+	c.store(entries, denied, stats)
+	c.complete = len(changed) == len(alive)
+	logTiming("  inode cache refresh", start, fmt.Sprintf("%d pids, %d rescanned, %d dropped", len(pids), len(changed), dropped))
+
+	return c.inodeMap(), c.deniedCount(), nil
+}
+
+// resolve attributes the sockets the inode map did not know about, after
+// reading the fds of the processes that may hold them again. it returns the
+// updated inode map.
+func (c *inodeCache) resolve(p procFS, conns []Connection, inodeMap map[int64][]inodeEntry) map[int64][]inodeEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	missing := make(map[int64]bool)
+	present := make(map[int64]bool)
+	for _, conn := range conns {
+		if conn.Inode == 0 || conn.PID != 0 {
+			continue
+		}
+		present[conn.Inode] = true
+		if !c.unresolved[conn.Inode] {
+			missing[conn.Inode] = true
+		}
+	}
+
+	// forget inodes whose sockets are gone
+	for inode := range c.unresolved {
+		if !present[inode] {
+			delete(c.unresolved, inode)
+		}
+	}
+	if len(missing) == 0 {
+		return inodeMap
+	}
+
+	start := time.Now()
+	rescanned := 0
+	if !c.complete {
+		// a new socket means a new fd, so the processes whose fd count
+		// changed are read first. the count can also stay the same when
+		// another fd was closed meanwhile, so if sockets are still missing
+		// the processes that hold sockets are read too. a kernel without fd
+		// counts has every process read.
+		changed := make(map[int]int64)
+		holders := make(map[int]int64)
+		for pid, cached := range c.procs {
+			if cached.denied {
+				continue
+			}
+			count := p.fdCount(pid)
+			switch {
+			case count == 0 || count != cached.fdCount:
+				changed[pid] = count
+			case len(cached.entries) > 0:
+				holders[pid] = count
+			}
+		}
+
+		c.rescan(p, changed)
+		inodeMap = c.inodeMap()
+		rescanned = len(changed)
+		for inode := range missing {
+			if _, ok := inodeMap[inode]; !ok {
+				c.rescan(p, holders)
+				inodeMap = c.inodeMap()
+				rescanned += len(holders)
+				break
+			}
+		}
+	}
+
+	for i := range conns {
+		if conns[i].Inode == 0 || conns[i].PID != 0 {
+			continue
+		}
+		attributeConnection(&conns[i], inodeMap)
+		if conns[i].PID == 0 {
+			c.unresolved[conns[i].Inode] = true
+		}
+	}
+	logTiming("  inode cache rescan", start, fmt.Sprintf("%d unknown inodes, %d of %d pids rescanned", len(missing), rescanned, len(c.procs)))
+
+	return inodeMap
+}
+
+// rescan reads the fds of cached processes again, given with their current
+// fd counts
+func (c *inodeCache) rescan(p procFS, fdCounts map[int]int64) {
+	if len(fdCounts) == 0 {
+		return
+	}
+	pids := make([]int, 0, len(fdCounts))
+	stats := make(map[int]cachedProcess, len(fdCounts))
+	for pid, count := range fdCounts {
+		cached := c.procs[pid]
+		pids = append(pids, pid)
+		stats[pid] = cachedProcess{startTime: cached.startTime, comm: cached.comm, fdCount: count}
+	}
+	entries, denied := p.scanPids(pids)
+	c.store(entries, denied, stats)
+}
+
+// store records the scan results of the processes in stats. processes with
+// no sockets are cached too, so they are not read again next time.
+func (c *inodeCache) store(scanned map[int][]inodeEntry, denied map[int]bool, stats map[int]cachedProcess) {
+	for pid, stat := range stats {
+		entry := stat
+		entry.entries = scanned[pid]
+		entry.denied = denied[pid]
+		c.procs[pid] = &entry
+	}
+}
+
+func (c *inodeCache) deniedCount() int {
+	n := 0
+	for _, cached := range c.procs {
+		if cached.denied {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *inodeCache) inodeMap() map[int64][]inodeEntry {
+	inodeMap := make(map[int64][]inodeEntry)
+	for _, cached := range c.procs {
+		addHolders(inodeMap, cached.entries)
+	}
+	sortHolders(inodeMap)
+	return inodeMap
+}
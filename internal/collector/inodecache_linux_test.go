@@ -0,0 +1,104 @@
+//go:build linux
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInodeCache(t *testing.T) {
+	fp := newFakeProc(t)
+	fp.addProcess(100, "nginx", 0, 1001)
+	fp.addProcess(200, "curl", 0, 2001)
+	p := newProcFS(fp.root)
+	cache := newInodeCache()
+
+	inodeMap, _, err := cache.refresh(p)
+	if err != nil {
+		t.Fatalf("refresh() error: %v", err)
+	}
+	if inodeMap[1001][0].info.pid != 100 || inodeMap[2001][0].info.pid != 200 {
+		t.Fatalf("unexpected initial map: %v", inodeMap)
+	}
+
+	// a new socket in a process that did not change is not seen by refresh,
+	// only by the rescan resolve falls back to
+	fp.addSocket(100, 4, 1002)
+	inodeMap, _, _ = cache.refresh(p)
+	if _, ok := inodeMap[1002]; ok {
+		t.Fatal("unchanged process should not have been rescanned")
+	}
+	conns := []Connection{{Inode: 1001, PID: 100}, {Inode: 1002}, {Inode: 9999}}
+	inodeMap = cache.resolve(p, conns, inodeMap)
+	if conns[1].PID != 100 || conns[1].Process != "nginx" || inodeMap[1002][0].info.pid != 100 {
+		t.Errorf("new socket not attributed after rescan: %+v", conns[1])
+	}
+	if conns[2].PID != 0 || !cache.unresolved[9999] {
+		t.Errorf("orphan inode should be remembered as unresolved: %+v", conns[2])
+	}
+
+	// pid reuse: same pid, new start time and command
+	if err := os.RemoveAll(filepath.Join(fp.root, "200")); err != nil {
+		t.Fatal(err)
+	}
+	fp.addProcess(200, "sshd", 0, 2002)
+	fp.writeStat(200, "sshd", 5000)
+	inodeMap, _, _ = cache.refresh(p)
+	if _, ok := inodeMap[2001]; ok {
+		t.Error("socket of the previous process with pid 200 is still mapped")
+	}
+	if holders := inodeMap[2002]; len(holders) != 1 || holders[0].info.command != "sshd" {
+		t.Errorf("reused pid not rescanned: %+v", holders)
+	}
+
+	// exited processes are dropped
+	if err := os.RemoveAll(filepath.Join(fp.root, "100")); err != nil {
+		t.Fatal(err)
+	}
+	inodeMap, _, _ = cache.refresh(p)
+	if _, ok := inodeMap[1001]; ok || len(cache.procs) != 1 {
+		t.Errorf("exited process still cached: %v", inodeMap)
+	}
+
+	// unresolved inodes are forgotten once their socket is gone
+	cache.resolve(p, nil, inodeMap)
+	if len(cache.unresolved) != 0 {
+		t.Errorf("unresolved inodes not pruned: %v", cache.unresolved)
+	}
+}
+
+func TestInodeCacheRescansOnlyLikelyOwners(t *testing.T) {
+	fp := newFakeProc(t)
+	fp.addProcess(100, "nginx", 0, 1001)
+	fp.addProcess(200, "sleep", 0)
+	devNull := filepath.Join(fp.root, "200", "fd", "0")
+	if err := os.Symlink("/dev/null", devNull); err != nil {
+		t.Fatal(err)
+	}
+	p := newProcFS(fp.root)
+	cache := newInodeCache()
+	if _, _, err := cache.refresh(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// nginx accepts a connection. sleep swaps a file for a socket, keeping
+	// its fd count: had it been read again, the socket would be cached.
+	fp.addSocket(100, 4, 1002)
+	if err := os.Remove(devNull); err != nil {
+		t.Fatal(err)
+	}
+	fp.addSocket(200, 0, 2001)
+
+	inodeMap, _, _ := cache.refresh(p)
+	conns := []Connection{{Inode: 1001}, {Inode: 1002}}
+	attributeConnection(&conns[0], inodeMap)
+	cache.resolve(p, conns, inodeMap)
+	if conns[1].PID != 100 {
+		t.Errorf("new socket not attributed: %+v", conns[1])
+	}
+	if entries := cache.procs[200].entries; len(entries) != 0 {
+		t.Errorf("process without sockets and with the same fds was read again: %+v", entries)
+	}
+}
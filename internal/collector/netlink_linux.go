@@ -0,0 +1,151 @@
+//go:build linux
+
+package collector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// netlinkConn is a minimal request/dump client for a single netlink family
+type netlinkConn struct {
+	fd  int
+	seq uint32
+	buf []byte
+}
+
+func dialNetlink(protocol int) (*netlinkConn, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, protocol)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket: %w", err)
+	}
+
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("netlink bind: %w", err)
+	}
+
+	return &netlinkConn{
+		fd:  fd,
+		buf: make([]byte, 8*os.Getpagesize()),
+	}, nil
+}
+
+func (c *netlinkConn) Close() error {
+	return unix.Close(c.fd)
+}
+
+// subscribe joins multicast groups of the netlink family
+func (c *netlinkConn) subscribe(groups ...int) error {
+	for _, group := range groups {
+		if err := unix.SetsockoptInt(c.fd, unix.SOL_NETLINK, unix.NETLINK_ADD_MEMBERSHIP, group); err != nil {
+			return fmt.Errorf("netlink join group %d: %w", group, err)
+		}
+	}
+	return nil
+}
+
+// setReadTimeout makes reads fail with EAGAIN after d without data
+func (c *netlinkConn) setReadTimeout(d time.Duration) error {
+	tv := unix.NsecToTimeval(d.Nanoseconds())
+	return unix.SetsockoptTimeval(c.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+}
+
+// dump sends a NLM_F_DUMP request and calls fn with the payload of every
+// message in the reply until the kernel signals NLMSG_DONE
+func (c *netlinkConn) dump(msgType uint16, payload []byte, fn func(payload []byte) error) error {
+	c.seq++
+	seq := c.seq
+
+	req := make([]byte, unix.NLMSG_HDRLEN+len(payload))
+	binary.NativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	binary.NativeEndian.PutUint16(req[4:6], msgType)
+	binary.NativeEndian.PutUint16(req[6:8], unix.NLM_F_REQUEST|unix.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(req[8:12], seq)
+	copy(req[unix.NLMSG_HDRLEN:], payload)
+
+	if err := unix.Sendto(c.fd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("netlink send: %w", err)
+	}
+
+	for {
+		n, _, err := unix.Recvfrom(c.fd, c.buf, 0)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return fmt.Errorf("netlink recv: %w", err)
+		}
+
+		done, err := walkNetlinkMessages(c.buf[:n], seq, fn)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// walkNetlinkMessages iterates over the messages in one datagram. it reports
+// done once NLMSG_DONE is seen and turns NLMSG_ERROR into a go error.
+func walkNetlinkMessages(b []byte, seq uint32, fn func(payload []byte) error) (bool, error) {
+	for len(b) >= unix.NLMSG_HDRLEN {
+		msgLen := int(binary.NativeEndian.Uint32(b[0:4]))
+		msgType := binary.NativeEndian.Uint16(b[4:6])
+		msgSeq := binary.NativeEndian.Uint32(b[8:12])
+
+		if msgLen < unix.NLMSG_HDRLEN || msgLen > len(b) {
+			return false, fmt.Errorf("netlink: truncated message")
+		}
+
+		payload := b[unix.NLMSG_HDRLEN:msgLen]
+		b = b[min(nlmsgAlign(msgLen), len(b)):]
+
+		if seq != 0 && msgSeq != seq {
+			continue
+		}
+
+		switch msgType {
+		case unix.NLMSG_DONE:
+			return true, nil
+		case unix.NLMSG_ERROR:
+			if len(payload) < 4 {
+				return false, fmt.Errorf("netlink: truncated error message")
+			}
+			errno := -int32(binary.NativeEndian.Uint32(payload[0:4]))
+			if errno == 0 {
+				return true, nil
+			}
+			return false, unix.Errno(errno)
+		}
+
+		if err := fn(payload); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// parseNetlinkAttrs splits a run of rtattr/nlattr entries into a type->value map
+func parseNetlinkAttrs(b []byte) map[uint16][]byte {
+	attrs := make(map[uint16][]byte)
+	for len(b) >= unix.NLA_HDRLEN {
+		attrLen := int(binary.NativeEndian.Uint16(b[0:2]))
+		attrType := binary.NativeEndian.Uint16(b[2:4]) &^ (unix.NLA_F_NESTED | unix.NLA_F_NET_BYTEORDER)
+
+		if attrLen < unix.NLA_HDRLEN || attrLen > len(b) {
+			break
+		}
+
+		attrs[attrType] = b[unix.NLA_HDRLEN:attrLen]
+		b = b[min(nlmsgAlign(attrLen), len(b)):]
+	}
+	return attrs
+}
+
+func nlmsgAlign(n int) int {
+	return (n + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+}
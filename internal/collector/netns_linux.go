@@ -0,0 +1,193 @@
+//go:build linux
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// namedNetnsDir is where `ip netns add` bind mounts named namespaces
+const namedNetnsDir = "/run/netns"
+
+// a few processes are remembered per namespace in case one exits before its
+// /proc/<pid>/net is read
+const maxNetnsPids = 3
+
+// netNamespace is a network namespace reachable through the proc root
+type netNamespace struct {
+	inode uint64
+	name  string // value used for Connection.Namespace
+	pids  []int
+	mount string // bind mount under /run/netns, for a namespace without processes
+}
+
+// netnsInode returns the inode of the network namespace a process lives in,
+// or 0 when it cannot be read
+func (p procFS) netnsInode(elem ...string) uint64 {
+	link, err := os.Readlink(p.path(append(elem, "ns", "net")...))
+	if err != nil {
+		return 0
+	}
+	var inode uint64
+	if _, err := fmt.Sscanf(link, "net:[%d]", &inode); err != nil {
+		return 0
+	}
+	return inode
+}
+
+// namedNetns maps namespace inodes to their names under /run/netns. names
+// are only meaningful for the live system, not for a foreign proc root.
+func (p procFS) namedNetns() map[uint64]string {
+	names := make(map[uint64]string)
+	if !p.isLive() {
+		return names
+	}
+
+	entries, err := os.ReadDir(namedNetnsDir)
+	if err != nil {
+		return names
+	}
+	for _, entry := range entries {
+		var st unix.Stat_t
+		if err := unix.Stat(filepath.Join(namedNetnsDir, entry.Name()), &st); err != nil {
+			continue
+		}
+		names[st.Ino] = entry.Name()
+	}
+	return names
+}
+
+// netnsIdentity names a namespace: its /run/netns name if it has one, "host"
+// for the namespace of pid 1, otherwise the inode number
+func netnsIdentity(inode, host uint64, named map[uint64]string) string {
+	if inode == 0 {
+		return ""
+	}
+	if name, ok := named[inode]; ok {
+		return name
+	}
+	if inode == host {
+		return HostNamespace
+	}
+	return strconv.FormatUint(inode, 10)
+}
+
+// netNamespaces finds every distinct network namespace that has a process in
+// it, plus the named namespaces under /run/netns that have none, which are
+// entered through their bind mount instead.
+func (p procFS) netNamespaces() ([]netNamespace, error) {
+	entries, err := os.ReadDir(p.path())
+	if err != nil {
+		return nil, err
+	}
+
+	host := p.netnsInode("1")
+	named := p.namedNetns()
+
+	byInode := make(map[uint64]*netNamespace)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		inode := p.netnsInode(entry.Name())
+		if inode == 0 {
+			continue
+		}
+
+		ns, ok := byInode[inode]
+		if !ok {
+			ns = &netNamespace{inode: inode, name: netnsIdentity(inode, host, named)}
+			byInode[inode] = ns
+		}
+		if len(ns.pids) < maxNetnsPids {
+			ns.pids = append(ns.pids, pid)
+		}
+	}
+
+	for inode, name := range named {
+		if _, ok := byInode[inode]; !ok {
+			byInode[inode] = &netNamespace{inode: inode, name: name, mount: filepath.Join(namedNetnsDir, name)}
+		}
+	}
+
+	namespaces := make([]netNamespace, 0, len(byInode))
+	for _, ns := range byInode {
+		namespaces = append(namespaces, *ns)
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].inode < namespaces[j].inode
+	})
+	return namespaces, nil
+}
+
+// collectEnteredNamespace reads the sockets of a namespace without processes
+// by entering it through its bind mount, which takes CAP_SYS_ADMIN. once in,
+// it is read like snitch's own namespace, from /proc/thread-self/net and over
+// netlink sockets opened inside it.
+func (dc *DefaultCollector) collectEnteredNamespace(mount string, inodeMap map[int64][]inodeEntry) ([]Connection, error) {
+	type result struct {
+		conns []Connection
+		err   error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		var conns []Connection
+		err := inNetns(mount, func() {
+			var diag *sockDiagConn
+			if dc.backend != BackendProc {
+				if d, err := dialSockDiag(); err == nil {
+					diag = d
+					defer func() { _ = d.Close() }()
+				}
+			}
+			conns = dc.collectSockets(diag, "/proc/thread-self/net", true, inodeMap)
+		})
+		done <- result{conns, err}
+	}()
+
+	r := <-done
+	return r.conns, r.err
+}
+
+// inNetns runs fn on a thread moved into the network namespace at path and
+// moves the thread back afterwards. a thread that cannot be moved back stays
+// locked to the calling goroutine, so it is never handed to other goroutines.
+func inNetns(path string, fn func()) error {
+	runtime.LockOSThread()
+
+	own, err := unix.Open("/proc/thread-self/ns/net", unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer func() { _ = unix.Close(own) }()
+
+	target, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	err = unix.Setns(target, unix.CLONE_NEWNET)
+	_ = unix.Close(target)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("entering %s: %w", path, err)
+	}
+
+	fn()
+
+	if err := unix.Setns(own, unix.CLONE_NEWNET); err != nil {
+		return fmt.Errorf("leaving %s: %w", path, err)
+	}
+	runtime.UnlockOSThread()
+	return nil
+}
@@ -0,0 +1,135 @@
+//go:build linux
+
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karol-broda/snitch/internal/errutil"
+)
+
+const defaultProcRoot = "/proc"
+
+// procFS is a proc filesystem mounted at root. the zero value refers to the
+// host's /proc; any other root (a bind mount such as /host/proc, or a copied
+// tree) is read as plain files.
+type procFS struct {
+	root string
+}
+
+func newProcFS(root string) procFS {
+	if root == "" {
+		root = defaultProcRoot
+	}
+	return procFS{root: filepath.Clean(root)}
+}
+
+// path joins elem onto the proc root
+func (p procFS) path(elem ...string) string {
+	root := p.root
+	if root == "" {
+		root = defaultProcRoot
+	}
+	return filepath.Join(append([]string{root}, elem...)...)
+}
+
+// pidPath joins elem onto /proc/<pid>
+func (p procFS) pidPath(pid int, elem ...string) string {
+	return p.path(append([]string{strconv.Itoa(pid)}, elem...)...)
+}
+
+// isLive reports whether this is the /proc of the running kernel, i.e. one
+// netlink answers for as well
+func (p procFS) isLive() bool {
+	return p.root == "" || p.root == defaultProcRoot
+}
+
+// procStat holds the fields snitch uses from /proc/<pid>/stat
+type procStat struct {
+	comm      string
+	ppid      int
+	startTime uint64 // in clock ticks since boot
+}
+
+// readProcStat parses /proc/<pid>/stat. the command name is in parentheses
+// and may itself contain spaces or parentheses.
+func (p procFS) readProcStat(pid int) (procStat, error) {
+	data, err := os.ReadFile(p.pidPath(pid, "stat"))
+	if err != nil {
+		return procStat{}, err
+	}
+
+	open := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return procStat{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+
+	// the fields after the name start at field 3: ppid is field 4 and
+	// starttime field 22
+	fields := bytes.Fields(data[end+1:])
+	if len(fields) < 20 {
+		return procStat{}, fmt.Errorf("short stat for pid %d", pid)
+	}
+	ppid, err := strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return procStat{}, err
+	}
+	startTime, err := strconv.ParseUint(string(fields[19]), 10, 64)
+	if err != nil {
+		return procStat{}, err
+	}
+	return procStat{comm: string(data[open+1 : end]), ppid: ppid, startTime: startTime}, nil
+}
+
+// bootTimes caches the boot time of each proc root
+var bootTimes sync.Map
+
+// startTime converts a start time in clock ticks since boot to wall time
+func (p procFS) startTime(ticks uint64) time.Time {
+	boot, err := p.bootTime()
+	if err != nil {
+		return time.Time{}
+	}
+	return boot.Add(time.Duration(ticks) * time.Second / userHZ)
+}
+
+// bootTime reads the btime line of /proc/stat
+func (p procFS) bootTime() (time.Time, error) {
+	if boot, ok := bootTimes.Load(p.path()); ok {
+		return boot.(time.Time), nil
+	}
+
+	file, err := os.Open(p.path("stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer errutil.Close(file)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "btime ")
+		if !ok {
+			continue
+		}
+		secs, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		boot := time.Unix(secs, 0)
+		bootTimes.Store(p.path(), boot)
+		return boot, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("no btime in %s", p.path("stat"))
+}
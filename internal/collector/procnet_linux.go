@@ -0,0 +1,327 @@
+//go:build linux
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karol-broda/snitch/internal/errutil"
+	"golang.org/x/sys/unix"
+)
+
+// readProcTable calls fn with the fields of every row of a /proc/net table,
+// skipping the header line
+func readProcTable(path string, fn func(fields []string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer errutil.Close(file)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan()
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			fn(fields)
+		}
+	}
+	return scanner.Err()
+}
+
+// association states from linux/sctp.h
+var sctpStates = map[int64]string{
+	0: "CLOSED",
+	1: "COOKIE_WAIT",
+	2: "COOKIE_ECHOED",
+	3: "ESTABLISHED",
+	4: "SHUTDOWN_PENDING",
+	5: "SHUTDOWN_SENT",
+	6: "SHUTDOWN_RECEIVED",
+	7: "SHUTDOWN_ACK_SENT",
+}
+
+// sctp sockets use TCP_LISTEN for the socket state of a listening endpoint
+const sctpListen = 10
+
+// parseProcSCTP reads the endpoints and associations under dir (usually
+// /proc/net/sctp). multi-homed sockets report the first local address and
+// the primary remote one.
+func parseProcSCTP(dir string, inodeMap map[int64][]inodeEntry) ([]Connection, error) {
+	now := time.Now()
+	var connections []Connection
+
+	// ENDPT SOCK STY SST HBKT LPORT UID INODE LADDRS...
+	err := readProcTable(filepath.Join(dir, "eps"), func(fields []string) {
+		if len(fields) < 9 {
+			return
+		}
+		sst, _ := strconv.ParseInt(fields[3], 10, 64)
+		lport, _ := strconv.Atoi(fields[5])
+		inode, _ := strconv.ParseInt(fields[7], 10, 64)
+
+		state := "CLOSED"
+		if sst == sctpListen {
+			state = "LISTEN"
+		}
+
+		conn := sctpConnection(now, fields[8])
+		conn.State = state
+		conn.Lport = lport
+		conn.Raddr = "*"
+		conn.Inode = inode
+		finishConnection(&conn, inodeMap)
+		connections = append(connections, conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// ASSOC SOCK STY SST ST HBKT ASSOC-ID TX_QUEUE RX_QUEUE UID INODE LPORT
+	// RPORT LADDRS... <-> RADDRS... followed by counters
+	err = readProcTable(filepath.Join(dir, "assocs"), func(fields []string) {
+		if len(fields) < 16 {
+			return
+		}
+		st, _ := strconv.ParseInt(fields[4], 10, 64)
+		sendQ, _ := strconv.ParseInt(fields[7], 10, 64)
+		recvQ, _ := strconv.ParseInt(fields[8], 10, 64)
+		inode, _ := strconv.ParseInt(fields[10], 10, 64)
+		lport, _ := strconv.Atoi(fields[11])
+		rport, _ := strconv.Atoi(fields[12])
+
+		laddrs, raddrs := splitSCTPAddrs(fields[13:])
+		if len(laddrs) == 0 {
+			return
+		}
+
+		conn := sctpConnection(now, laddrs[0])
+		conn.State = sctpStates[st]
+		conn.Lport = lport
+		conn.Raddr = primarySCTPAddr(raddrs)
+		conn.Rport = rport
+		conn.SendQ = sendQ
+		conn.RecvQ = recvQ
+		conn.Inode = inode
+		finishConnection(&conn, inodeMap)
+		connections = append(connections, conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return connections, nil
+}
+
+func sctpConnection(ts time.Time, laddr string) Connection {
+	conn := Connection{
+		TS:        ts,
+		Proto:     "sctp",
+		IPVersion: "IPv4",
+		Laddr:     sctpAddr(laddr),
+	}
+	if strings.Contains(laddr, ":") {
+		conn.IPVersion = "IPv6"
+	}
+	return conn
+}
+
+// splitSCTPAddrs separates the local and remote address lists of an
+// association line, which are joined by "<->"
+func splitSCTPAddrs(fields []string) (local, remote []string) {
+	for i, f := range fields {
+		if f == "<->" {
+			local = fields[:i]
+			remote = fields[i+1:]
+			break
+		}
+	}
+	// the counters that follow the remote addresses are plain numbers
+	for i, f := range remote {
+		if net.ParseIP(strings.TrimPrefix(f, "*")) == nil {
+			remote = remote[:i]
+			break
+		}
+	}
+	return local, remote
+}
+
+// primarySCTPAddr returns the address the kernel marked with a '*' as the
+// primary path, or the first one
+func primarySCTPAddr(addrs []string) string {
+	for _, addr := range addrs {
+		if strings.HasPrefix(addr, "*") {
+			return sctpAddr(addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return "*"
+	}
+	return sctpAddr(addrs[0])
+}
+
+func sctpAddr(addr string) string {
+	addr = strings.TrimPrefix(addr, "*")
+	if ip := net.ParseIP(addr); ip != nil {
+		if ip.IsUnspecified() {
+			return "*"
+		}
+		return ip.String()
+	}
+	return addr
+}
+
+// well-known ethertypes, from linux/if_ether.h
+var ethertypes = map[uint64]string{
+	unix.ETH_P_ALL:   "all",
+	unix.ETH_P_IP:    "ip",
+	unix.ETH_P_ARP:   "arp",
+	unix.ETH_P_IPV6:  "ipv6",
+	unix.ETH_P_8021Q: "802.1q",
+	unix.ETH_P_LLDP:  "lldp",
+	unix.ETH_P_PAE:   "eapol",
+}
+
+// parseProcPacket reads /proc/net/packet. Laddr holds the ethertype the
+// socket receives and Interface the device it is bound to, if any.
+func parseProcPacket(path string, live bool, inodeMap map[int64][]inodeEntry) ([]Connection, error) {
+	now := time.Now()
+	var connections []Connection
+
+	// sk RefCnt Type Proto Iface R Rmem User Inode
+	err := readProcTable(path, func(fields []string) {
+		if len(fields) < 9 {
+			return
+		}
+		sockType, _ := strconv.ParseUint(fields[2], 10, 16)
+		proto, _ := strconv.ParseUint(fields[3], 16, 16)
+		ifindex, _ := strconv.Atoi(fields[4])
+		inode, _ := strconv.ParseInt(fields[8], 10, 64)
+
+		state := "IDLE"
+		if fields[5] == "1" {
+			state = "RUNNING"
+		}
+
+		conn := Connection{
+			TS:        now,
+			Proto:     "packet",
+			State:     state,
+			SockType:  packetTypeName(sockType),
+			Laddr:     ethertypeName(proto),
+			Interface: packetInterface(ifindex, live),
+			Inode:     inode,
+		}
+		finishConnection(&conn, inodeMap)
+		connections = append(connections, conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return connections, nil
+}
+
+func packetTypeName(sockType uint64) string {
+	switch sockType {
+	case unix.SOCK_RAW:
+		return "raw"
+	case unix.SOCK_DGRAM:
+		return "dgram"
+	default:
+		return "unknown"
+	}
+}
+
+func ethertypeName(proto uint64) string {
+	if name, ok := ethertypes[proto]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", proto)
+}
+
+// packetInterface names the device a packet socket is bound to. indexes only
+// mean something in snitch's own namespace, elsewhere the number is kept.
+func packetInterface(ifindex int, live bool) string {
+	if ifindex == 0 {
+		return InterfaceAll
+	}
+	if live {
+		if iface, err := net.InterfaceByIndex(ifindex); err == nil {
+			return iface.Name
+		}
+	}
+	return strconv.Itoa(ifindex)
+}
+
+// netlink protocol families, from linux/netlink.h
+var netlinkProtocols = map[uint64]string{
+	unix.NETLINK_ROUTE:          "route",
+	unix.NETLINK_USERSOCK:       "usersock",
+	unix.NETLINK_FIREWALL:       "firewall",
+	unix.NETLINK_SOCK_DIAG:      "sock_diag",
+	unix.NETLINK_NFLOG:          "nflog",
+	unix.NETLINK_XFRM:           "xfrm",
+	unix.NETLINK_SELINUX:        "selinux",
+	unix.NETLINK_ISCSI:          "iscsi",
+	unix.NETLINK_AUDIT:          "audit",
+	unix.NETLINK_FIB_LOOKUP:     "fib_lookup",
+	unix.NETLINK_CONNECTOR:      "connector",
+	unix.NETLINK_NETFILTER:      "netfilter",
+	unix.NETLINK_IP6_FW:         "ip6_fw",
+	unix.NETLINK_DNRTMSG:        "dnrtmsg",
+	unix.NETLINK_KOBJECT_UEVENT: "uevent",
+	unix.NETLINK_GENERIC:        "generic",
+	unix.NETLINK_SCSITRANSPORT:  "scsitransport",
+	unix.NETLINK_ECRYPTFS:       "ecryptfs",
+	unix.NETLINK_RDMA:           "rdma",
+	unix.NETLINK_CRYPTO:         "crypto",
+	unix.NETLINK_SMC:            "smc",
+}
+
+// parseProcNetlink reads /proc/net/netlink. Laddr holds the netlink family
+// and Lport the socket's port id, which is the owner's pid for most sockets.
+func parseProcNetlink(path string, inodeMap map[int64][]inodeEntry) ([]Connection, error) {
+	now := time.Now()
+	var connections []Connection
+
+	// sk Eth Pid Groups Rmem Wmem Dump Locks Drops Inode
+	err := readProcTable(path, func(fields []string) {
+		if len(fields) < 10 {
+			return
+		}
+		family, _ := strconv.ParseUint(fields[1], 10, 32)
+		portID, _ := strconv.ParseUint(fields[2], 10, 32)
+		recvQ, _ := strconv.ParseInt(fields[4], 10, 64)
+		sendQ, _ := strconv.ParseInt(fields[5], 10, 64)
+		inode, _ := strconv.ParseInt(fields[9], 10, 64)
+
+		conn := Connection{
+			TS:    now,
+			Proto: "netlink",
+			Laddr: netlinkFamilyName(family),
+			Lport: int(portID),
+			RecvQ: recvQ,
+			SendQ: sendQ,
+			Inode: inode,
+		}
+		finishConnection(&conn, inodeMap)
+		connections = append(connections, conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return connections, nil
+}
+
+func netlinkFamilyName(family uint64) string {
+	if name, ok := netlinkProtocols[family]; ok {
+		return name
+	}
+	return strconv.FormatUint(family, 10)
+}
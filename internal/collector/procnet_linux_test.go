@@ -0,0 +1,71 @@
+//go:build linux
+
+package collector
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseProcSCTP(t *testing.T) {
+	fp := newFakeProc(t)
+	fp.writeFile("net/sctp/eps", " ENDPT     SOCK   STY SST HBKT LPORT   UID INODE LADDRS\n"+
+		"ffff88017e0a0200 ffff880299f7fa00 2   10  29   3868      0   262342 10.0.0.1 10.0.1.1\n")
+	fp.writeFile("net/sctp/assocs", " ASSOC     SOCK   STY SST ST HBKT ASSOC-ID TX_QUEUE RX_QUEUE UID INODE LPORT RPORT LADDRS <-> RADDRS HBINT INS OUTS MAXRT T1X T2X RTXC wmema wmemq sndbuf rcvbuf\n"+
+		"ffff8801 ffff8802 2   1   3  0    2        128        64      0   262343 3868  36412  10.0.0.1 <-> 10.0.0.9 *10.0.1.9 \t30000    10    10   10    0    0        0        1        0   212992   212992\n")
+
+	conns, err := parseProcSCTP(filepath.Join(fp.root, "net", "sctp"), map[int64][]inodeEntry{
+		262342: ownedBy(77, "diameter"),
+	})
+	if err != nil {
+		t.Fatalf("parseProcSCTP() error: %v", err)
+	}
+	if len(conns) != 2 {
+		t.Fatalf("expected 2 sockets, got %d", len(conns))
+	}
+
+	ep := conns[0]
+	if ep.Proto != "sctp" || ep.State != "LISTEN" || ep.Laddr != "10.0.0.1" || ep.Lport != 3868 || ep.PID != 77 {
+		t.Errorf("unexpected endpoint: %+v", ep)
+	}
+
+	assoc := conns[1]
+	if assoc.State != "ESTABLISHED" || assoc.Raddr != "10.0.1.9" || assoc.Rport != 36412 ||
+		assoc.SendQ != 128 || assoc.RecvQ != 64 || assoc.IPVersion != "IPv4" {
+		t.Errorf("unexpected association: %+v", assoc)
+	}
+}
+
+func TestParseProcPacketAndNetlink(t *testing.T) {
+	fp := newFakeProc(t)
+	fp.writeFile("net/packet", "sk               RefCnt Type Proto  Iface R Rmem   User   Inode\n"+
+		"ffff8800aabbcc00 3      3    0003   0     1 0      0      9001\n"+
+		"ffff8800aabbcc01 3      2    88cc   7     0 0      0      9002\n")
+	fp.writeFile("net/netlink", "sk               Eth Pid        Groups   Rmem     Wmem     Dump  Locks    Drops    Inode\n"+
+		"ffff8800aabbcc02 0   812        00000551 0        0        0     2        0        9003\n"+
+		"ffff8800aabbcc03 31  0          00000000 0        0        0     2        0        9004\n")
+	inodeMap := map[int64][]inodeEntry{9001: ownedBy(5, "tcpdump")}
+
+	packets, err := parseProcPacket(filepath.Join(fp.root, "net", "packet"), false, inodeMap)
+	if err != nil {
+		t.Fatalf("parseProcPacket() error: %v", err)
+	}
+	if len(packets) != 2 {
+		t.Fatalf("expected 2 packet sockets, got %d", len(packets))
+	}
+	if c := packets[0]; c.Proto != "packet" || c.Laddr != "all" || c.Interface != InterfaceAll ||
+		c.State != "RUNNING" || c.SockType != "raw" || c.Process != "tcpdump" {
+		t.Errorf("unexpected sniffer socket: %+v", c)
+	}
+	if c := packets[1]; c.Laddr != "lldp" || c.Interface != "7" || c.State != "IDLE" || c.SockType != "dgram" {
+		t.Errorf("unexpected lldp socket: %+v", c)
+	}
+
+	netlinks, err := parseProcNetlink(filepath.Join(fp.root, "net", "netlink"), inodeMap)
+	if err != nil {
+		t.Fatalf("parseProcNetlink() error: %v", err)
+	}
+	if len(netlinks) != 2 || netlinks[0].Laddr != "route" || netlinks[0].Lport != 812 || netlinks[1].Laddr != "31" {
+		t.Errorf("unexpected netlink sockets: %+v", netlinks)
+	}
+}
@@ -0,0 +1,172 @@
+package collector
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FilteringCollector is implemented by collectors that can apply filters at
+// the source, so that only matching connections are collected or sent
+type FilteringCollector interface {
+	GetFilteredConnections(filters FilterOptions) ([]Connection, error)
+}
+
+// GetFilteredConnections fetches the connections of the global collector
+// that match filters, pushing the filters down to it when it can apply them
+func GetFilteredConnections(filters FilterOptions) ([]Connection, error) {
+	if f, ok := globalCollector.(FilteringCollector); ok {
+		return f.GetFilteredConnections(filters)
+	}
+	conns, err := globalCollector.GetConnections()
+	if err != nil {
+		return nil, err
+	}
+	return FilterConnections(conns, filters), nil
+}
+
+// RemoteOptions configures a RemoteCollector
+type RemoteOptions struct {
+	// Token is sent as a bearer token, for agents started with one
+	Token string
+
+	// TLSConfig is used for https addresses, the system roots when nil
+	TLSConfig *tls.Config
+
+	// Timeout bounds each request, 10s when zero
+	Timeout time.Duration
+
+	// IncludeUnix, IncludeNetlink and Conntrack ask the agent to collect
+	// what Options of the same names would locally
+	IncludeUnix    bool
+	IncludeNetlink bool
+	Conntrack      bool
+}
+
+// RemoteCollector fetches the connections of another host from a snitch
+// agent. it implements Collector, FilteringCollector and WarningReporter.
+type RemoteCollector struct {
+	addr   string
+	url    string
+	opts   RemoteOptions
+	client *http.Client
+
+	mu   sync.Mutex
+	last *Snapshot
+}
+
+// NewRemoteCollector creates a collector for the agent at addr, either
+// host:port for plain http or an http(s):// url
+func NewRemoteCollector(addr string, opts RemoteOptions) (*RemoteCollector, error) {
+	base := addr
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote %q: %w", addr, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid remote %q: expected host:port or an http(s) url", addr)
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = opts.TLSConfig
+
+	return &RemoteCollector{
+		addr:   addr,
+		url:    strings.TrimSuffix(u.String(), "/") + AgentPath,
+		opts:   opts,
+		client: &http.Client{Timeout: timeout, Transport: transport},
+	}, nil
+}
+
+// Addr returns the address the collector was created with
+func (rc *RemoteCollector) Addr() string {
+	return rc.addr
+}
+
+// GetConnections fetches every connection of the remote host
+func (rc *RemoteCollector) GetConnections() ([]Connection, error) {
+	return rc.GetFilteredConnections(FilterOptions{})
+}
+
+// GetFilteredConnections has the agent filter the connections before
+// sending them. they are filtered again here, in case the agent is older
+// and does not know every filter.
+func (rc *RemoteCollector) GetFilteredConnections(filters FilterOptions) ([]Connection, error) {
+	s, err := rc.Fetch(filters)
+	if err != nil {
+		return nil, err
+	}
+	return FilterConnections(s.Connections, filters), nil
+}
+
+// Fetch asks the agent for a snapshot of the connections matching filters
+func (rc *RemoteCollector) Fetch(filters FilterOptions) (*Snapshot, error) {
+	query := url.Values{}
+	if !filters.IsEmpty() {
+		encoded, err := json.Marshal(filters)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("filter", string(encoded))
+	}
+	for name, set := range map[string]bool{"unix": rc.opts.IncludeUnix, "netlink": rc.opts.IncludeNetlink, "conntrack": rc.opts.Conntrack} {
+		if set {
+			query.Set(name, "true")
+		}
+	}
+
+	target := rc.url
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rc.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+rc.opts.Token)
+	}
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: %s: %s", rc.addr, resp.Status, strings.TrimSpace(string(body)))
+	}
+	s, err := ReadSnapshot(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rc.addr, err)
+	}
+
+	rc.mu.Lock()
+	rc.last = s
+	rc.mu.Unlock()
+	return s, nil
+}
+
+// Warnings returns what the agent could not see in the last collection
+func (rc *RemoteCollector) Warnings() []Warning {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.last == nil {
+		return nil
+	}
+	return rc.last.Warnings
+}
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// warningCollector is a MockCollector that reports warnings
+type warningCollector struct {
+	*MockCollector
+	warnings []Warning
+}
+
+func (w warningCollector) Warnings() []Warning {
+	return w.warnings
+}
+
+func newTestAgent(t *testing.T, token string) (*httptest.Server, *[]Options) {
+	var requested []Options
+	server := httptest.NewServer(NewAgentHandler(AgentOptions{
+		Token:         token,
+		SnitchVersion: "1.2.3",
+		NewCollector: func(o Options) Collector {
+			requested = append(requested, o)
+			return warningCollector{NewMockCollector(), []Warning{{Kind: WarningUnreadablePIDs, Count: 2}}}
+		},
+	}))
+	t.Cleanup(server.Close)
+	return server, &requested
+}
+
+func TestRemoteCollector(t *testing.T) {
+	server, requested := newTestAgent(t, "secret")
+
+	rc, err := NewRemoteCollector(server.URL, RemoteOptions{Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := rc.GetConnections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(getDefaultTestConnections()) {
+		t.Errorf("expected every connection of the agent, got %d", len(all))
+	}
+	if w := rc.Warnings(); len(w) != 1 || w[0].Count != 2 {
+		t.Errorf("expected the agent's warnings, got %+v", w)
+	}
+
+	s, err := rc.Fetch(FilterOptions{Proc: "nginx", Proto: "unix,tcp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Connections) == 0 || s.SnitchVersion != "1.2.3" || s.Hostname == "" {
+		t.Fatalf("unexpected snapshot from the agent %+v", s)
+	}
+	for _, c := range s.Connections {
+		if c.Process != "nginx" {
+			t.Errorf("expected the agent to filter by process, got %s", c.Process)
+		}
+	}
+
+	// one collector per set of options, and the unix filter asks for unix
+	if len(*requested) != 2 || (*requested)[0].IncludeUnix || !(*requested)[1].IncludeUnix {
+		t.Errorf("unexpected collector options %+v", *requested)
+	}
+	if _, err := rc.GetConnections(); err != nil || len(*requested) != 2 {
+		t.Errorf("expected the collector to be reused, got %d collectors (%v)", len(*requested), err)
+	}
+}
+
+func TestAgentRequiresToken(t *testing.T) {
+	server, _ := newTestAgent(t, "secret")
+
+	for _, token := range []string{"", "wrong"} {
+		rc, err := NewRemoteCollector(strings.TrimPrefix(server.URL, "http://"), RemoteOptions{Token: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rc.GetConnections(); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("token %q: expected 401, got %v", token, err)
+		}
+	}
+
+	resp, err := http.Post(server.URL+AgentPath, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected authentication before anything else, got %s", resp.Status)
+	}
+}
+
+func TestNewRemoteCollectorAddr(t *testing.T) {
+	for _, addr := range []string{"", "ftp://web-1", "http://"} {
+		if _, err := NewRemoteCollector(addr, RemoteOptions{}); err == nil {
+			t.Errorf("expected %q to be rejected", addr)
+		}
+	}
+	rc, err := NewRemoteCollector("https://web-1:9797/", RemoteOptions{})
+	if err != nil || rc.url != "https://web-1:9797"+AgentPath {
+		t.Errorf("unexpected url %q (%v)", rc.url, err)
+	}
+}
@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// SnapshotVersion is the format version of snapshot files
+const SnapshotVersion = 1
+
+// Snapshot is the sockets of a host at one point in time, with enough about
+// the host to tell where and when it was taken
+type Snapshot struct {
+	Version       int          `json:"version"`
+	Hostname      string       `json:"hostname"`
+	Kernel        string       `json:"kernel"` // uname release
+	OS            string       `json:"os"`     // GOOS/GOARCH
+	Time          time.Time    `json:"time"`
+	SnitchVersion string       `json:"snitch_version"`
+	Warnings      []Warning    `json:"warnings,omitempty"`
+	Connections   []Connection `json:"connections"`
+}
+
+// TakeSnapshot collects the connections of the global collector, keeping
+// those matching filters, and describes the host they came from
+func TakeSnapshot(filters FilterOptions, snitchVersion string) (*Snapshot, error) {
+	conns, err := GetConnections()
+	if err != nil {
+		return nil, err
+	}
+
+	return newSnapshot(FilterConnections(conns, filters), GetWarnings(), snitchVersion), nil
+}
+
+// newSnapshot describes this host around conns
+func newSnapshot(conns []Connection, warnings []Warning, snitchVersion string) *Snapshot {
+	hostname, _ := os.Hostname()
+	return &Snapshot{
+		Version:       SnapshotVersion,
+		Hostname:      hostname,
+		Kernel:        kernelRelease(),
+		OS:            runtime.GOOS + "/" + runtime.GOARCH,
+		Time:          time.Now(),
+		SnitchVersion: snitchVersion,
+		Warnings:      warnings,
+		Connections:   conns,
+	}
+}
+
+func kernelRelease() string {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return ""
+	}
+	return unix.ByteSliceToString(uts.Release[:])
+}
+
+// Write encodes the snapshot as indented json
+func (s *Snapshot) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// ReadSnapshot decodes a snapshot. a plain json array of connections, as
+// written by snitch json or MockCollector.SaveToFile, is read as a snapshot
+// without host details.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var conns []Connection
+		if err := json.Unmarshal(trimmed, &conns); err != nil {
+			return nil, err
+		}
+		return &Snapshot{Connections: conns}, nil
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Version > SnapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d is newer than this snitch supports (%d)", s.Version, SnapshotVersion)
+	}
+	return &s, nil
+}
+
+// LoadSnapshot reads a snapshot file
+func LoadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	s, err := ReadSnapshot(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Describe names the host and time of the snapshot, e.g. for a title
+func (s *Snapshot) Describe() string {
+	host := s.Hostname
+	if host == "" {
+		host = "unknown host"
+	}
+	if s.Time.IsZero() {
+		return host
+	}
+	return host + " at " + s.Time.Format(time.RFC3339)
+}
+
+// SnapshotCollector serves the connections of a snapshot instead of the
+// live host's. it implements Collector and WarningReporter.
+type SnapshotCollector struct {
+	snapshot *Snapshot
+}
+
+// NewSnapshotCollector creates a collector replaying s
+func NewSnapshotCollector(s *Snapshot) *SnapshotCollector {
+	return &SnapshotCollector{snapshot: s}
+}
+
+// GetConnections returns a copy of the snapshot's connections
+func (sc *SnapshotCollector) GetConnections() ([]Connection, error) {
+	result := make([]Connection, len(sc.snapshot.Connections))
+	copy(result, sc.snapshot.Connections)
+	return result, nil
+}
+
+// Warnings returns what the host could not see when the snapshot was taken
+func (sc *SnapshotCollector) Warnings() []Warning {
+	return sc.snapshot.Warnings
+}
+
+// Snapshot returns the snapshot the collector replays
+func (sc *SnapshotCollector) Snapshot() *Snapshot {
+	return sc.snapshot
+}
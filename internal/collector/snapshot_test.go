@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTakeSnapshot(t *testing.T) {
+	original := GetCollector()
+	defer SetCollector(original)
+	SetCollector(NewMockCollector())
+
+	s, err := TakeSnapshot(FilterOptions{Proc: "nginx"}, "1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != SnapshotVersion || s.SnitchVersion != "1.2.3" || s.Time.IsZero() || s.OS == "" {
+		t.Errorf("unexpected snapshot header %+v", s)
+	}
+	for _, c := range s.Connections {
+		if c.Process != "nginx" {
+			t.Errorf("expected only nginx connections, got %s", c.Process)
+		}
+	}
+	if len(s.Connections) == 0 {
+		t.Error("expected the nginx connections in the snapshot")
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	s := &Snapshot{
+		Version:     SnapshotVersion,
+		Hostname:    "web-1",
+		Kernel:      "6.1.0",
+		Warnings:    []Warning{{Kind: WarningUnreadablePIDs, Count: 3}},
+		Connections: getDefaultTestConnections(),
+	}
+
+	var buf bytes.Buffer
+	if err := s.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Hostname != "web-1" || read.Kernel != "6.1.0" || len(read.Connections) != len(s.Connections) {
+		t.Errorf("snapshot changed on the way: %+v", read)
+	}
+
+	sc := NewSnapshotCollector(read)
+	conns, _ := sc.GetConnections()
+	conns[0].Process = "changed"
+	if again, _ := sc.GetConnections(); again[0].Process == "changed" {
+		t.Error("expected GetConnections to return a copy")
+	}
+	if len(sc.Warnings()) != 1 {
+		t.Errorf("expected the snapshot's warnings, got %v", sc.Warnings())
+	}
+}
+
+func TestReadSnapshotFormats(t *testing.T) {
+	// a fixture saved by MockCollector, or snitch json output
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := NewMockCollector().SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Connections) != len(getDefaultTestConnections()) || s.Describe() != "unknown host" {
+		t.Errorf("unexpected snapshot from a connection array: %d connections, %q", len(s.Connections), s.Describe())
+	}
+
+	newer, _ := json.Marshal(Snapshot{Version: SnapshotVersion + 1})
+	if _, err := ReadSnapshot(bytes.NewReader(newer)); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("expected a newer snapshot version to be rejected, got %v", err)
+	}
+}
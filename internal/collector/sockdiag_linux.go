@@ -0,0 +1,203 @@
+//go:build linux
+
+package collector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// layouts from linux/inet_diag.h
+const (
+	sizeofInetDiagSockID = 48
+	sizeofInetDiagReqV2  = 8 + sizeofInetDiagSockID
+	sizeofInetDiagMsg    = 4 + sizeofInetDiagSockID + 20
+
+	// all tcp states, including TIME_WAIT and NEW_SYN_RECV
+	inetDiagAllStates = 0xffffffff
+
+	// INET_DIAG_INFO carries a struct tcp_info for tcp sockets
+	inetDiagInfo = 2
+)
+
+// inetDiagMsg is the decoded fixed part of a struct inet_diag_msg
+type inetDiagMsg struct {
+	family  uint8
+	state   uint8
+	timer   uint8
+	retrans uint8
+	sport   uint16
+	dport   uint16
+	src     []byte
+	dst     []byte
+	ifindex uint32
+	expires uint32
+	rqueue  uint32
+	wqueue  uint32
+	uid     uint32
+	inode   uint32
+	attrs   map[uint16][]byte
+}
+
+// sockDiagConn dumps sockets over NETLINK_SOCK_DIAG
+type sockDiagConn struct {
+	nl *netlinkConn
+}
+
+func dialSockDiag() (*sockDiagConn, error) {
+	nl, err := dialNetlink(unix.NETLINK_SOCK_DIAG)
+	if err != nil {
+		return nil, err
+	}
+	return &sockDiagConn{nl: nl}, nil
+}
+
+func (c *sockDiagConn) Close() error {
+	return c.nl.Close()
+}
+
+// dumpInet returns every socket of the table's family and protocol
+func (c *sockDiagConn) dumpInet(t inetTable, inodeMap map[int64][]inodeEntry) ([]Connection, error) {
+	req := make([]byte, sizeofInetDiagReqV2)
+	req[0] = t.family
+	req[1] = t.protocol
+	if t.protocol == unix.IPPROTO_TCP {
+		req[2] = 1 << (inetDiagInfo - 1)
+	}
+	binary.NativeEndian.PutUint32(req[4:8], inetDiagAllStates)
+
+	now := time.Now()
+	var connections []Connection
+	err := c.nl.dump(unix.SOCK_DIAG_BY_FAMILY, req, func(payload []byte) error {
+		msg, err := parseInetDiagMsg(payload)
+		if err != nil {
+			return err
+		}
+		connections = append(connections, msg.connection(t, now, inodeMap))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return connections, nil
+}
+
+func parseInetDiagMsg(b []byte) (*inetDiagMsg, error) {
+	if len(b) < sizeofInetDiagMsg {
+		return nil, fmt.Errorf("inet_diag: short message (%d bytes)", len(b))
+	}
+
+	msg := &inetDiagMsg{
+		family:  b[0],
+		state:   b[1],
+		timer:   b[2],
+		retrans: b[3],
+		// ports are in network byte order, everything else is host order
+		sport:   binary.BigEndian.Uint16(b[4:6]),
+		dport:   binary.BigEndian.Uint16(b[6:8]),
+		ifindex: binary.NativeEndian.Uint32(b[40:44]),
+		expires: binary.NativeEndian.Uint32(b[52:56]),
+		rqueue:  binary.NativeEndian.Uint32(b[56:60]),
+		wqueue:  binary.NativeEndian.Uint32(b[60:64]),
+		uid:     binary.NativeEndian.Uint32(b[64:68]),
+		inode:   binary.NativeEndian.Uint32(b[68:72]),
+		attrs:   parseNetlinkAttrs(b[sizeofInetDiagMsg:]),
+	}
+
+	addrLen := 4
+	if msg.family == unix.AF_INET6 {
+		addrLen = 16
+	}
+	msg.src = b[8 : 8+addrLen]
+	msg.dst = b[24 : 24+addrLen]
+
+	return msg, nil
+}
+
+func (msg *inetDiagMsg) connection(t inetTable, ts time.Time, inodeMap map[int64][]inodeEntry) Connection {
+	conn := Connection{
+		TS:        ts,
+		Proto:     t.proto,
+		IPVersion: fmt.Sprintf("IPv%d", t.ipVersion),
+		State:     stateName(int64(msg.state), t.proto),
+		Laddr:     formatSockAddr(msg.src),
+		Lport:     int(msg.sport),
+		Raddr:     formatSockAddr(msg.dst),
+		Rport:     int(msg.dport),
+		Inode:     int64(msg.inode),
+		// for listeners this is the accept queue
+		RecvQ:       int64(msg.rqueue),
+		Timer:       timerName(int64(msg.timer)),
+		Retransmits: int(msg.retrans),
+	}
+	// a listener's wqueue is its backlog limit, not a queue. /proc reports
+	// a tx_queue of 0 for it, and so do we.
+	if msg.state != tcpListen {
+		conn.SendQ = int64(msg.wqueue)
+	}
+	if msg.timer != 0 {
+		conn.TimerExpiresMs = int64(msg.expires)
+	}
+
+	if info := msg.tcpInfo(); info != nil {
+		applyTCPInfo(&conn, info)
+	}
+
+	finishConnection(&conn, inodeMap)
+	return conn
+}
+
+// tcpInfo decodes the INET_DIAG_INFO attribute. older kernels send a shorter
+// struct, in which case the fields they do not know about stay zero.
+func (msg *inetDiagMsg) tcpInfo() *unix.TCPInfo {
+	raw, ok := msg.attrs[inetDiagInfo]
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+
+	info := &unix.TCPInfo{}
+	dst := unsafe.Slice((*byte)(unsafe.Pointer(info)), unix.SizeofTCPInfo)
+	copy(dst, raw)
+	return info
+}
+
+// applyTCPInfo copies the kernel's per-socket counters onto the connection
+func applyTCPInfo(conn *Connection, info *unix.TCPInfo) {
+	conn.RxBytes = int64(info.Bytes_received)
+	conn.TxBytes = int64(info.Bytes_acked)
+	conn.RttMs = float64(info.Rtt) / 1000 // srtt is reported in microseconds
+	conn.Cwnd = int(info.Snd_cwnd)
+	conn.TotalRetrans = int(info.Total_retrans)
+	conn.DeliveryRate = int64(info.Delivery_rate)
+}
+
+// formatSockAddr renders a raw address the same way parseHexAddr renders
+// the /proc representation, so both backends produce identical output
+func formatSockAddr(ip []byte) string {
+	switch len(ip) {
+	case 4:
+		addr := fmt.Sprintf("%d.%d.%d.%d", ip[0], ip[1], ip[2], ip[3])
+		if addr == "0.0.0.0" {
+			return "*"
+		}
+		return addr
+	case 16:
+		groups := make([]string, 8)
+		for i := range groups {
+			groups[i] = fmt.Sprintf("%x", binary.BigEndian.Uint16(ip[i*2:i*2+2]))
+		}
+		addr := strings.Join(groups, ":")
+		if addr == "0:0:0:0:0:0:0:0" {
+			return "*"
+		}
+		return addr
+	default:
+		return ""
+	}
+}
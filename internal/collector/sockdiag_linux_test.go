@@ -0,0 +1,196 @@
+//go:build linux
+
+package collector
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseInetDiagMsg(t *testing.T) {
+	b := make([]byte, sizeofInetDiagMsg)
+	b[0] = unix.AF_INET
+	b[1] = 0x0A // LISTEN
+	binary.BigEndian.PutUint16(b[4:6], 8080)
+	binary.BigEndian.PutUint16(b[6:8], 0)
+	copy(b[8:12], []byte{127, 0, 0, 1})
+	binary.NativeEndian.PutUint32(b[56:60], 3)
+	binary.NativeEndian.PutUint32(b[60:64], 7)
+	binary.NativeEndian.PutUint32(b[68:72], 424242)
+
+	msg, err := parseInetDiagMsg(b)
+	if err != nil {
+		t.Fatalf("parseInetDiagMsg() error: %v", err)
+	}
+
+	if msg.sport != 8080 {
+		t.Errorf("sport = %d, want 8080", msg.sport)
+	}
+	if msg.rqueue != 3 || msg.wqueue != 7 {
+		t.Errorf("queues = %d/%d, want 3/7", msg.rqueue, msg.wqueue)
+	}
+	if msg.inode != 424242 {
+		t.Errorf("inode = %d, want 424242", msg.inode)
+	}
+
+	conn := msg.connection(inetTables[0], time.Now(), nil)
+	if conn.State != "LISTEN" || conn.Laddr != "127.0.0.1" || conn.Raddr != "*" {
+		t.Errorf("unexpected connection: state=%s laddr=%s raddr=%s", conn.State, conn.Laddr, conn.Raddr)
+	}
+}
+
+func TestParseInetDiagMsgShort(t *testing.T) {
+	if _, err := parseInetDiagMsg(make([]byte, 10)); err == nil {
+		t.Error("expected error for short message")
+	}
+}
+
+func TestFormatSockAddrMatchesProc(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("proc hex fixtures below are little endian")
+	}
+
+	tests := []struct {
+		ip  string
+		hex string
+	}{
+		{"0.0.0.0", "00000000"},
+		{"127.0.0.1", "0100007F"},
+		{"192.168.1.20", "1401A8C0"},
+		{"::", "00000000000000000000000000000000"},
+		{"::1", "00000000000000000000000001000000"},
+		{"fe80::1", "000080FE000000000000000001000000"},
+		{"::ffff:10.0.0.1", "0000000000000000FFFF00000100000A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			ip := net.ParseIP(tt.ip)
+			if v4 := ip.To4(); v4 != nil && len(tt.hex) == 8 {
+				ip = v4
+			}
+
+			want, _, err := parseHexAddr(tt.hex + ":0000")
+			if err != nil {
+				t.Fatalf("parseHexAddr() error: %v", err)
+			}
+			if got := formatSockAddr(ip); got != want {
+				t.Errorf("formatSockAddr(%s) = %q, proc gives %q", tt.ip, got, want)
+			}
+		})
+	}
+}
+
+func TestNetlinkBackendMatchesProc(t *testing.T) {
+	diag, err := dialSockDiag()
+	if err != nil {
+		t.Skipf("netlink sock_diag unavailable: %v", err)
+	}
+	_ = diag.Close()
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	find := func(backend string) *Connection {
+		conns, err := NewDefaultCollector(Options{Backend: backend}).GetConnections()
+		if err != nil {
+			t.Fatalf("%s backend: %v", backend, err)
+		}
+		for i := range conns {
+			if conns[i].Proto == "tcp" && conns[i].Lport == port && conns[i].State == "LISTEN" {
+				return &conns[i]
+			}
+		}
+		t.Fatalf("%s backend did not report listener on port %d", backend, port)
+		return nil
+	}
+
+	viaNetlink := find(BackendNetlink)
+	viaProc := find(BackendProc)
+
+	if viaNetlink.Laddr != viaProc.Laddr || viaNetlink.Inode != viaProc.Inode || viaNetlink.PID != viaProc.PID {
+		t.Errorf("backends disagree: netlink=%+v proc=%+v", *viaNetlink, *viaProc)
+	}
+	// the backlog limit is not a send queue: sendq>0 must not match listeners
+	if viaNetlink.SendQ != 0 || viaProc.SendQ != 0 || viaNetlink.RecvQ != viaProc.RecvQ {
+		t.Errorf("listener queues disagree: netlink sendq=%d recvq=%d, proc sendq=%d recvq=%d",
+			viaNetlink.SendQ, viaNetlink.RecvQ, viaProc.SendQ, viaProc.RecvQ)
+	}
+}
+
+func TestUnknownBackend(t *testing.T) {
+	if _, err := NewDefaultCollector(Options{Backend: "bogus"}).GetConnections(); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+}
+
+func TestTCPInfoCounters(t *testing.T) {
+	diag, err := dialSockDiag()
+	if err != nil {
+		t.Skipf("netlink sock_diag unavailable: %v", err)
+	}
+	_ = diag.Close()
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+
+	client, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	server := <-accepted
+	defer func() { _ = server.Close() }()
+
+	payload := make([]byte, 64*1024)
+	if _, err := client.Write(payload); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, len(payload))
+	for n := 0; n < len(payload); {
+		m, err := server.Read(buf)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		n += m
+	}
+
+	clientPort := client.LocalAddr().(*net.TCPAddr).Port
+	conns, err := NewDefaultCollector(Options{Backend: BackendNetlink}).GetConnections()
+	if err != nil {
+		t.Fatalf("GetConnections() error: %v", err)
+	}
+
+	for _, c := range conns {
+		if c.Proto != "tcp" || c.Lport != clientPort {
+			continue
+		}
+		if c.TxBytes < int64(len(payload)) {
+			t.Errorf("TxBytes = %d, want >= %d", c.TxBytes, len(payload))
+		}
+		if c.RttMs <= 0 || c.Cwnd <= 0 {
+			t.Errorf("expected rtt and cwnd to be set, got rtt=%v cwnd=%d", c.RttMs, c.Cwnd)
+		}
+		return
+	}
+	t.Fatalf("client socket on port %d not found", clientPort)
+}
@@ -0,0 +1,334 @@
+//go:build linux
+
+package collector
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karol-broda/snitch/internal/errutil"
+)
+
+// systemdUnitDirs are the system unit search paths, highest precedence first
+var systemdUnitDirs = []string{
+	"/etc/systemd/system",
+	"/run/systemd/system",
+	"/run/systemd/transient",
+	"/usr/local/lib/systemd/system",
+	"/usr/lib/systemd/system",
+	"/lib/systemd/system",
+}
+
+// socketUnit is a .socket unit with the addresses it listens on
+type socketUnit struct {
+	name    string
+	service string // the unit it activates
+	listens []listenSpec
+}
+
+// listenSpec is one ListenStream=, ListenDatagram= or
+// ListenSequentialPacket= line
+type listenSpec struct {
+	sockType string // stream, dgram or seqpacket
+	path     string // unix sockets, '@' for the abstract namespace
+	addr     string // ip sockets, "" when only a port was given
+	port     int
+}
+
+var listenDirectives = map[string]string{
+	"ListenStream":           "stream",
+	"ListenDatagram":         "dgram",
+	"ListenSequentialPacket": "seqpacket",
+}
+
+// attributeSocketUnits names the .socket unit and the service behind the
+// sockets init holds for socket activation. unit files only describe the
+// running system, so other proc roots are left alone.
+func (dc *DefaultCollector) attributeSocketUnits(conns []Connection) {
+	if !dc.proc.isLive() {
+		return
+	}
+
+	var units []socketUnit
+	loaded := false
+	for i := range conns {
+		if !heldByInit(conns[i]) {
+			continue
+		}
+		if !loaded {
+			units = dc.socketUnits.get()
+			loaded = true
+		}
+		if unit, ok := matchSocketUnit(units, conns[i]); ok {
+			conns[i].SocketUnit = unit.name
+			conns[i].Unit = unit.service
+		}
+	}
+}
+
+// socketUnitCache keeps the parsed socket units until a unit directory, the
+// drop-in directory of a socket unit, or a unit file or drop-in changes
+type socketUnitCache struct {
+	dirs   []string
+	units  []socketUnit
+	stamps map[string]fileStamp // of every file and directory the units came from
+}
+
+// fileStamp tells whether a file changed: an edit in place leaves its
+// directory alone, but not its mtime or, within one clock tick, its size
+type fileStamp struct {
+	mtime time.Time
+	size  int64
+}
+
+func newSocketUnitCache(dirs []string) *socketUnitCache {
+	return &socketUnitCache{dirs: dirs}
+}
+
+// get returns the socket units, reading them again only when one of their
+// files or directories changed since the last read. a nil cache reads them
+// every time.
+func (c *socketUnitCache) get() []socketUnit {
+	if c == nil {
+		return loadSocketUnits(systemdUnitDirs)
+	}
+	if c.stamps != nil && !c.changed() {
+		return c.units
+	}
+
+	// stamp before reading, so a change made during the read is seen next time
+	c.stamps = make(map[string]fileStamp)
+	for _, dir := range c.dirs {
+		c.stamps[dir] = stampOf(dir)
+		for _, pattern := range []string{"*.socket", "*.socket.d", "*.socket.d/*.conf"} {
+			paths, _ := filepath.Glob(filepath.Join(dir, pattern))
+			for _, path := range paths {
+				c.stamps[path] = stampOf(path)
+			}
+		}
+	}
+	c.units = loadSocketUnits(c.dirs)
+	return c.units
+}
+
+func (c *socketUnitCache) changed() bool {
+	for path, stamp := range c.stamps {
+		if current := stampOf(path); !current.mtime.Equal(stamp.mtime) || current.size != stamp.size {
+			return true
+		}
+	}
+	return false
+}
+
+// stampOf stats path, following symlinks as systemd does. it is zero when
+// path does not exist.
+func stampOf(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{mtime: info.ModTime(), size: info.Size()}
+}
+
+func heldByInit(c Connection) bool {
+	for _, h := range c.Holders {
+		if h.PID == 1 {
+			return true
+		}
+	}
+	return c.PID == 1
+}
+
+// matchSocketUnit finds the socket unit listening on the socket's address
+func matchSocketUnit(units []socketUnit, c Connection) (socketUnit, bool) {
+	for _, unit := range units {
+		for _, l := range unit.listens {
+			if l.matches(c) {
+				return unit, true
+			}
+		}
+	}
+	return socketUnit{}, false
+}
+
+func (l listenSpec) matches(c Connection) bool {
+	if l.path != "" {
+		return c.Proto == "unix" && c.Laddr == l.path && c.SockType == l.sockType
+	}
+
+	var proto string
+	switch l.sockType {
+	case "stream":
+		proto = "tcp"
+	case "dgram":
+		proto = "udp"
+	default:
+		proto = "sctp"
+	}
+	if !matchesSingleProto(c.Proto, proto) || c.Lport != l.port {
+		return false
+	}
+	return l.addr == "" || l.addr == c.Laddr
+}
+
+// loadSocketUnits reads every .socket unit in dirs, each with its drop-ins.
+// a unit in an earlier dir hides one of the same name in a later dir.
+func loadSocketUnits(dirs []string) []socketUnit {
+	seen := make(map[string]bool)
+	var units []socketUnit
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			// templates only listen once instantiated
+			if !strings.HasSuffix(name, ".socket") || strings.HasSuffix(name, "@.socket") || seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			unit := socketUnit{name: name}
+			accept := false
+			files := append([]string{filepath.Join(dir, name)}, dropIns(dirs, name)...)
+			for _, file := range files {
+				parseSocketUnitFile(file, &unit, &accept)
+			}
+			if len(unit.listens) == 0 {
+				continue // masked, or listening on something else, e.g. a fifo
+			}
+
+			if unit.service == "" {
+				base := strings.TrimSuffix(name, ".socket")
+				if accept {
+					unit.service = base + "@.service"
+				} else {
+					unit.service = base + ".service"
+				}
+			}
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
+// dropIns lists the <unit>.d/*.conf files of a unit in the order systemd
+// applies them: sorted by file name, earlier dirs overriding later ones
+func dropIns(dirs []string, name string) []string {
+	byName := make(map[string]string)
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, name+".d", "*.conf"))
+		for _, m := range matches {
+			if _, ok := byName[filepath.Base(m)]; !ok {
+				byName[filepath.Base(m)] = m
+			}
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for n := range byName {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	files := make([]string, len(names))
+	for i, n := range names {
+		files[i] = byName[n]
+	}
+	return files
+}
+
+// parseSocketUnitFile applies the [Socket] section of a unit file or drop-in
+// to unit. an empty Listen line resets the addresses set so far.
+func parseSocketUnitFile(path string, unit *socketUnit, accept *bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer errutil.Close(file)
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+		if section != "Socket" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch key {
+		case "Service":
+			unit.service = value
+		case "Accept":
+			*accept = value == "yes" || value == "true" || value == "on" || value == "1"
+		default:
+			sockType, ok := listenDirectives[key]
+			if !ok {
+				continue
+			}
+			if value == "" {
+				unit.listens = nil
+				continue
+			}
+			if l, ok := parseListen(sockType, value); ok {
+				unit.listens = append(unit.listens, l)
+			}
+		}
+	}
+}
+
+// parseListen understands paths, abstract names, plain ports and
+// address:port pairs. addresses with specifiers other than %t, and vsock
+// addresses, are skipped.
+func parseListen(sockType, value string) (listenSpec, bool) {
+	l := listenSpec{sockType: sockType}
+
+	if strings.HasPrefix(value, "/") || strings.HasPrefix(value, "@") || strings.HasPrefix(value, "%t/") {
+		l.path = strings.Replace(value, "%t", "/run", 1)
+		return l, !strings.Contains(l.path, "%")
+	}
+
+	if port, err := strconv.Atoi(value); err == nil {
+		l.port = port
+		return l, true
+	}
+
+	host, portStr, err := net.SplitHostPort(value)
+	if err != nil {
+		return l, false
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return l, false
+	}
+	host, _, _ = strings.Cut(host, "%") // zone or bind device
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return l, false
+	}
+
+	l.port = port
+	if !ip.IsUnspecified() {
+		l.addr = ip.String()
+	}
+	return l, true
+}
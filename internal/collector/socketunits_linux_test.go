@@ -0,0 +1,135 @@
+//go:build linux
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeUnit(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSocketUnits(t *testing.T) {
+	etc, lib := t.TempDir(), t.TempDir()
+	writeUnit(t, lib, "sshd.socket", "[Unit]\nDescription=ssh\n\n[Socket]\nListenStream=22\nAccept=yes\n")
+	writeUnit(t, lib, "cups.socket", "[Socket]\nListenStream=/run/cups/cups.sock\n")
+	writeUnit(t, lib, "dns.socket", "[Socket]\nListenDatagram=127.0.0.1:53\nListenStream=[::1]:53\nService=resolver.service\n")
+	writeUnit(t, lib, "journald@.socket", "[Socket]\nListenDatagram=/run/systemd/journal/socket\n")
+	writeUnit(t, lib, "fifo.socket", "[Socket]\nListenFIFO=/run/initctl\n")
+	// /etc overrides the packaged unit, and a drop-in resets its addresses
+	writeUnit(t, etc, "cups.socket", "[Socket]\nListenStream=%t/cups/cups.sock\n")
+	writeUnit(t, etc, "dns.socket.d/10-local.conf", "[Socket]\nListenDatagram=\nListenDatagram=127.0.0.53:53\n")
+
+	units := loadSocketUnits([]string{etc, lib})
+	byName := map[string]socketUnit{}
+	for _, u := range units {
+		byName[u.name] = u
+	}
+	if len(units) != 3 {
+		t.Fatalf("expected 3 socket units, got %+v", units)
+	}
+	if byName["sshd.socket"].service != "sshd@.service" {
+		t.Errorf("accepting socket should activate the template, got %q", byName["sshd.socket"].service)
+	}
+	if byName["cups.socket"].service != "cups.service" {
+		t.Errorf("unexpected cups service %q", byName["cups.socket"].service)
+	}
+	if l := byName["dns.socket"].listens; len(l) != 1 || l[0].addr != "127.0.0.53" {
+		t.Errorf("drop-in did not reset the datagram address: %+v", l)
+	}
+
+	tests := []struct {
+		name string
+		conn Connection
+		want string
+	}{
+		{"port only", Connection{Proto: "tcp6", Laddr: "*", Lport: 22}, "sshd.socket"},
+		{"wrong proto", Connection{Proto: "udp", Laddr: "*", Lport: 22}, ""},
+		{"unix path with specifier", Connection{Proto: "unix", Laddr: "/run/cups/cups.sock", SockType: "stream"}, "cups.socket"},
+		{"unix type mismatch", Connection{Proto: "unix", Laddr: "/run/cups/cups.sock", SockType: "dgram"}, ""},
+		{"address", Connection{Proto: "udp", Laddr: "127.0.0.53", Lport: 53}, "dns.socket"},
+		{"reset address", Connection{Proto: "udp", Laddr: "127.0.0.1", Lport: 53}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit, ok := matchSocketUnit(units, tt.conn)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("expected no match, got %s", unit.name)
+				}
+				return
+			}
+			if !ok || unit.name != tt.want {
+				t.Errorf("matchSocketUnit() = %q, want %q", unit.name, tt.want)
+			}
+		})
+	}
+}
+
+func TestSocketUnitCache(t *testing.T) {
+	dir := t.TempDir()
+	writeUnit(t, dir, "sshd.socket", "[Socket]\nListenStream=22\n")
+	cache := newSocketUnitCache([]string{dir})
+
+	// mtimes are only as fine as the kernel's clock tick, so files and
+	// directories are dated back before they are stamped to keep later
+	// changes apart
+	age := func(path string) {
+		t.Helper()
+		past := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	age(dir)
+	age(filepath.Join(dir, "sshd.socket"))
+
+	if units := cache.get(); len(units) != 1 || units[0].listens[0].port != 22 {
+		t.Fatalf("unexpected units %+v", units)
+	}
+	if units := cache.get(); len(units) != 1 {
+		t.Fatalf("expected the cached units, got %+v", units)
+	}
+
+	// editing a file in place leaves the directory alone, but not the file
+	writeUnit(t, dir, "sshd.socket", "[Socket]\nListenStream=23\n")
+	if units := cache.get(); units[0].listens[0].port != 23 {
+		t.Errorf("expected an edit in place to be seen, got port %d", units[0].listens[0].port)
+	}
+
+	// a new drop-in directory changes the unit directory
+	writeUnit(t, dir, "sshd.socket.d/port.conf", "[Socket]\nListenStream=\nListenStream=2200\n")
+	age(filepath.Join(dir, "sshd.socket.d"))
+	if units := cache.get(); units[0].listens[0].port != 2200 {
+		t.Fatalf("expected the units to be read again, got %+v", units)
+	}
+
+	// and so does a drop-in saved into it, as editors do, by renaming
+	writeUnit(t, dir, "sshd.socket.d/.port.conf.swp", "[Socket]\nListenStream=\nListenStream=2201\n")
+	if err := os.Rename(filepath.Join(dir, "sshd.socket.d/.port.conf.swp"), filepath.Join(dir, "sshd.socket.d/port.conf")); err != nil {
+		t.Fatal(err)
+	}
+	if units := cache.get(); units[0].listens[0].port != 2201 {
+		t.Errorf("expected a drop-in change to be seen, got %+v", units)
+	}
+
+	// and an edit of the drop-in in place
+	age(filepath.Join(dir, "sshd.socket.d"))
+	age(filepath.Join(dir, "sshd.socket.d/port.conf"))
+	cache.get()
+	writeUnit(t, dir, "sshd.socket.d/port.conf", "[Socket]\nListenStream=\nListenStream=2202\n")
+	if units := cache.get(); units[0].listens[0].port != 2202 {
+		t.Errorf("expected a drop-in edited in place to be seen, got %+v", units)
+	}
+}
@@ -9,20 +9,27 @@ import (
 type SortField string
 
 const (
-	SortByPID        SortField = "pid"
-	SortByProcess    SortField = "process"
-	SortByUser       SortField = "user"
-	SortByProto      SortField = "proto"
-	SortByState      SortField = "state"
-	SortByLaddr      SortField = "laddr"
-	SortByLport      SortField = "lport"
-	SortByRaddr      SortField = "raddr"
-	SortByRport      SortField = "rport"
-	SortByInterface  SortField = "if"
-	SortByRxBytes    SortField = "rx_bytes"
-	SortByTxBytes    SortField = "tx_bytes"
-	SortByRttMs      SortField = "rtt_ms"
-	SortByTimestamp  SortField = "ts"
+	SortByPID          SortField = "pid"
+	SortByProcess      SortField = "process"
+	SortByUser         SortField = "user"
+	SortByProto        SortField = "proto"
+	SortByState        SortField = "state"
+	SortByLaddr        SortField = "laddr"
+	SortByLport        SortField = "lport"
+	SortByRaddr        SortField = "raddr"
+	SortByRport        SortField = "rport"
+	SortByInterface    SortField = "if"
+	SortByRxBytes      SortField = "rx_bytes"
+	SortByTxBytes      SortField = "tx_bytes"
+	SortByRttMs        SortField = "rtt_ms"
+	SortByCwnd         SortField = "cwnd"
+	SortByTotalRetrans SortField = "total_retrans"
+	SortByDeliveryRate SortField = "delivery_rate"
+	SortBySendQ        SortField = "sendq"
+	SortByRecvQ        SortField = "recvq"
+	SortByRetransmits  SortField = "retransmits"
+	SortByTimestamp    SortField = "ts"
+	SortByAge          SortField = "age"
 )
 
 // SortDirection represents ascending or descending order
@@ -99,8 +106,22 @@ func compareConnections(a, b Connection, field SortField) bool {
 		return a.TxBytes < b.TxBytes
 	case SortByRttMs:
 		return a.RttMs < b.RttMs
+	case SortByCwnd:
+		return a.Cwnd < b.Cwnd
+	case SortByTotalRetrans:
+		return a.TotalRetrans < b.TotalRetrans
+	case SortByDeliveryRate:
+		return a.DeliveryRate < b.DeliveryRate
+	case SortBySendQ:
+		return a.SendQ < b.SendQ
+	case SortByRecvQ:
+		return a.RecvQ < b.RecvQ
+	case SortByRetransmits:
+		return a.Retransmits < b.Retransmits
 	case SortByTimestamp:
 		return a.TS.Before(b.TS)
+	case SortByAge:
+		return a.Age < b.Age
 	default:
 		return a.Lport < b.Lport
 	}
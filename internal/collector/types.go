@@ -24,4 +24,80 @@ type Connection struct {
 	Mark       string    `json:"mark"`
 	Namespace  string    `json:"namespace"`
 	Inode      int64     `json:"inode"`
+	ID         string    `json:"id,omitempty"` // see ConnectionID
+	SockType   string    `json:"sock_type,omitempty"` // unix sockets only: stream, dgram, seqpacket
+
+	// every process holding the socket, sorted by pid. PID and Process are
+	// the first of them other than init, e.g. the master of a prefork server.
+	Holders []Holder `json:"holders,omitempty"`
+
+	// when the socket was first seen: across refreshes in top, watch and
+	// trace, otherwise estimated from when its fd showed up in proc
+	FirstSeen time.Time `json:"first_seen,omitzero"`
+	Age       int64     `json:"age,omitempty"` // seconds from FirstSeen to TS
+
+	// socket queues and the pending kernel timer, as shown by ss
+	SendQ          int64  `json:"sendq"`
+	RecvQ          int64  `json:"recvq"`
+	Timer          string `json:"timer,omitempty"` // off, on, keepalive, timewait, persist
+	TimerExpiresMs int64  `json:"timer_expires_ms"`
+	Retransmits    int    `json:"retransmits"` // unrecovered retransmits of the current timer
+
+	// container and kubernetes pod of the owning process, from its cgroup
+	ContainerID      string `json:"container_id,omitempty"`
+	ContainerRuntime string `json:"container_runtime,omitempty"` // docker, containerd, cri-o, podman
+	PodUID           string `json:"pod_uid,omitempty"`
+	PodQoS           string `json:"pod_qos,omitempty"` // Guaranteed, Burstable, BestEffort
+
+	// more about the owning process, for telling a daemon from an impostor
+	PPID          int       `json:"ppid,omitempty"`
+	StartTime     time.Time `json:"start_time,omitzero"`
+	Exe           string    `json:"exe,omitempty"`
+	ExeDeleted    bool      `json:"exe_deleted,omitempty"`     // the binary was removed or replaced since exec
+	CapEff        string    `json:"cap_eff,omitempty"`         // effective capabilities, hex as in /proc/<pid>/status
+	SecurityLabel string    `json:"security_label,omitempty"` // selinux context or apparmor profile
+
+	// systemd unit of the owning process. sockets systemd listens on for
+	// socket activation name the service they start and the .socket unit.
+	Unit       string `json:"unit,omitempty"`
+	SocketUnit string `json:"socket_unit,omitempty"`
+
+	// netfilter connection tracking entry of the flow, with --conntrack
+	Conntrack *Conntrack `json:"conntrack,omitempty"`
+
+	// tcp only, filled from the kernel's tcp_info by the netlink backend
+	Cwnd         int   `json:"cwnd"`          // congestion window in segments
+	TotalRetrans int   `json:"total_retrans"` // segments retransmitted over the socket's lifetime
+	DeliveryRate int64 `json:"delivery_rate"` // most recent delivery rate in bytes/s
+}
+
+// Conntrack is what netfilter's connection tracking knows about a flow
+type Conntrack struct {
+	Original ConntrackTuple `json:"original"`      // as the first packet was seen
+	Reply    ConntrackTuple `json:"reply"`         // as replies are expected, after nat
+	NAT      string         `json:"nat,omitempty"` // snat, dnat or snat+dnat
+	Mark     uint32         `json:"mark"`
+	Zone     uint16         `json:"zone"`
+
+	// the local end as the peer sees it and the remote end as it really
+	// is. they differ from laddr and raddr when the flow is natted.
+	NATLaddr string `json:"nat_laddr"`
+	NATLport int    `json:"nat_lport"`
+	NATRaddr string `json:"nat_raddr"`
+	NATRport int    `json:"nat_rport"`
+}
+
+// ConntrackTuple is one direction of a conntrack flow
+type ConntrackTuple struct {
+	Src   string `json:"src"`
+	Sport int    `json:"sport"`
+	Dst   string `json:"dst"`
+	Dport int    `json:"dport"`
+}
+
+// Holder is a process with an open file descriptor for a socket
+type Holder struct {
+	PID     int    `json:"pid"`
+	FD      int    `json:"fd"`
+	Process string `json:"process"`
 }
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Warning kinds
+const (
+	WarningUnreadablePIDs      = "unreadable_pids"
+	WarningUnattributedSockets = "unattributed_sockets"
+	WarningMissingCapabilities = "missing_capabilities"
+)
+
+// Warning describes something that kept the last collection from seeing
+// everything, typically running without root
+type Warning struct {
+	Kind         string   `json:"kind"`
+	Count        int      `json:"count,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	Message      string   `json:"message"`
+}
+
+// WarningReporter is implemented by collectors that can explain the gaps in
+// the result of their last GetConnections call
+type WarningReporter interface {
+	Warnings() []Warning
+}
+
+// GetWarnings returns the warnings of the global collector's last collection
+func GetWarnings() []Warning {
+	if r, ok := globalCollector.(WarningReporter); ok {
+		return r.Warnings()
+	}
+	return nil
+}
+
+// permissionWarnings builds the warnings for a collection in which denied
+// processes could not be inspected. unattributed sockets are only reported
+// alongside them, since kernel sockets never have an owner anyway.
+func permissionWarnings(denied, unattributed int, missingCaps []string) []Warning {
+	if denied == 0 {
+		return nil
+	}
+
+	warnings := []Warning{{
+		Kind:    WarningUnreadablePIDs,
+		Count:   denied,
+		Message: fmt.Sprintf("%d processes could not be inspected", denied),
+	}}
+	if unattributed > 0 {
+		warnings = append(warnings, Warning{
+			Kind:    WarningUnattributedSockets,
+			Count:   unattributed,
+			Message: fmt.Sprintf("%d sockets have no known process", unattributed),
+		})
+	}
+	if len(missingCaps) > 0 {
+		warnings = append(warnings, Warning{
+			Kind:         WarningMissingCapabilities,
+			Capabilities: missingCaps,
+			Message:      "missing " + strings.Join(missingCaps, ", "),
+		})
+	}
+	return warnings
+}
+
+// SummarizeWarnings joins warnings into one line with a hint, for status
+// lines and footers. it returns "" when there is nothing to report.
+func SummarizeWarnings(warnings []Warning) string {
+	if len(warnings) == 0 {
+		return ""
+	}
+	messages := make([]string, len(warnings))
+	for i, w := range warnings {
+		messages[i] = w.Message
+	}
+	return strings.Join(messages, "; ") + " (run as root to see everything)"
+}
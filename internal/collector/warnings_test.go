@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPermissionWarnings(t *testing.T) {
+	if w := permissionWarnings(0, 40, []string{"CAP_SYS_PTRACE"}); w != nil {
+		t.Errorf("expected no warnings when every process was readable, got %+v", w)
+	}
+
+	w := permissionWarnings(12, 3, []string{"CAP_DAC_READ_SEARCH", "CAP_SYS_PTRACE"})
+	if len(w) != 3 {
+		t.Fatalf("expected 3 warnings, got %+v", w)
+	}
+	if w[0].Kind != WarningUnreadablePIDs || w[0].Count != 12 {
+		t.Errorf("unexpected pid warning: %+v", w[0])
+	}
+	if w[1].Kind != WarningUnattributedSockets || w[1].Count != 3 {
+		t.Errorf("unexpected socket warning: %+v", w[1])
+	}
+	if w[2].Kind != WarningMissingCapabilities || len(w[2].Capabilities) != 2 {
+		t.Errorf("unexpected capability warning: %+v", w[2])
+	}
+
+	summary := SummarizeWarnings(w)
+	if !strings.Contains(summary, "12 processes could not be inspected") || !strings.Contains(summary, "run as root") {
+		t.Errorf("unexpected summary: %q", summary)
+	}
+	if SummarizeWarnings(nil) != "" {
+		t.Error("expected empty summary without warnings")
+	}
+}
@@ -7,14 +7,26 @@ import (
 	"strings"
 	"time"
 
+	"github.com/karol-broda/snitch/internal/sink"
 	"github.com/karol-broda/snitch/internal/theme"
 	"github.com/spf13/viper"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Defaults DefaultConfig `mapstructure:"defaults"`
-	TUI      TUIConfig     `mapstructure:"tui"`
+	Defaults  DefaultConfig   `mapstructure:"defaults"`
+	TUI       TUIConfig       `mapstructure:"tui"`
+	Collector CollectorConfig `mapstructure:"collector"`
+	Sinks     []sink.Config   `mapstructure:"sinks"`
+}
+
+// CollectorConfig contains settings for how sockets are collected
+type CollectorConfig struct {
+	Backend   string `mapstructure:"backend"`
+	ProcRoot  string `mapstructure:"proc_root"`
+	AllNetns  bool   `mapstructure:"all_netns"`
+	Unix      bool   `mapstructure:"unix"`
+	Conntrack bool   `mapstructure:"conntrack"`
 }
 
 // TUIConfig contains TUI-specific configuration
@@ -67,6 +79,11 @@ func Load() (*Config, error) {
 	_ = v.BindEnv("defaults.dns_cache", "SNITCH_DNS_CACHE")
 	_ = v.BindEnv("defaults.theme", "SNITCH_THEME")
 	_ = v.BindEnv("defaults.color", "SNITCH_NO_COLOR")
+	_ = v.BindEnv("collector.backend", "SNITCH_BACKEND")
+	_ = v.BindEnv("collector.proc_root", "SNITCH_PROC_ROOT")
+	_ = v.BindEnv("collector.all_netns", "SNITCH_ALL_NETNS")
+	_ = v.BindEnv("collector.unix", "SNITCH_UNIX")
+	_ = v.BindEnv("collector.conntrack", "SNITCH_CONNTRACK")
 	
 	// Set defaults
 	setDefaults(v)
@@ -114,6 +131,13 @@ func setDefaults(v *viper.Viper) {
 
 	// tui settings
 	v.SetDefault("tui.remember_state", false)
+
+	// collector settings
+	v.SetDefault("collector.backend", "netlink")
+	v.SetDefault("collector.proc_root", "/proc")
+	v.SetDefault("collector.all_netns", false)
+	v.SetDefault("collector.unix", false)
+	v.SetDefault("collector.conntrack", false)
 }
 
 func handleSpecialEnvVars(v *viper.Viper) {
@@ -158,6 +182,10 @@ func Get() *Config {
 				TUI: TUIConfig{
 					RememberState: false,
 				},
+				Collector: CollectorConfig{
+					Backend:  "netlink",
+					ProcRoot: "/proc",
+				},
 			}
 		}
 		return config
@@ -216,6 +244,41 @@ sort_by = ""
 # remember view options (filters, sort, resolution) between sessions
 # state is saved to $XDG_STATE_HOME/snitch/tui.json
 remember_state = false
+
+[collector]
+# how sockets are enumerated on linux: netlink (sock_diag) or proc
+# netlink falls back to parsing /proc/net when it is unavailable
+backend = "netlink"
+
+# where the proc filesystem is mounted, e.g. /host/proc in a sidecar
+# anything other than /proc is always read through files, never netlink
+proc_root = "/proc"
+
+# collect sockets from every network namespace (containers, pods, ip netns)
+# instead of only the one snitch runs in
+all_netns = false
+
+# include unix domain sockets, as if --unix was always given
+unix = false
+
+# join the netfilter conntrack table, as if --conntrack was always given
+conntrack = false
+
+# destinations trace and watch send their json events to, besides stdout,
+# in addition to any --sink flags. types: file, syslog, journald, webhook, unix
+# [[sinks]]
+# type = "file"
+# target = "/var/log/snitch/trace.ndjson"
+# max_size = "100MB"
+# max_age = "24h"
+# backups = 7
+#
+# [[sinks]]
+# type = "webhook"
+# target = "https://example.com/snitch"
+# batch = 100
+# flush = "1s"
+# retries = 3  # 0 sends each batch once
 `, themeList, theme.DefaultTheme)
 
 	// Ensure directory exists
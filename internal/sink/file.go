@@ -0,0 +1,151 @@
+package sink
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rotatedSuffix is appended to the path of a rotated file. it sorts by time.
+const rotatedSuffix = "20060102T150405.000"
+
+// fileSink appends events as ndjson to a file, rotating it by size and age.
+// a rotated file is renamed to <path>.<time>, and the oldest are removed
+// beyond the number of backups to keep.
+type fileSink struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	backups int
+
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+func openFile(c Config) (Sink, error) {
+	if c.Target == "" {
+		return nil, fmt.Errorf("file sink needs a path")
+	}
+	s := &fileSink{path: c.Target, maxAge: c.MaxAge, backups: c.Backups}
+	if c.MaxSize != "" {
+		size, err := parseSize(c.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+		s.maxSize = size
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.f = f
+	s.size = info.Size()
+	// the creation time of a file is not portable, so an existing file ages
+	// from when it was opened
+	s.opened = time.Now()
+	return nil
+}
+
+func (s *fileSink) Write(event []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	line := append(event[:len(event):len(event)], '\n')
+	if s.size > 0 && s.due(int64(len(line))) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *fileSink) due(next int64) bool {
+	if s.maxSize > 0 && s.size+next > s.maxSize {
+		return true
+	}
+	return s.maxAge > 0 && time.Since(s.opened) >= s.maxAge
+}
+
+func (s *fileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	s.f = nil
+
+	rotated := s.path + "." + time.Now().Format(rotatedSuffix)
+	if err := os.Rename(s.path, rotated); err != nil {
+		return err
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	return s.prune()
+}
+
+// prune removes the oldest rotated files beyond the number of backups
+func (s *fileSink) prune() error {
+	if s.backups <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(s.path + ".*")
+	if err != nil {
+		return err
+	}
+
+	var rotated []string
+	for _, m := range matches {
+		if _, err := time.Parse(rotatedSuffix, m[len(s.path)+1:]); err == nil {
+			rotated = append(rotated, m)
+		}
+	}
+	sort.Strings(rotated)
+
+	for len(rotated) > s.backups {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
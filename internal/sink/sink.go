@@ -0,0 +1,223 @@
+// Package sink delivers json events to destinations other than stdout:
+// rotating files, syslog, journald, http webhooks and unix sockets.
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink receives events one at a time
+type Sink interface {
+	// Write delivers one json-encoded event, without a trailing newline
+	Write(event []byte) error
+	// Close flushes buffered events and releases the destination
+	Close() error
+}
+
+// Config describes one destination. it is read from the [[sinks]] tables of
+// the config file or parsed from a --sink flag.
+type Config struct {
+	Type   string `mapstructure:"type"`   // file, syslog, journald, webhook or unix
+	Target string `mapstructure:"target"` // path, url or address, depending on type
+
+	// file
+	MaxSize string        `mapstructure:"max_size"` // rotate past this size, e.g. 100MB
+	MaxAge  time.Duration `mapstructure:"max_age"`  // rotate files older than this
+	Backups int           `mapstructure:"backups"`  // rotated files to keep, 0 keeps all
+
+	// syslog and journald
+	Tag string `mapstructure:"tag"`
+
+	// webhook
+	Batch   int           `mapstructure:"batch"`   // events per request
+	Flush   time.Duration `mapstructure:"flush"`   // send a partial batch after this long
+	Retries *int          `mapstructure:"retries"` // attempts after a failed request, 3 when unset
+	Timeout time.Duration `mapstructure:"timeout"` // per request
+}
+
+func (c Config) String() string {
+	if c.Target == "" {
+		return c.Type
+	}
+	return c.Type + ":" + c.Target
+}
+
+// Parse reads a sink from its flag form, type[:target][,key=value...], e.g.
+//
+//	file:/var/log/snitch/trace.ndjson,max_size=100MB,max_age=24h,backups=7
+//	webhook:https://example.com/hook,batch=50,flush=2s
+func Parse(spec string) (Config, error) {
+	parts := strings.Split(spec, ",")
+	var c Config
+	c.Type, c.Target, _ = strings.Cut(parts[0], ":")
+
+	for _, opt := range parts[1:] {
+		key, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			return c, fmt.Errorf("sink %q: option %q is not key=value", spec, opt)
+		}
+		var err error
+		switch key {
+		case "max_size":
+			_, err = parseSize(value)
+			c.MaxSize = value
+		case "max_age":
+			c.MaxAge, err = time.ParseDuration(value)
+		case "backups":
+			c.Backups, err = strconv.Atoi(value)
+		case "tag":
+			c.Tag = value
+		case "batch":
+			c.Batch, err = strconv.Atoi(value)
+		case "flush":
+			c.Flush, err = time.ParseDuration(value)
+		case "retries":
+			var n int
+			n, err = strconv.Atoi(value)
+			c.Retries = &n
+		case "timeout":
+			c.Timeout, err = time.ParseDuration(value)
+		default:
+			return c, fmt.Errorf("sink %q: unknown option %q", spec, key)
+		}
+		if err != nil {
+			return c, fmt.Errorf("sink %q: %s: %w", spec, key, err)
+		}
+	}
+	return c, nil
+}
+
+// Open connects to the destination c describes
+func Open(c Config) (Sink, error) {
+	switch c.Type {
+	case "file":
+		return openFile(c)
+	case "syslog":
+		return openSyslog(c)
+	case "journald":
+		return openJournald(c)
+	case "webhook":
+		return openWebhook(c)
+	case "unix":
+		return openUnix(c)
+	case "":
+		return nil, fmt.Errorf("sink without type")
+	default:
+		return nil, fmt.Errorf("unknown sink type %q (expected file, syslog, journald, webhook, unix)", c.Type)
+	}
+}
+
+// parseSize reads a byte count like 512, 10K, 100MB or 1GiB
+func parseSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	upper = strings.TrimSuffix(strings.TrimSuffix(upper, "B"), "I")
+
+	mult := int64(1)
+	if n := len(upper); n > 0 {
+		switch upper[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			upper = upper[:n-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// Fanout writes every event to a set of sinks. a failing sink is reported
+// once, and again when it recovers, so a dead destination does not flood
+// stderr or hold up the others.
+type Fanout struct {
+	mu      sync.Mutex
+	sinks   []Sink
+	names   []string
+	failing []bool
+	errw    io.Writer
+}
+
+// OpenAll opens every configured sink, closing those already opened if one
+// fails. errors from writes go to errw.
+func OpenAll(configs []Config, errw io.Writer) (*Fanout, error) {
+	f := &Fanout{errw: errw}
+	for _, c := range configs {
+		s, err := Open(c)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("sink %s: %w", c, err)
+		}
+		f.sinks = append(f.sinks, s)
+		f.names = append(f.names, c.String())
+		f.failing = append(f.failing, false)
+	}
+	return f, nil
+}
+
+// Write delivers one json-encoded event to every sink
+func (f *Fanout) Write(event []byte) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, s := range f.sinks {
+		err := s.Write(event)
+		switch {
+		case err != nil && !f.failing[i]:
+			_, _ = fmt.Fprintf(f.errw, "sink %s: %v\n", f.names[i], err)
+		case err == nil && f.failing[i]:
+			_, _ = fmt.Fprintf(f.errw, "sink %s: recovered\n", f.names[i])
+		}
+		f.failing[i] = err != nil
+	}
+}
+
+// Send encodes v as json and writes it to every sink
+func (f *Fanout) Send(v any) {
+	if f == nil || len(f.sinks) == 0 {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		_, _ = fmt.Fprintf(f.errw, "sink: %v\n", err)
+		return
+	}
+	f.Write(data)
+}
+
+// Close flushes and closes every sink
+func (f *Fanout) Close() error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var first error
+	for i, s := range f.sinks {
+		if err := s.Close(); err != nil {
+			_, _ = fmt.Fprintf(f.errw, "sink %s: %v\n", f.names[i], err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	f.sinks = nil
+	return first
+}
@@ -0,0 +1,329 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	c, err := Parse("file:/var/log/snitch/trace.ndjson,max_size=10MB,max_age=24h,backups=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Type != "file" || c.Target != "/var/log/snitch/trace.ndjson" || c.MaxSize != "10MB" || c.MaxAge != 24*time.Hour || c.Backups != 3 {
+		t.Errorf("unexpected config %+v", c)
+	}
+
+	c, err = Parse("webhook:https://example.com:8443/hook,batch=5,flush=2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Target != "https://example.com:8443/hook" || c.Batch != 5 || c.Flush != 2*time.Second || c.Retries != nil {
+		t.Errorf("unexpected config %+v", c)
+	}
+	if c, err := Parse("webhook:https://example.com/hook,retries=0"); err != nil || c.Retries == nil || *c.Retries != 0 {
+		t.Errorf("expected retries=0 to be kept apart from unset, got %+v, %v", c, err)
+	}
+
+	if c, err := Parse("journald"); err != nil || c.Type != "journald" || c.Target != "" {
+		t.Errorf("Parse(journald) = %+v, %v", c, err)
+	}
+
+	for _, spec := range []string{"file:x,max_size=lots", "file:x,colour=red", "file:x,backups"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q): expected an error", spec)
+		}
+	}
+	if _, err := Open(Config{Type: "carrier-pigeon"}); err == nil {
+		t.Error("expected an unknown sink type to be rejected")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{"512": 512, "10K": 10 << 10, "100MB": 100 << 20, "1GiB": 1 << 30, "2m": 2 << 20} {
+		if got, err := parseSize(in); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "trace.ndjson")
+	s, err := Open(Config{Type: "file", Target: path, MaxSize: "20", Backups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each event is 10 bytes with its newline, so every file holds two
+	for i := 0; i < 8; i++ {
+		if err := s.Write([]byte(`{"n":123}`)); err != nil {
+			t.Fatal(err)
+		}
+		// rotated names have millisecond precision
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 20 {
+		t.Errorf("expected the current file to hold two events, got %q", data)
+	}
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 2 {
+		t.Errorf("expected 2 rotated files to be kept, got %v", rotated)
+	}
+}
+
+func TestFileRotationByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.ndjson")
+	s, err := Open(Config{Type: "file", Target: path, MaxAge: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+
+	_ = s.Write([]byte(`{}`))
+	time.Sleep(5 * time.Millisecond)
+	_ = s.Write([]byte(`{}`))
+
+	if rotated, _ := filepath.Glob(path + ".*"); len(rotated) != 1 {
+		t.Errorf("expected the aged file to be rotated, got %v", rotated)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer srv.Close()
+
+	s, err := Open(Config{Type: "webhook", Target: srv.URL, Batch: 2, Flush: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		if err := s.Write([]byte(event)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first full batch fails once and is retried after a second
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(bodies)
+		mu.Unlock()
+		if n == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the partial batch is sent on close
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"{\"n\":1}\n{\"n\":2}\n", "{\"n\":3}\n"}
+	if strings.Join(bodies, "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected batches %q, want %q", bodies, want)
+	}
+}
+
+func TestWebhookRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	s, err := Open(Config{Type: "webhook", Target: srv.URL, Batch: 1, Flush: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Write([]byte(`{}`))
+	if err := s.Close(); err == nil || strings.Contains(err.Error(), "not delivered") {
+		t.Errorf("expected a rejected batch to be reported and dropped, got %v", err)
+	}
+}
+
+func TestWebhookNoRetries(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c, err := Parse("webhook:" + srv.URL + ",batch=1,flush=1h,retries=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+	_ = s.Write([]byte(`{}`))
+
+	// a retry would come a second after the first attempt
+	time.Sleep(1500 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Errorf("expected exactly one attempt with retries=0, got %d", calls)
+	}
+}
+
+func TestUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.sock")
+
+	s, err := Open(Config{Type: "unix", Target: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+
+	// the reader is not up yet
+	if err := s.Write([]byte(`{"n":0}`)); err == nil {
+		t.Error("expected a write without reader to fail")
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		lines <- line
+		_ = conn.Close()
+	}()
+
+	if err := s.Write([]byte(`{"n":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case line := <-lines:
+		if line != "{\"n\":1}\n" {
+			t.Errorf("unexpected line %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+}
+
+func TestJournald(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	s, err := Open(Config{Type: "journald", Target: path, Tag: "snitch-trace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.Write([]byte(`{"event":"opened"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(buf[:n])
+	if !strings.Contains(got, "SYSLOG_IDENTIFIER=snitch-trace\n") || !strings.Contains(got, "MESSAGE={\"event\":\"opened\"}\n") {
+		t.Errorf("unexpected journal entry %q", got)
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	s, err := Open(Config{Type: "syslog", Target: "udp://" + conn.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.Write([]byte(`{"event":"closed"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); !strings.Contains(got, "snitch") || !strings.Contains(got, `{"event":"closed"}`) {
+		t.Errorf("unexpected syslog message %q", got)
+	}
+}
+
+func TestFanoutReportsFailuresOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.sock")
+	var errs bytes.Buffer
+	f, err := OpenAll([]Config{{Type: "unix", Target: path}}, &errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	f.Send(map[string]int{"n": 1})
+	f.Send(map[string]int{"n": 2})
+	if n := strings.Count(errs.String(), "sink unix:"+path); n != 1 {
+		t.Errorf("expected one failure report, got %q", errs.String())
+	}
+
+	var none *Fanout
+	none.Send(1)
+	if err := none.Close(); err != nil {
+		t.Errorf("nil fanout: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package sink
+
+import (
+	"fmt"
+	"log/syslog"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// defaultTag is the program name syslog and journald entries carry
+const defaultTag = "snitch"
+
+// journalSocket is where journald reads native protocol datagrams
+const journalSocket = "/run/systemd/journal/socket"
+
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// openSyslog logs to the local syslog daemon, or to a remote one given as
+// udp://host:port or tcp://host:port
+func openSyslog(c Config) (Sink, error) {
+	var network, addr string
+	if c.Target != "" {
+		u, err := url.Parse(c.Target)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid syslog address %q (expected udp://host:port or tcp://host:port)", c.Target)
+		}
+		network, addr = u.Scheme, u.Host
+	}
+
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, tagOf(c))
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Write(event []byte) error {
+	return s.w.Info(string(event))
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
+
+// journaldSink sends events to journald over its native protocol, so they
+// keep their identifier and priority without passing through syslog
+type journaldSink struct {
+	mu   sync.Mutex
+	conn net.Conn
+	tag  string
+}
+
+func openJournald(c Config) (Sink, error) {
+	path := c.Target
+	if path == "" {
+		path = journalSocket
+	}
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		return nil, err
+	}
+	return &journaldSink{conn: conn, tag: tagOf(c)}, nil
+}
+
+func (s *journaldSink) Write(event []byte) error {
+	var b strings.Builder
+	b.WriteString("SYSLOG_IDENTIFIER=")
+	b.WriteString(s.tag)
+	b.WriteString("\nPRIORITY=6\nMESSAGE=")
+	// encoded json never holds a raw newline, so the simple form is enough
+	b.Write(event)
+	b.WriteByte('\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.conn.Write([]byte(b.String()))
+	return err
+}
+
+func (s *journaldSink) Close() error {
+	return s.conn.Close()
+}
+
+func tagOf(c Config) string {
+	if c.Tag != "" {
+		return c.Tag
+	}
+	return defaultTag
+}
@@ -0,0 +1,75 @@
+package sink
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// unixWriteTimeout bounds how long a slow reader can hold up trace
+const unixWriteTimeout = 5 * time.Second
+
+// unixSink writes ndjson to a unix stream socket another process listens
+// on. it connects on the first event and reconnects when the connection is
+// lost, so the reader can start after snitch or be restarted.
+type unixSink struct {
+	mu   sync.Mutex
+	path string
+	conn net.Conn
+}
+
+func openUnix(c Config) (Sink, error) {
+	if c.Target == "" {
+		return nil, fmt.Errorf("unix sink needs a socket path")
+	}
+	return &unixSink{path: c.Target}, nil
+}
+
+func (s *unixSink) dial() error {
+	conn, err := net.DialTimeout("unix", s.path, unixWriteTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *unixSink) Write(event []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line := append(event[:len(event):len(event)], '\n')
+
+	// a write on a connection the reader closed can still succeed once, so
+	// a failure is retried on a fresh connection a single time
+	for attempt := 0; ; attempt++ {
+		if s.conn == nil {
+			if err := s.dial(); err != nil {
+				return err
+			}
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(unixWriteTimeout))
+		_, err := s.conn.Write(line)
+		if err == nil {
+			return nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+func (s *unixSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
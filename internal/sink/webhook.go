@@ -0,0 +1,220 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// webhook defaults
+const (
+	defaultBatch   = 100
+	defaultFlush   = time.Second
+	defaultRetries = 3
+	defaultTimeout = 10 * time.Second
+
+	// batches held back while the endpoint is down, before the oldest
+	// events are dropped
+	maxPendingBatches = 100
+)
+
+// webhookSink posts events as ndjson batches. a batch is sent when it is
+// full or has waited for the flush interval, and retried with backoff when
+// the request fails or the endpoint answers 429 or 5xx.
+type webhookSink struct {
+	url     string
+	client  *http.Client
+	batch   int
+	flush   time.Duration
+	retries int
+
+	mu      sync.Mutex
+	pending [][]byte
+	dropped int
+	err     error // last delivery error, reported by the next Write
+
+	kick chan struct{}
+	ctx  context.Context
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func openWebhook(c Config) (Sink, error) {
+	if c.Target == "" {
+		return nil, fmt.Errorf("webhook sink needs a url")
+	}
+	s := &webhookSink{
+		url:     c.Target,
+		client:  &http.Client{Timeout: orDefault(c.Timeout, defaultTimeout)},
+		batch:   c.Batch,
+		flush:   orDefault(c.Flush, defaultFlush),
+		retries: defaultRetries,
+		kick:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if s.batch <= 0 {
+		s.batch = defaultBatch
+	}
+	if c.Retries != nil {
+		if *c.Retries < 0 {
+			return nil, fmt.Errorf("webhook sink retries cannot be negative")
+		}
+		s.retries = *c.Retries
+	}
+	s.ctx, s.stop = context.WithCancel(context.Background())
+
+	go s.loop()
+	return s, nil
+}
+
+func (s *webhookSink) Write(event []byte) error {
+	s.mu.Lock()
+	s.pending = append(s.pending, append([]byte(nil), event...))
+	if over := len(s.pending) - s.batch*maxPendingBatches; over > 0 {
+		s.pending = s.pending[over:]
+		s.dropped += over
+	}
+	full := len(s.pending) >= s.batch
+	err := s.err
+	s.err = nil
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+func (s *webhookSink) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flush)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			// a last attempt for what is left, without waiting on retries
+			for s.send(false) {
+			}
+			return
+		case <-ticker.C:
+		case <-s.kick:
+		}
+		for s.send(true) {
+		}
+	}
+}
+
+// send posts the next batch and reports whether more are waiting
+func (s *webhookSink) send(retry bool) bool {
+	s.mu.Lock()
+	n := min(len(s.pending), s.batch)
+	batch := s.pending[:n]
+	dropped := s.dropped
+	s.mu.Unlock()
+	if n == 0 {
+		return false
+	}
+
+	var body bytes.Buffer
+	for _, event := range batch {
+		body.Write(event)
+		body.WriteByte('\n')
+	}
+
+	err := s.post(body.Bytes())
+	var rejected *rejectedError
+	for attempt := 0; err != nil && !errors.As(err, &rejected) && retry && attempt < s.retries; attempt++ {
+		select {
+		case <-time.After(time.Duration(1<<attempt) * time.Second):
+		case <-s.ctx.Done():
+			retry = false
+			continue
+		}
+		err = s.post(body.Bytes())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.err = err
+		if !errors.As(err, &rejected) {
+			// keep the batch for the next flush
+			return false
+		}
+	}
+	// the pending slice may have been trimmed meanwhile, drop what was sent
+	// or rejected
+	sent := max(n-(s.dropped-dropped), 0)
+	s.pending = s.pending[min(sent, len(s.pending)):]
+	if s.dropped > 0 {
+		s.err = fmt.Errorf("dropped %d events while the endpoint was unreachable", s.dropped)
+		s.dropped = 0
+	}
+	return len(s.pending) >= s.batch || (!retry && len(s.pending) > 0)
+}
+
+func (s *webhookSink) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests:
+		return &rejectedError{status: resp.Status}
+	default:
+		return fmt.Errorf("%s", resp.Status)
+	}
+}
+
+// rejectedError is a 4xx answer: sending the batch again would not help
+type rejectedError struct {
+	status string
+}
+
+func (e *rejectedError) Error() string {
+	return "batch rejected: " + e.status
+}
+
+// Close sends what is still pending, once, and stops the sink
+func (s *webhookSink) Close() error {
+	s.stop()
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil && len(s.pending) == 0 {
+		return s.err
+	}
+	if len(s.pending) > 0 {
+		return fmt.Errorf("%d events not delivered: %v", len(s.pending), s.err)
+	}
+	return nil
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
@@ -1,9 +1,12 @@
 package tui
 
 import (
+	"fmt"
 	"regexp"
 	"github.com/karol-broda/snitch/internal/collector"
+	"sort"
 	"strings"
+	"time"
 )
 
 func truncate(s string, max int) string {
@@ -26,6 +29,107 @@ func containsIgnoreCase(s, substr string) bool {
 	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
 }
 
+// namespaces returns the distinct network namespaces in the current data,
+// host first
+func (m model) namespaces() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, c := range m.connections {
+		if c.Namespace != "" && !seen[c.Namespace] {
+			seen[c.Namespace] = true
+			names = append(names, c.Namespace)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == collector.HostNamespace) != (names[j] == collector.HostNamespace) {
+			return names[i] == collector.HostNamespace
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// formatBytes renders a byte count with a binary unit suffix
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatHolders lists the processes sharing a socket, one "pid name (fd n)"
+// entry each
+func formatHolders(holders []collector.Holder) string {
+	parts := make([]string, len(holders))
+	for i, h := range holders {
+		parts[i] = fmt.Sprintf("%d %s (fd %d)", h.PID, h.Process, h.FD)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatExe flags a binary that was deleted or replaced after the process
+// started, a common sign of an upgraded daemon or of an intruder
+func formatExe(exe string, deleted bool) string {
+	if exe != "" && deleted {
+		return exe + " (deleted)"
+	}
+	return exe
+}
+
+func formatStarted(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s ago)", t.Format("2006-01-02 15:04:05"), formatAge(time.Since(t)))
+}
+
+// formatSocketAge shows how long ago a socket was first seen
+func formatSocketAge(c collector.Connection) string {
+	if c.FirstSeen.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%s (since %s)", formatAge(time.Duration(c.Age)*time.Second), c.FirstSeen.Format("15:04:05"))
+}
+
+// formatAge renders a long duration with its two largest units, e.g. 3d4h
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
+
+// formatCapabilities names the effective capabilities of a process, "all"
+// for a full set and "none" for an empty one
+func formatCapabilities(capEff string) string {
+	if capEff == "" {
+		return ""
+	}
+	if collector.HasAllCapabilities(capEff) {
+		return "all"
+	}
+	names := collector.CapabilityNames(capEff)
+	if len(names) == 0 {
+		return "none"
+	}
+	for i, name := range names {
+		names[i] = strings.ToLower(strings.TrimPrefix(name, "CAP_"))
+	}
+	return strings.Join(names, ", ")
+}
+
 func sortFieldLabel(f collector.SortField) string {
 	switch f {
 	case collector.SortByLport:
@@ -42,6 +146,8 @@ func sortFieldLabel(f collector.SortField) string {
 		return "raddr"
 	case collector.SortByRport:
 		return "rport"
+	case collector.SortByAge:
+		return "age"
 	default:
 		return "port"
 	}
@@ -224,8 +224,20 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.showListening = true
 		m.showEstablished = true
 		m.showOther = true
+		m.namespace = ""
 		m.saveState()
 
+	case "z":
+		m.cycleNamespace()
+		m.clampCursor()
+		label := m.namespace
+		if label == "" {
+			label = "all"
+		}
+		m.statusMessage = fmt.Sprintf("namespace: %s", label)
+		m.statusExpiry = time.Now().Add(2 * time.Second)
+		return m, clearStatusAfter(2 * time.Second)
+
 	// sorting
 	case "s":
 		m.cycleSort()
@@ -300,6 +312,17 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	// kill process
 	case "K":
+		// the pids of a snapshot belong to another host, or another time
+		if m.snapshot != "" {
+			m.statusMessage = "snapshot: processes cannot be killed"
+			m.statusExpiry = time.Now().Add(2 * time.Second)
+			return m, clearStatusAfter(2 * time.Second)
+		}
+		if m.remote != "" {
+			m.statusMessage = "remote: processes cannot be killed from here"
+			m.statusExpiry = time.Now().Add(2 * time.Second)
+			return m, clearStatusAfter(2 * time.Second)
+		}
 		visible := m.visibleConnections()
 		if m.cursor < len(visible) {
 			conn := visible[m.cursor]
@@ -359,6 +382,26 @@ func (m model) pageSize() int {
 	return size
 }
 
+// cycleNamespace steps through the network namespaces present in the current
+// data, then back to showing all of them
+func (m *model) cycleNamespace() {
+	names := m.namespaces()
+	if m.namespace == "" {
+		if len(names) > 0 {
+			m.namespace = names[0]
+		}
+		return
+	}
+
+	for i, name := range names {
+		if name == m.namespace && i+1 < len(names) {
+			m.namespace = names[i+1]
+			return
+		}
+	}
+	m.namespace = ""
+}
+
 func (m *model) cycleSort() {
 	fields := []collector.SortField{
 		collector.SortByLport,
@@ -368,6 +411,7 @@ func (m *model) cycleSort() {
 		collector.SortByProto,
 		collector.SortByRaddr,
 		collector.SortByRport,
+		collector.SortByAge,
 	}
 
 	for i, f := range fields {
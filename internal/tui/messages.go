@@ -14,6 +14,7 @@ type tickMsg time.Time
 
 type dataMsg struct {
 	connections []collector.Connection
+	warnings    []collector.Warning
 }
 
 type errMsg struct {
@@ -50,7 +51,7 @@ func (m model) fetchData() tea.Cmd {
 			}
 			resolver.ResolveAddrsParallel(addrs)
 		}
-		return dataMsg{connections: conns}
+		return dataMsg{connections: conns, warnings: collector.GetWarnings()}
 	}
 }
 
@@ -28,6 +28,7 @@ type model struct {
 	showOther       bool
 	searchQuery     string
 	searchActive    bool
+	namespace       string // only show this network namespace, "" for all
 
 	// sorting
 	sortField   collector.SortField
@@ -57,6 +58,9 @@ type model struct {
 	statusMessage string
 	statusExpiry  time.Time
 
+	// what the collector could not see, e.g. without root
+	warnings []collector.Warning
+
 	// export modal
 	showExportModal bool
 	exportFilename  string
@@ -65,6 +69,10 @@ type model struct {
 
 	// state persistence
 	rememberState bool
+
+	// where the connections come from when they are not the live host's
+	snapshot string
+	remote   string
 }
 
 type Options struct {
@@ -75,11 +83,13 @@ type Options struct {
 	Listening     bool
 	Established   bool
 	Other         bool
-	FilterSet     bool // true if user specified any filter flags
-	ResolveAddrs  bool // when true, resolve IP addresses to hostnames
-	ResolvePorts  bool // when true, resolve port numbers to service names
-	NoCache       bool // when true, disable DNS caching
-	RememberState bool // when true, persist view options between sessions
+	FilterSet     bool   // true if user specified any filter flags
+	ResolveAddrs  bool   // when true, resolve IP addresses to hostnames
+	ResolvePorts  bool   // when true, resolve port numbers to service names
+	NoCache       bool   // when true, disable DNS caching
+	RememberState bool   // when true, persist view options between sessions
+	Snapshot      string // set when replaying a snapshot: its host and time
+	Remote        string // set when reading an agent: its address
 }
 
 func New(opts Options) model {
@@ -151,6 +161,8 @@ func New(opts Options) model {
 		lastRefresh:     time.Now(),
 		watchedPIDs:     make(map[int]bool),
 		rememberState:   opts.RememberState,
+		snapshot:        opts.Snapshot,
+		remote:          opts.Remote,
 	}
 }
 
@@ -177,6 +189,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case dataMsg:
 		m.connections = msg.connections
+		m.warnings = msg.warnings
 		m.lastRefresh = time.Now()
 		m.applySorting()
 		m.clampCursor()
@@ -275,6 +288,10 @@ func (m model) visibleConnections() []collector.Connection {
 }
 
 func (m model) matchesFilters(c collector.Connection) bool {
+	if m.namespace != "" && c.Namespace != m.namespace {
+		return false
+	}
+
 	isTCP := c.Proto == "tcp" || c.Proto == "tcp6"
 	isUDP := c.Proto == "udp" || c.Proto == "udp6"
 
@@ -313,6 +330,9 @@ func (m model) matchesSearch(c collector.Connection) bool {
 		containsIgnoreCase(c.User, m.searchQuery) ||
 		containsIgnoreCase(c.Proto, m.searchQuery) ||
 		containsIgnoreCase(c.State, m.searchQuery) ||
+		containsIgnoreCase(c.Namespace, m.searchQuery) ||
+		containsIgnoreCase(c.ContainerID, m.searchQuery) ||
+		containsIgnoreCase(c.Unit, m.searchQuery) ||
 		containsIgnoreCase(lportStr, m.searchQuery) ||
 		containsIgnoreCase(rportStr, m.searchQuery) ||
 		containsIgnoreCase(pidStr, m.searchQuery)
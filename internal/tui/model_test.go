@@ -485,6 +485,94 @@ func TestTUI_MatchesSearchPort(t *testing.T) {
 	}
 }
 
+func TestTUI_UnitColumn(t *testing.T) {
+	m := New(Options{Theme: "dark", Interval: time.Hour})
+	m.width = 160
+	m.height = 40
+	m.connections = []collector.Connection{
+		{Process: "curl", Proto: "tcp", State: "ESTABLISHED", Lport: 40000},
+	}
+	if cols := m.columnWidths(); cols.unit != 0 {
+		t.Error("expected no unit column without systemd units")
+	}
+
+	m.connections = append(m.connections, collector.Connection{
+		PID: 1, Process: "systemd", Proto: "tcp", State: "LISTEN", Lport: 22,
+		Unit: "sshd@.service", SocketUnit: "sshd.socket",
+	})
+	if cols := m.columnWidths(); cols.unit != len("sshd@.service") {
+		t.Errorf("expected unit column sized to its content, got %d", cols.unit)
+	}
+	view := m.View()
+	if !strings.Contains(view, "UNIT") || !strings.Contains(view, "sshd@.service") {
+		t.Error("expected unit column in main view")
+	}
+
+	m.searchQuery = "sshd@"
+	if len(m.visibleConnections()) != 1 {
+		t.Errorf("expected search to match the unit, got %d connections", len(m.visibleConnections()))
+	}
+}
+
+func TestTUI_NamespaceCycle(t *testing.T) {
+	m := New(Options{Theme: "dark", Interval: time.Hour})
+	m.width = 120
+	m.height = 40
+	m.connections = []collector.Connection{
+		{Process: "kubelet", Proto: "tcp", State: "LISTEN", Lport: 10250, Namespace: "cni-b"},
+		{Process: "sshd", Proto: "tcp", State: "LISTEN", Lport: 22, Namespace: collector.HostNamespace},
+		{Process: "app", Proto: "tcp", State: "LISTEN", Lport: 8080, Namespace: "cni-a"},
+	}
+
+	if cols := m.columnWidths(); cols.namespace == 0 {
+		t.Error("expected namespace column with several namespaces")
+	}
+	if !strings.Contains(m.View(), "NS") {
+		t.Error("expected NS header in main view")
+	}
+
+	want := []string{collector.HostNamespace, "cni-a", "cni-b", ""}
+	for _, ns := range want {
+		m.cycleNamespace()
+		if m.namespace != ns {
+			t.Fatalf("expected namespace %q, got %q", ns, m.namespace)
+		}
+		if ns != "" && len(m.visibleConnections()) != 1 {
+			t.Errorf("expected one connection in %s, got %d", ns, len(m.visibleConnections()))
+		}
+	}
+
+	m.connections = m.connections[:1]
+	if cols := m.columnWidths(); cols.namespace != 0 {
+		t.Error("expected namespace column to be hidden with a single namespace")
+	}
+}
+
+func TestTUI_WarningsInStatusLine(t *testing.T) {
+	m := New(Options{Theme: "dark", Interval: time.Hour})
+	m.width = 200
+	m.height = 40
+
+	updated, _ := m.Update(dataMsg{
+		connections: []collector.Connection{{Process: "sshd", Proto: "tcp", State: "LISTEN", Lport: 22}},
+		warnings: []collector.Warning{
+			{Kind: collector.WarningUnreadablePIDs, Count: 12},
+			{Kind: collector.WarningMissingCapabilities, Capabilities: []string{"CAP_SYS_PTRACE"}},
+		},
+	})
+	m = updated.(model)
+
+	status := m.renderStatusLine()
+	if !strings.Contains(status, "12 pids unreadable") || !strings.Contains(status, "no CAP_SYS_PTRACE") {
+		t.Errorf("expected warnings in status line, got %q", status)
+	}
+
+	updated, _ = m.Update(dataMsg{})
+	if status := updated.(model).renderStatusLine(); strings.Contains(status, "unreadable") {
+		t.Errorf("expected warnings to clear, got %q", status)
+	}
+}
+
 func TestTUI_SortCycleIncludesRemote(t *testing.T) {
 	m := New(Options{Theme: "dark", Interval: time.Hour})
 
@@ -776,3 +864,45 @@ func TestTUI_ExportModalRenderWithStats(t *testing.T) {
 	}
 }
 
+
+func TestTUI_SnapshotIsReadOnly(t *testing.T) {
+	m := New(Options{Theme: "dark", Interval: time.Hour, Snapshot: "web-1 at 2026-01-02T03:04:05Z"})
+	m.width = 200
+	m.height = 40
+
+	updated, _ := m.Update(dataMsg{
+		connections: []collector.Connection{{PID: 4242, Process: "nginx", Proto: "tcp", State: "LISTEN", Lport: 80}},
+	})
+	m = updated.(model)
+
+	if title := m.renderTitle(); !strings.Contains(title, "snapshot of web-1") {
+		t.Errorf("expected the snapshot in the title, got %q", title)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'K'}})
+	m = updated.(model)
+	if m.showKillConfirm || m.killTarget != nil {
+		t.Error("expected killing to be disabled for a snapshot")
+	}
+}
+
+func TestTUI_RemoteCannotKill(t *testing.T) {
+	m := New(Options{Theme: "dark", Interval: time.Hour, Remote: "web-1:9797"})
+	m.width = 200
+	m.height = 40
+
+	updated, _ := m.Update(dataMsg{
+		connections: []collector.Connection{{PID: 4242, Process: "nginx", Proto: "tcp", State: "LISTEN", Lport: 80}},
+	})
+	m = updated.(model)
+
+	if title := m.renderTitle(); !strings.Contains(title, "remote web-1:9797") {
+		t.Errorf("expected the remote in the title, got %q", title)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'K'}})
+	m = updated.(model)
+	if m.showKillConfirm || m.killTarget != nil {
+		t.Error("expected killing to be disabled for a remote host")
+	}
+}
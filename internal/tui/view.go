@@ -33,6 +33,12 @@ func (m model) renderTitle() string {
 	total := len(m.connections)
 
 	left := m.theme.Styles.Header.Render("snitch")
+	if m.snapshot != "" {
+		left += m.theme.Styles.Normal.Render("  snapshot of " + m.snapshot)
+	}
+	if m.remote != "" {
+		left += m.theme.Styles.Normal.Render("  remote " + m.remote)
+	}
 
 	ago := time.Since(m.lastRefresh).Round(time.Millisecond * 100)
 	right := m.theme.Styles.Normal.Render(fmt.Sprintf("%d/%d connections  %s %s", len(visible), total, SymbolRefresh, formatDuration(ago)))
@@ -58,6 +64,19 @@ func (m model) renderFilters() string {
 	parts = append(parts, m.renderFilterLabel("e", "stab", m.showEstablished))
 	parts = append(parts, m.renderFilterLabel("o", "ther", m.showOther))
 
+	if len(m.namespaces()) > 1 || m.namespace != "" {
+		ns := m.namespace
+		if ns == "" {
+			ns = "all"
+		}
+		parts = append(parts, m.theme.Styles.Border.Render(BoxVertical))
+		style := m.theme.Styles.Normal
+		if m.namespace != "" {
+			style = m.theme.Styles.Success
+		}
+		parts = append(parts, style.Render("ns: "+ns))
+	}
+
 	left := "  " + strings.Join(parts, "  ")
 
 	sortLabel := sortFieldLabel(m.sortField)
@@ -87,8 +106,11 @@ func (m model) renderFilters() string {
 func (m model) renderTableHeader() string {
 	cols := m.columnWidths()
 
-	header := fmt.Sprintf("  %-*s  %-*s  %-*s  %-*s  %-*s  %s",
+	header := fmt.Sprintf("  %s%-*s  %s%s%-*s  %-*s  %-*s  %-*s  %s",
+		optionalCell(cols.namespace, "NS"),
 		cols.process, "PROCESS",
+		optionalCell(cols.unit, "UNIT"),
+		optionalCell(cols.container, "CONTAINER"),
 		cols.port, "PORT",
 		cols.proto, "PROTO",
 		cols.state, "STATE",
@@ -175,15 +197,36 @@ func (m model) renderRow(c collector.Connection, selected bool) string {
 		local = "*"
 	}
 
+	// unix sockets have a path, or nothing at all, instead of address:port
+	if c.Proto == "unix" {
+		port = SymbolDash
+		local = truncate(c.Laddr, cols.local)
+		if local == "" {
+			local = SymbolDash
+		}
+	}
+
 	remote := truncate(m.formatRemote(c.Raddr, c.Rport, c.Proto), cols.remote)
 
 	// apply styling
 	protoStyled := m.theme.Styles.GetProtoStyle(proto).Render(fmt.Sprintf("%-*s", cols.proto, proto))
 	stateStyled := m.theme.Styles.GetStateStyle(state).Render(fmt.Sprintf("%-*s", cols.state, truncate(state, cols.state)))
 
-	row := fmt.Sprintf("%s%-*s  %-*s  %s  %s  %-*s  %s",
+	container := collector.ShortContainerID(c.ContainerID)
+	if container == "" {
+		container = SymbolDash
+	}
+	unit := truncate(c.Unit, cols.unit)
+	if unit == "" {
+		unit = SymbolDash
+	}
+
+	row := fmt.Sprintf("%s%s%-*s  %s%s%-*s  %s  %s  %-*s  %s",
 		indicator,
+		optionalCell(cols.namespace, truncate(c.Namespace, cols.namespace)),
 		cols.process, process,
+		optionalCell(cols.unit, unit),
+		optionalCell(cols.container, container),
 		cols.port, port,
 		protoStyled,
 		stateStyled,
@@ -226,9 +269,32 @@ func (m model) renderStatusLine() string {
 		left += m.theme.Styles.Normal.Render(fmt.Sprintf("  dns: %s", resolveStatus))
 	}
 
+	if summary := m.warningSummary(); summary != "" {
+		left += "  " + m.theme.Styles.Warning.Render(SymbolWarning+" "+summary)
+	}
+
 	return left
 }
 
+// warningSummary is a compact form of the collector warnings that fits the
+// status line
+func (m model) warningSummary() string {
+	var parts []string
+	for _, w := range m.warnings {
+		switch w.Kind {
+		case collector.WarningUnreadablePIDs:
+			parts = append(parts, fmt.Sprintf("%d pids unreadable", w.Count))
+		case collector.WarningUnattributedSockets:
+			parts = append(parts, fmt.Sprintf("%d sockets unattributed", w.Count))
+		case collector.WarningMissingCapabilities:
+			parts = append(parts, "no "+strings.Join(w.Capabilities, "/"))
+		default:
+			parts = append(parts, w.Message)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
 func (m model) renderError() string {
 	return fmt.Sprintf("\n  %s\n\n  press q to quit\n",
 		m.theme.Styles.Error.Render(fmt.Sprintf("error: %v", m.err)))
@@ -251,6 +317,7 @@ func (m model) renderHelp() string {
   e            toggle established
   o            toggle other states
   a            reset all filters
+  z            cycle network namespace
 
   sorting
   ───────
@@ -280,6 +347,11 @@ func (m model) renderHelp() string {
 	return m.theme.Styles.Normal.Render(help)
 }
 
+type detailField struct {
+	label string
+	value string
+}
+
 func (m model) renderDetail() string {
 	if m.selected == nil {
 		return ""
@@ -297,21 +369,66 @@ func (m model) renderDetail() string {
 	remoteAddr := m.resolveAddr(c.Raddr)
 	remotePort := m.resolvePort(c.Rport, c.Proto)
 
-	fields := []struct {
-		label string
-		value string
-	}{
+	local := fmt.Sprintf("%s:%s", localAddr, localPort)
+	remote := fmt.Sprintf("%s:%s", remoteAddr, remotePort)
+	if c.Proto == "unix" {
+		local, remote = c.Laddr, SymbolDash
+	}
+
+	fields := []detailField{
 		{"process", c.Process},
 		{"cmdline", c.Cmdline},
 		{"cwd", c.Cwd},
 		{"pid", fmt.Sprintf("%d", c.PID)},
+		{"ppid", fmt.Sprintf("%d", c.PPID)},
+		{"exe", formatExe(c.Exe, c.ExeDeleted)},
+		{"started", formatStarted(c.StartTime)},
 		{"user", c.User},
+		{"caps", formatCapabilities(c.CapEff)},
+		{"label", c.SecurityLabel},
 		{"protocol", c.Proto},
 		{"state", c.State},
-		{"local", fmt.Sprintf("%s:%s", localAddr, localPort)},
-		{"remote", fmt.Sprintf("%s:%s", remoteAddr, remotePort)},
+		{"local", local},
+		{"remote", remote},
 		{"interface", c.Interface},
+		{"namespace", c.Namespace},
+		{"unit", c.Unit},
+		{"socket unit", c.SocketUnit},
+		{"container", c.ContainerID},
+		{"runtime", c.ContainerRuntime},
+		{"pod", c.PodUID},
+		{"qos", c.PodQoS},
 		{"inode", fmt.Sprintf("%d", c.Inode)},
+		{"age", formatSocketAge(*c)},
+		{"send-q", fmt.Sprintf("%d", c.SendQ)},
+		{"recv-q", fmt.Sprintf("%d", c.RecvQ)},
+	}
+
+	if c.SockType != "" {
+		fields = append(fields, detailField{"type", c.SockType})
+	}
+	// a socket shared by forked processes, e.g. prefork workers
+	if len(c.Holders) > 1 {
+		fields = append(fields, detailField{"holders", formatHolders(c.Holders)})
+	}
+	if c.Timer != "" && c.Timer != "off" {
+		timer := fmt.Sprintf("%s (%s)", c.Timer, formatDuration(time.Duration(c.TimerExpiresMs)*time.Millisecond))
+		fields = append(fields, detailField{"timer", timer})
+	}
+	if c.Retransmits > 0 {
+		fields = append(fields, detailField{"retransmits", fmt.Sprintf("%d", c.Retransmits)})
+	}
+
+	// counters only exist for tcp sockets read over netlink
+	if c.RxBytes > 0 || c.TxBytes > 0 || c.RttMs > 0 {
+		fields = append(fields, []detailField{
+			{"rx", formatBytes(c.RxBytes)},
+			{"tx", formatBytes(c.TxBytes)},
+			{"rtt", fmt.Sprintf("%.1fms", c.RttMs)},
+			{"cwnd", fmt.Sprintf("%d", c.Cwnd)},
+			{"retrans", fmt.Sprintf("%d", c.TotalRetrans)},
+			{"delivery", formatBytes(c.DeliveryRate) + "/s"},
+		}...)
 	}
 
 	for _, f := range fields {
@@ -632,12 +749,15 @@ func (m model) scrollOffset(pageSize, total int) int {
 }
 
 type columns struct {
-	process int
-	port    int
-	proto   int
-	state   int
-	local   int
-	remote  int
+	namespace int // 0 when the column is hidden
+	container int // 0 when the column is hidden
+	unit      int // 0 when the column is hidden
+	process   int
+	port      int
+	proto     int
+	state     int
+	local     int
+	remote    int
 }
 
 func (m model) columnWidths() columns {
@@ -651,9 +771,25 @@ func (m model) columnWidths() columns {
 		remote:  6,  // "REMOTE"
 	}
 
+	// the namespace column only earns its space when there is a choice
+	showNamespace := m.namespace == "" && len(m.namespaces()) > 1
+	if showNamespace {
+		c.namespace = 2 // "NS"
+	}
+
 	// scan visible connections to find max content width for each column
 	visible := m.visibleConnections()
 	for _, conn := range visible {
+		if conn.ContainerID != "" {
+			c.container = 12 // short id, as docker prints it
+		}
+		if conn.Unit != "" && len(conn.Unit) > c.unit {
+			c.unit = max(4, min(len(conn.Unit), 24)) // at least "UNIT"
+		}
+		if showNamespace && len(conn.Namespace) > c.namespace {
+			c.namespace = min(len(conn.Namespace), 12)
+		}
+
 		if len(conn.Process) > c.process {
 			c.process = len(conn.Process)
 		}
@@ -684,11 +820,20 @@ func (m model) columnWidths() columns {
 
 	// calculate total and available width
 	spacing := 12 // 2 spaces between each of 6 columns
+	if showNamespace {
+		spacing += 2
+	}
+	if c.container > 0 {
+		spacing += 2
+	}
+	if c.unit > 0 {
+		spacing += 2
+	}
 	indicator := 2
 	margin := 2
 	available := m.safeWidth() - spacing - indicator - margin
 
-	total := c.process + c.port + c.proto + c.state + c.local + c.remote
+	total := c.namespace + c.container + c.unit + c.process + c.port + c.proto + c.state + c.local + c.remote
 
 	// if content fits, we're done
 	if total <= available {
@@ -697,7 +842,7 @@ func (m model) columnWidths() columns {
 
 	// content exceeds available space - need to shrink columns proportionally
 	// fixed columns that shouldn't shrink much: port, proto, state
-	fixedWidth := c.port + c.proto + c.state
+	fixedWidth := c.namespace + c.container + c.unit + c.port + c.proto + c.state
 	flexibleAvailable := available - fixedWidth
 
 	// distribute flexible space between process, local, remote
@@ -712,6 +857,14 @@ func (m model) columnWidths() columns {
 	return c
 }
 
+// optionalCell renders a column that may be hidden, including its gap
+func optionalCell(width int, value string) string {
+	if width == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%-*s  ", width, value)
+}
+
 func (m model) safeWidth() int {
 	if m.width < 80 {
 		return 80